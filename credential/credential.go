@@ -1,5 +1,7 @@
 package credential
 
+import "time"
+
 // Credential contains all needed information about a WebAuthn credential for storage
 type Credential struct {
 	// A probabilistically-unique byte sequence identifying a public key credential source and its authentication assertions.
@@ -15,4 +17,52 @@ type Credential struct {
 	UserVerification bool
 	// The Authenticator information for a given certificate
 	Authenticator Authenticator
+	// A name chosen by the user to tell their credentials apart
+	Name string
+	// The time of the last successful login with the credential, zero if it was never used for a login
+	LastUsedAt time.Time
+	// The transports the authenticator supports, as reported by the client during registration, e.g. "usb" or "internal"
+	Transports []string
+	// The attachment of the authenticator as reported by the client, "platform", "cross-platform" or empty if unknown
+	AuthenticatorAttachment string
+	// Indicates if the credential can be backed up, e.g. synced to other devices (BE flag). It is nil if the flag is
+	// unknown because the credential was stored before the flag was recorded, it is then learned on the next login.
+	BackupEligible *bool
+	// Indicates if the credential was backed up when the flags were last seen (BS flag)
+	BackupState bool
+	// The time the credential was registered
+	CreatedAt time.Time
+	// The raw attestation object returned during registration, which allows to verify the attestation again later
+	AttestationObject []byte
+	// Indicates if the credential is client-side discoverable, i.e. it can be used for a login without username
+	Discoverable bool
+	// Indicates if the authenticator can store a large blob for the credential (largeBlob extension)
+	LargeBlobSupported bool
+	// Indicates if the credential was quarantined, because its signature counter suggests that the authenticator was
+	// cloned. A quarantined credential can not be used for logins until the flag is cleared.
+	Quarantined bool
+}
+
+// clone returns a copy of the credential which does not share any memory with the original
+func (c *Credential) clone() Credential {
+	cloned := *c
+	cloned.ID = cloneBytes(c.ID)
+	cloned.PublicKey = cloneBytes(c.PublicKey)
+	cloned.Authenticator.AAGUID = cloneBytes(c.Authenticator.AAGUID)
+	cloned.AttestationObject = cloneBytes(c.AttestationObject)
+	if c.Transports != nil {
+		cloned.Transports = append([]string{}, c.Transports...)
+	}
+	if c.BackupEligible != nil {
+		backupEligible := *c.BackupEligible
+		cloned.BackupEligible = &backupEligible
+	}
+	return cloned
+}
+
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
 }
@@ -1,8 +1,33 @@
 package credential
 
-// TODO: Doc
+import "errors"
+
+var (
+	// ErrCredentialNotFound is returned by a CredentialService if the credential to change does not exist
+	ErrCredentialNotFound = errors.New("credential not found")
+	// ErrCredentialAlreadyExists is returned by a CredentialService if a credential with the same ID is already stored
+	ErrCredentialAlreadyExists = errors.New("credential already exists")
+)
+
+// CredentialService is used to look up and persist the credentials of the users. It has to be implemented by the
+// Relying Party to connect the library to its storage.
 type CredentialService interface {
+	// ExistsCredential returns whether a credential with the given ID is already registered
 	ExistsCredential(credentialId []byte) (bool, error)
+	// GetCredential returns the credential with the given ID and the ID of the user it belongs to. If no credential
+	// with the ID exists, it returns nil for both without an error.
 	GetCredential(credentialId []byte) (cred *Credential, userId []byte, err error)
+	// GetCredentialForUser returns all credentials registered for the user
 	GetCredentialForUser(userId []byte) ([]Credential, error)
+	// StoreCredential stores a newly registered credential for the user
+	StoreCredential(userId []byte, cred *Credential) error
+	// UpdateCredential persists the state of a credential that changes with every login, i.e. the sign count of the
+	// authenticator, the time the credential was last used, its backup state and whether it is quarantined. It also
+	// persists the backup eligibility, which is learned on the first login if it was unknown. The stored sign count
+	// never decreases: a lower sign count is ignored, while the other fields are still updated.
+	UpdateCredential(cred *Credential) error
+	// RenameCredential changes the name of the credential with the given ID
+	RenameCredential(credentialId []byte, name string) error
+	// DeleteCredential removes the credential with the given ID
+	DeleteCredential(credentialId []byte) error
 }
@@ -0,0 +1,367 @@
+// Package credentialtest contains a conformance test suite for implementations of credential.CredentialService. A
+// backend is tested by calling RunConformanceTests from a regular Go test with a function that creates an empty
+// instance of the backend.
+package credentialtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teamhanko/webauthn-go/credential"
+)
+
+// Factory creates a new, empty CredentialService for a single test
+type Factory func(t *testing.T) credential.CredentialService
+
+// RunConformanceTests runs all conformance tests against the CredentialService created by the factory. Every test
+// runs as a subtest and gets a fresh instance.
+func RunConformanceTests(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, service credential.CredentialService)
+	}{
+		{"StoreAndGetCredential", testStoreAndGetCredential},
+		{"GetUnknownCredential", testGetUnknownCredential},
+		{"StoreDuplicateCredential", testStoreDuplicateCredential},
+		{"GetCredentialForUser", testGetCredentialForUser},
+		{"UpdateCredential", testUpdateCredential},
+		{"SignCountNeverDecreases", testSignCountNeverDecreases},
+		{"RenameCredential", testRenameCredential},
+		{"DeleteCredential", testDeleteCredential},
+		{"UnknownCredentialChanges", testUnknownCredentialChanges},
+		{"ReturnedCredentialIsCopy", testReturnedCredentialIsCopy},
+		{"ConcurrentUpdates", testConcurrentUpdates},
+		{"ConcurrentStoreCredential", testConcurrentStoreCredential},
+		{"UnknownBackupEligibility", testUnknownBackupEligibility},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, factory(t))
+		})
+	}
+}
+
+// NewTestCredential returns a fully populated credential with the given ID, which can be used to test backends
+func NewTestCredential(id string) *credential.Credential {
+	return &credential.Credential{
+		ID:               []byte(id),
+		PublicKey:        []byte("public-key-" + id),
+		AttestationType:  "packed",
+		UserVerification: true,
+		Authenticator: credential.Authenticator{
+			AAGUID:    bytes.Repeat([]byte{0xad}, 16),
+			SignCount: 42,
+		},
+		Name:                    "Key " + id,
+		Transports:              []string{"usb", "nfc"},
+		AuthenticatorAttachment: "cross-platform",
+		BackupEligible:          boolPointer(true),
+		BackupState:             false,
+		CreatedAt:               time.Date(2022, 2, 1, 12, 0, 0, 0, time.UTC),
+		AttestationObject:       []byte("attestation-object-" + id),
+		Discoverable:            true,
+		LargeBlobSupported:      true,
+	}
+}
+
+func boolPointer(b bool) *bool {
+	return &b
+}
+
+func equalBoolPointer(a, b *bool) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func storeCredential(t *testing.T, service credential.CredentialService, userId string, cred *credential.Credential) {
+	t.Helper()
+	if err := service.StoreCredential([]byte(userId), cred); err != nil {
+		t.Fatalf("StoreCredential() error = %v", err)
+	}
+}
+
+func getCredential(t *testing.T, service credential.CredentialService, id []byte) (*credential.Credential, []byte) {
+	t.Helper()
+	cred, userId, err := service.GetCredential(id)
+	if err != nil {
+		t.Fatalf("GetCredential() error = %v", err)
+	}
+	return cred, userId
+}
+
+func assertEqualCredential(t *testing.T, got, want *credential.Credential) {
+	t.Helper()
+	if got == nil {
+		t.Fatalf("credential = nil, want %+v", want)
+	}
+	if !bytes.Equal(got.ID, want.ID) ||
+		!bytes.Equal(got.PublicKey, want.PublicKey) ||
+		got.AttestationType != want.AttestationType ||
+		got.UserVerification != want.UserVerification ||
+		!bytes.Equal(got.Authenticator.AAGUID, want.Authenticator.AAGUID) ||
+		got.Authenticator.SignCount != want.Authenticator.SignCount ||
+		got.Name != want.Name ||
+		!got.LastUsedAt.Equal(want.LastUsedAt) ||
+		strings.Join(got.Transports, ",") != strings.Join(want.Transports, ",") ||
+		got.AuthenticatorAttachment != want.AuthenticatorAttachment ||
+		!equalBoolPointer(got.BackupEligible, want.BackupEligible) ||
+		got.BackupState != want.BackupState ||
+		!got.CreatedAt.Equal(want.CreatedAt) ||
+		!bytes.Equal(got.AttestationObject, want.AttestationObject) ||
+		got.Discoverable != want.Discoverable ||
+		got.LargeBlobSupported != want.LargeBlobSupported ||
+		got.Quarantined != want.Quarantined {
+		t.Errorf("credential = %+v, want %+v", got, want)
+	}
+}
+
+func testStoreAndGetCredential(t *testing.T, service credential.CredentialService) {
+	want := NewTestCredential("credential-1")
+	storeCredential(t, service, "user-1", want)
+
+	exists, err := service.ExistsCredential(want.ID)
+	if err != nil || !exists {
+		t.Errorf("ExistsCredential() = %v, %v, want true, nil", exists, err)
+	}
+
+	got, userId := getCredential(t, service, want.ID)
+	assertEqualCredential(t, got, want)
+	if string(userId) != "user-1" {
+		t.Errorf("GetCredential() userId = %s, want user-1", string(userId))
+	}
+}
+
+func testGetUnknownCredential(t *testing.T, service credential.CredentialService) {
+	exists, err := service.ExistsCredential([]byte("unknown"))
+	if err != nil || exists {
+		t.Errorf("ExistsCredential() = %v, %v, want false, nil", exists, err)
+	}
+
+	cred, userId := getCredential(t, service, []byte("unknown"))
+	if cred != nil || userId != nil {
+		t.Errorf("GetCredential() = %+v, %v, want nil, nil", cred, userId)
+	}
+
+	credentials, err := service.GetCredentialForUser([]byte("unknown"))
+	if err != nil || len(credentials) != 0 {
+		t.Errorf("GetCredentialForUser() = %+v, %v, want empty, nil", credentials, err)
+	}
+}
+
+func testStoreDuplicateCredential(t *testing.T, service credential.CredentialService) {
+	cred := NewTestCredential("credential-1")
+	storeCredential(t, service, "user-1", cred)
+
+	err := service.StoreCredential([]byte("user-2"), cred)
+	if !errors.Is(err, credential.ErrCredentialAlreadyExists) {
+		t.Errorf("StoreCredential() error = %v, want %v", err, credential.ErrCredentialAlreadyExists)
+	}
+
+	_, userId := getCredential(t, service, cred.ID)
+	if string(userId) != "user-1" {
+		t.Errorf("GetCredential() userId = %s, want user-1", string(userId))
+	}
+}
+
+func testGetCredentialForUser(t *testing.T, service credential.CredentialService) {
+	storeCredential(t, service, "user-1", NewTestCredential("credential-b"))
+	storeCredential(t, service, "user-1", NewTestCredential("credential-a"))
+	storeCredential(t, service, "user-2", NewTestCredential("credential-c"))
+
+	credentials, err := service.GetCredentialForUser([]byte("user-1"))
+	if err != nil {
+		t.Fatalf("GetCredentialForUser() error = %v", err)
+	}
+	if len(credentials) != 2 {
+		t.Fatalf("GetCredentialForUser() returned %d credentials, want 2", len(credentials))
+	}
+	ids := map[string]bool{}
+	for _, cred := range credentials {
+		ids[string(cred.ID)] = true
+	}
+	if !ids["credential-a"] || !ids["credential-b"] {
+		t.Errorf("GetCredentialForUser() = %v, want credential-a and credential-b", ids)
+	}
+}
+
+func testUpdateCredential(t *testing.T, service credential.CredentialService) {
+	cred := NewTestCredential("credential-1")
+	storeCredential(t, service, "user-1", cred)
+
+	cred.Authenticator.SignCount = 43
+	cred.LastUsedAt = time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	cred.BackupState = true
+	cred.Quarantined = true
+	if err := service.UpdateCredential(cred); err != nil {
+		t.Fatalf("UpdateCredential() error = %v", err)
+	}
+
+	got, _ := getCredential(t, service, cred.ID)
+	assertEqualCredential(t, got, cred)
+}
+
+func testSignCountNeverDecreases(t *testing.T, service credential.CredentialService) {
+	cred := NewTestCredential("credential-1")
+	storeCredential(t, service, "user-1", cred)
+
+	cred.Authenticator.SignCount = 50
+	if err := service.UpdateCredential(cred); err != nil {
+		t.Fatalf("UpdateCredential() error = %v", err)
+	}
+
+	stale := NewTestCredential("credential-1")
+	stale.Authenticator.SignCount = 45
+	stale.LastUsedAt = time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	stale.Quarantined = true
+	if err := service.UpdateCredential(stale); err != nil {
+		t.Fatalf("UpdateCredential() error = %v", err)
+	}
+
+	got, _ := getCredential(t, service, cred.ID)
+	stale.Authenticator.SignCount = 50
+	assertEqualCredential(t, got, stale)
+}
+
+func testRenameCredential(t *testing.T, service credential.CredentialService) {
+	cred := NewTestCredential("credential-1")
+	storeCredential(t, service, "user-1", cred)
+
+	if err := service.RenameCredential(cred.ID, "Yubikey"); err != nil {
+		t.Fatalf("RenameCredential() error = %v", err)
+	}
+
+	got, _ := getCredential(t, service, cred.ID)
+	cred.Name = "Yubikey"
+	assertEqualCredential(t, got, cred)
+}
+
+func testDeleteCredential(t *testing.T, service credential.CredentialService) {
+	cred := NewTestCredential("credential-1")
+	storeCredential(t, service, "user-1", cred)
+	storeCredential(t, service, "user-1", NewTestCredential("credential-2"))
+
+	if err := service.DeleteCredential(cred.ID); err != nil {
+		t.Fatalf("DeleteCredential() error = %v", err)
+	}
+
+	got, _ := getCredential(t, service, cred.ID)
+	if got != nil {
+		t.Errorf("GetCredential() after delete = %+v, want nil", got)
+	}
+	credentials, err := service.GetCredentialForUser([]byte("user-1"))
+	if err != nil || len(credentials) != 1 {
+		t.Errorf("GetCredentialForUser() after delete = %+v, %v, want one credential", credentials, err)
+	}
+}
+
+func testUnknownCredentialChanges(t *testing.T, service credential.CredentialService) {
+	cred := NewTestCredential("unknown")
+
+	if err := service.UpdateCredential(cred); !errors.Is(err, credential.ErrCredentialNotFound) {
+		t.Errorf("UpdateCredential() error = %v, want %v", err, credential.ErrCredentialNotFound)
+	}
+	if err := service.RenameCredential(cred.ID, "name"); !errors.Is(err, credential.ErrCredentialNotFound) {
+		t.Errorf("RenameCredential() error = %v, want %v", err, credential.ErrCredentialNotFound)
+	}
+	if err := service.DeleteCredential(cred.ID); !errors.Is(err, credential.ErrCredentialNotFound) {
+		t.Errorf("DeleteCredential() error = %v, want %v", err, credential.ErrCredentialNotFound)
+	}
+}
+
+func testUnknownBackupEligibility(t *testing.T, service credential.CredentialService) {
+	cred := NewTestCredential("credential-1")
+	cred.BackupEligible = nil
+	storeCredential(t, service, "user-1", cred)
+
+	got, _ := getCredential(t, service, cred.ID)
+	assertEqualCredential(t, got, cred)
+
+	cred.BackupEligible = boolPointer(true)
+	if err := service.UpdateCredential(cred); err != nil {
+		t.Fatalf("UpdateCredential() error = %v", err)
+	}
+	got, _ = getCredential(t, service, cred.ID)
+	assertEqualCredential(t, got, cred)
+}
+
+func testReturnedCredentialIsCopy(t *testing.T, service credential.CredentialService) {
+	want := NewTestCredential("credential-1")
+	storeCredential(t, service, "user-1", want)
+
+	got, _ := getCredential(t, service, want.ID)
+	got.Authenticator.SignCount = 100
+	got.PublicKey[0] = 'X'
+	got.Transports[0] = "ble"
+
+	got, _ = getCredential(t, service, want.ID)
+	assertEqualCredential(t, got, NewTestCredential("credential-1"))
+}
+
+func testConcurrentUpdates(t *testing.T, service credential.CredentialService) {
+	const count = 20
+	for i := 0; i < count; i++ {
+		storeCredential(t, service, "user-1", NewTestCredential(fmt.Sprintf("credential-%d", i)))
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cred := NewTestCredential(fmt.Sprintf("credential-%d", i))
+			cred.Authenticator.SignCount = uint32(1000 + i)
+			errs <- service.UpdateCredential(cred)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("UpdateCredential() error = %v", err)
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		got, _ := getCredential(t, service, []byte(fmt.Sprintf("credential-%d", i)))
+		if got == nil || got.Authenticator.SignCount != uint32(1000+i) {
+			t.Errorf("GetCredential() = %+v, want SignCount %d", got, 1000+i)
+		}
+	}
+}
+
+func testConcurrentStoreCredential(t *testing.T, service credential.CredentialService) {
+	const count = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- service.StoreCredential([]byte(fmt.Sprintf("user-%d", i)), NewTestCredential("credential-1"))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	stored := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			stored++
+		case !errors.Is(err, credential.ErrCredentialAlreadyExists):
+			t.Errorf("StoreCredential() error = %v, want nil or %v", err, credential.ErrCredentialAlreadyExists)
+		}
+	}
+	if stored != 1 {
+		t.Errorf("StoreCredential() succeeded %d times, want 1", stored)
+	}
+}
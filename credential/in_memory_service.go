@@ -0,0 +1,134 @@
+package credential
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// storedCredential is a credential together with the ID of the user it belongs to
+type storedCredential struct {
+	UserID     []byte     `json:"user_id"`
+	Credential Credential `json:"credential"`
+}
+
+// InMemoryCredentialService keeps the credentials in memory. It is safe for concurrent use and is meant for tests and
+// prototypes, all credentials are lost when the process exits.
+type InMemoryCredentialService struct {
+	credentials map[string]*storedCredential
+	mu          sync.RWMutex
+}
+
+var _ CredentialService = (*InMemoryCredentialService)(nil)
+
+// NewInMemoryCredentialService creates an empty InMemoryCredentialService
+func NewInMemoryCredentialService() *InMemoryCredentialService {
+	return &InMemoryCredentialService{
+		credentials: make(map[string]*storedCredential),
+	}
+}
+
+func (s *InMemoryCredentialService) ExistsCredential(credentialId []byte) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.credentials[string(credentialId)]
+	return ok, nil
+}
+
+func (s *InMemoryCredentialService) GetCredential(credentialId []byte) (*Credential, []byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, ok := s.credentials[string(credentialId)]
+	if !ok {
+		return nil, nil, nil
+	}
+	cred := stored.Credential.clone()
+	return &cred, cloneBytes(stored.UserID), nil
+}
+
+func (s *InMemoryCredentialService) GetCredentialForUser(userId []byte) ([]Credential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return credentialsForUser(s.credentials, userId), nil
+}
+
+func (s *InMemoryCredentialService) StoreCredential(userId []byte, cred *Credential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.credentials[string(cred.ID)]; ok {
+		return ErrCredentialAlreadyExists
+	}
+	s.credentials[string(cred.ID)] = &storedCredential{
+		UserID:     cloneBytes(userId),
+		Credential: cred.clone(),
+	}
+	return nil
+}
+
+func (s *InMemoryCredentialService) UpdateCredential(cred *Credential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.credentials[string(cred.ID)]
+	if !ok {
+		return ErrCredentialNotFound
+	}
+	updateStoredCredential(stored, cred)
+	return nil
+}
+
+func (s *InMemoryCredentialService) RenameCredential(credentialId []byte, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.credentials[string(credentialId)]
+	if !ok {
+		return ErrCredentialNotFound
+	}
+	stored.Credential.Name = name
+	return nil
+}
+
+func (s *InMemoryCredentialService) DeleteCredential(credentialId []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.credentials[string(credentialId)]; !ok {
+		return ErrCredentialNotFound
+	}
+	delete(s.credentials, string(credentialId))
+	return nil
+}
+
+// credentialsForUser returns copies of all credentials of the user, ordered by their ID
+func credentialsForUser(credentials map[string]*storedCredential, userId []byte) []Credential {
+	var result []Credential
+	for _, stored := range credentials {
+		if bytes.Equal(stored.UserID, userId) {
+			result = append(result, stored.Credential.clone())
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return bytes.Compare(result[i].ID, result[j].ID) < 0
+	})
+	return result
+}
+
+// updateStoredCredential copies the fields which UpdateCredential is allowed to change. The sign count is only
+// increased, so that a concurrent login with an older count can't lower it again.
+func updateStoredCredential(stored *storedCredential, cred *Credential) {
+	if cred.Authenticator.SignCount > stored.Credential.Authenticator.SignCount {
+		stored.Credential.Authenticator.SignCount = cred.Authenticator.SignCount
+	}
+	stored.Credential.LastUsedAt = cred.LastUsedAt
+	stored.Credential.BackupState = cred.BackupState
+	if cred.BackupEligible != nil {
+		backupEligible := *cred.BackupEligible
+		stored.Credential.BackupEligible = &backupEligible
+	}
+	stored.Credential.Quarantined = cred.Quarantined
+}
@@ -0,0 +1,14 @@
+package credential_test
+
+import (
+	"testing"
+
+	"github.com/teamhanko/webauthn-go/credential"
+	"github.com/teamhanko/webauthn-go/credential/credentialtest"
+)
+
+func TestInMemoryCredentialService(t *testing.T) {
+	credentialtest.RunConformanceTests(t, func(t *testing.T) credential.CredentialService {
+		return credential.NewInMemoryCredentialService()
+	})
+}
@@ -0,0 +1,178 @@
+package credential
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// JSONFileCredentialService keeps the credentials in memory and writes all of them to a JSON file after every change.
+// The file is replaced atomically, so it is never left in a partially written state. It is safe for concurrent use
+// within one process, but the file must not be shared between processes.
+type JSONFileCredentialService struct {
+	path        string
+	credentials map[string]*storedCredential
+	mu          sync.RWMutex
+}
+
+var _ CredentialService = (*JSONFileCredentialService)(nil)
+
+// NewJSONFileCredentialService creates a JSONFileCredentialService which stores the credentials in the file at the
+// given path. Existing credentials are loaded from the file, if it does not exist it is created on the first change.
+func NewJSONFileCredentialService(path string) (*JSONFileCredentialService, error) {
+	s := &JSONFileCredentialService{
+		path:        path,
+		credentials: make(map[string]*storedCredential),
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []*storedCredential
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse credential file %s: %w", path, err)
+	}
+	for _, c := range stored {
+		s.credentials[string(c.Credential.ID)] = c
+	}
+
+	return s, nil
+}
+
+func (s *JSONFileCredentialService) ExistsCredential(credentialId []byte) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.credentials[string(credentialId)]
+	return ok, nil
+}
+
+func (s *JSONFileCredentialService) GetCredential(credentialId []byte) (*Credential, []byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, ok := s.credentials[string(credentialId)]
+	if !ok {
+		return nil, nil, nil
+	}
+	cred := stored.Credential.clone()
+	return &cred, cloneBytes(stored.UserID), nil
+}
+
+func (s *JSONFileCredentialService) GetCredentialForUser(userId []byte) ([]Credential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return credentialsForUser(s.credentials, userId), nil
+}
+
+func (s *JSONFileCredentialService) StoreCredential(userId []byte, cred *Credential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.credentials[string(cred.ID)]; ok {
+		return ErrCredentialAlreadyExists
+	}
+	s.credentials[string(cred.ID)] = &storedCredential{
+		UserID:     cloneBytes(userId),
+		Credential: cred.clone(),
+	}
+	if err := s.save(); err != nil {
+		delete(s.credentials, string(cred.ID))
+		return err
+	}
+	return nil
+}
+
+func (s *JSONFileCredentialService) UpdateCredential(cred *Credential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.credentials[string(cred.ID)]
+	if !ok {
+		return ErrCredentialNotFound
+	}
+	previous := *stored
+	updateStoredCredential(stored, cred)
+	if err := s.save(); err != nil {
+		*stored = previous
+		return err
+	}
+	return nil
+}
+
+func (s *JSONFileCredentialService) RenameCredential(credentialId []byte, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.credentials[string(credentialId)]
+	if !ok {
+		return ErrCredentialNotFound
+	}
+	previousName := stored.Credential.Name
+	stored.Credential.Name = name
+	if err := s.save(); err != nil {
+		stored.Credential.Name = previousName
+		return err
+	}
+	return nil
+}
+
+func (s *JSONFileCredentialService) DeleteCredential(credentialId []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.credentials[string(credentialId)]
+	if !ok {
+		return ErrCredentialNotFound
+	}
+	delete(s.credentials, string(credentialId))
+	if err := s.save(); err != nil {
+		s.credentials[string(credentialId)] = stored
+		return err
+	}
+	return nil
+}
+
+// save writes all credentials to a temporary file and moves it to the configured path. The caller must hold the
+// write lock.
+func (s *JSONFileCredentialService) save() error {
+	stored := make([]*storedCredential, 0, len(s.credentials))
+	for _, c := range s.credentials {
+		stored = append(stored, c)
+	}
+	sort.Slice(stored, func(i, j int) bool {
+		return bytes.Compare(stored[i].Credential.ID, stored[j].Credential.ID) < 0
+	})
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
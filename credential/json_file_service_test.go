@@ -0,0 +1,47 @@
+package credential_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/teamhanko/webauthn-go/credential"
+	"github.com/teamhanko/webauthn-go/credential/credentialtest"
+)
+
+func TestJSONFileCredentialService(t *testing.T) {
+	credentialtest.RunConformanceTests(t, func(t *testing.T) credential.CredentialService {
+		service, err := credential.NewJSONFileCredentialService(filepath.Join(t.TempDir(), "credentials.json"))
+		if err != nil {
+			t.Fatalf("NewJSONFileCredentialService() error = %v", err)
+		}
+		return service
+	})
+}
+
+func TestJSONFileCredentialService_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	service, err := credential.NewJSONFileCredentialService(path)
+	if err != nil {
+		t.Fatalf("NewJSONFileCredentialService() error = %v", err)
+	}
+
+	cred := credentialtest.NewTestCredential("credential-1")
+	if err := service.StoreCredential([]byte("user-1"), cred); err != nil {
+		t.Fatalf("StoreCredential() error = %v", err)
+	}
+	if err := service.RenameCredential(cred.ID, "Yubikey"); err != nil {
+		t.Fatalf("RenameCredential() error = %v", err)
+	}
+
+	reloaded, err := credential.NewJSONFileCredentialService(path)
+	if err != nil {
+		t.Fatalf("NewJSONFileCredentialService() reload error = %v", err)
+	}
+	got, userId, err := reloaded.GetCredential(cred.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetCredential() = %v, %v, want credential", got, err)
+	}
+	if got.Name != "Yubikey" || string(userId) != "user-1" {
+		t.Errorf("GetCredential() = %+v, %s, want renamed credential of user-1", got, string(userId))
+	}
+}
@@ -0,0 +1,372 @@
+package credential
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLPlaceholderStyle defines how the bind parameters of a query are written for a database driver
+type SQLPlaceholderStyle int
+
+const (
+	// QuestionMarkPlaceholders are used by SQLite, e.g. "WHERE id = ?"
+	QuestionMarkPlaceholders SQLPlaceholderStyle = iota
+	// DollarPlaceholders are used by PostgreSQL, e.g. "WHERE id = $1"
+	DollarPlaceholders
+)
+
+// sqlMigration is one step of the schema of the SQLCredentialService. Migrations are applied in order and must never
+// be changed once released, new columns or tables are added with a new migration.
+type sqlMigration struct {
+	Version    int
+	Statements []string
+}
+
+var sqlMigrations = []sqlMigration{
+	{
+		Version: 1,
+		Statements: []string{
+			`CREATE TABLE webauthn_credentials (
+				id VARCHAR(1400) NOT NULL PRIMARY KEY,
+				user_id VARCHAR(100) NOT NULL,
+				public_key TEXT NOT NULL,
+				attestation_type VARCHAR(100) NOT NULL,
+				user_verification BOOLEAN NOT NULL,
+				aaguid VARCHAR(100) NOT NULL,
+				sign_count BIGINT NOT NULL,
+				name VARCHAR(255) NOT NULL,
+				last_used_at BIGINT NOT NULL
+			)`,
+			`CREATE INDEX webauthn_credentials_user_id_idx ON webauthn_credentials (user_id)`,
+		},
+	},
+	{
+		Version: 2,
+		Statements: []string{
+			`ALTER TABLE webauthn_credentials ADD COLUMN transports VARCHAR(255) NOT NULL DEFAULT ''`,
+			`ALTER TABLE webauthn_credentials ADD COLUMN authenticator_attachment VARCHAR(50) NOT NULL DEFAULT ''`,
+			`ALTER TABLE webauthn_credentials ADD COLUMN backup_eligible BOOLEAN NOT NULL DEFAULT FALSE`,
+			`ALTER TABLE webauthn_credentials ADD COLUMN backup_state BOOLEAN NOT NULL DEFAULT FALSE`,
+			`ALTER TABLE webauthn_credentials ADD COLUMN created_at BIGINT NOT NULL DEFAULT 0`,
+			`ALTER TABLE webauthn_credentials ADD COLUMN attestation_object TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		Version: 3,
+		Statements: []string{
+			`ALTER TABLE webauthn_credentials ADD COLUMN discoverable BOOLEAN NOT NULL DEFAULT FALSE`,
+		},
+	},
+	{
+		Version: 4,
+		Statements: []string{
+			`ALTER TABLE webauthn_credentials ADD COLUMN large_blob_supported BOOLEAN NOT NULL DEFAULT FALSE`,
+		},
+	},
+	{
+		Version: 5,
+		Statements: []string{
+			`ALTER TABLE webauthn_credentials ADD COLUMN quarantined BOOLEAN NOT NULL DEFAULT FALSE`,
+		},
+	},
+	{
+		// The backup eligibility of credentials stored before migration 2 is unknown, they have no creation time
+		Version: 6,
+		Statements: []string{
+			`ALTER TABLE webauthn_credentials ADD COLUMN backup_eligible_known BOOLEAN NOT NULL DEFAULT FALSE`,
+			`UPDATE webauthn_credentials SET backup_eligible_known = TRUE WHERE created_at <> 0`,
+		},
+	},
+}
+
+const sqlMigrationsTable = "webauthn_schema_migrations"
+
+// SQLCredentialService stores the credentials in a SQL database accessed through database/sql. Binary values are
+// stored base64url encoded and timestamps as unix nanoseconds, so the schema only uses portable column types. It is
+// tested with SQLite and written to work with PostgreSQL when DollarPlaceholders are used. MySQL is not supported,
+// the schema exceeds its index key length and it can not run the migrations in a transaction.
+type SQLCredentialService struct {
+	db           *sql.DB
+	placeholders SQLPlaceholderStyle
+}
+
+var _ CredentialService = (*SQLCredentialService)(nil)
+
+// NewSQLCredentialService creates a SQLCredentialService on top of the given database and migrates the schema to the
+// latest version.
+func NewSQLCredentialService(db *sql.DB, placeholders SQLPlaceholderStyle) (*SQLCredentialService, error) {
+	s := &SQLCredentialService{
+		db:           db,
+		placeholders: placeholders,
+	}
+	if err := s.Migrate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Migrate applies all schema migrations which have not been applied to the database yet. Every migration runs in
+// its own transaction together with the update of the schema version.
+func (s *SQLCredentialService) Migrate() error {
+	_, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS ` + sqlMigrationsTable + ` (version INTEGER NOT NULL PRIMARY KEY)`)
+	if err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	var current sql.NullInt64
+	err = s.db.QueryRow(`SELECT MAX(version) FROM ` + sqlMigrationsTable).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for _, migration := range sqlMigrations {
+		if int64(migration.Version) <= current.Int64 {
+			continue
+		}
+		if err := s.applyMigration(migration); err != nil {
+			return fmt.Errorf("failed to apply migration %d: %w", migration.Version, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLCredentialService) applyMigration(migration sqlMigration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, statement := range migration.Statements {
+		if _, err := tx.Exec(statement); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.Exec(s.query(`INSERT INTO `+sqlMigrationsTable+` (version) VALUES (?)`), migration.Version); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+const sqlCredentialColumns = `id, user_id, public_key, attestation_type, user_verification, aaguid, sign_count, name, last_used_at, ` +
+	`transports, authenticator_attachment, backup_eligible, backup_state, created_at, attestation_object, discoverable, large_blob_supported, quarantined, ` +
+	`backup_eligible_known`
+
+func (s *SQLCredentialService) ExistsCredential(credentialId []byte) (bool, error) {
+	var count int
+	err := s.db.QueryRow(s.query(`SELECT COUNT(*) FROM webauthn_credentials WHERE id = ?`), encodeSQLBytes(credentialId)).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *SQLCredentialService) GetCredential(credentialId []byte) (*Credential, []byte, error) {
+	row := s.db.QueryRow(s.query(`SELECT `+sqlCredentialColumns+` FROM webauthn_credentials WHERE id = ?`), encodeSQLBytes(credentialId))
+	cred, userId, err := scanSQLCredential(row)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return cred, userId, nil
+}
+
+func (s *SQLCredentialService) GetCredentialForUser(userId []byte) ([]Credential, error) {
+	rows, err := s.db.Query(s.query(`SELECT `+sqlCredentialColumns+` FROM webauthn_credentials WHERE user_id = ? ORDER BY id`), encodeSQLBytes(userId))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var credentials []Credential
+	for rows.Next() {
+		cred, _, err := scanSQLCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, *cred)
+	}
+	return credentials, rows.Err()
+}
+
+// StoreCredential inserts the credential right away, so that concurrent calls for the same ID are decided by the
+// primary key. The error of a failed insert is mapped to ErrCredentialAlreadyExists if the credential exists, as the
+// constraint errors differ between the drivers.
+func (s *SQLCredentialService) StoreCredential(userId []byte, cred *Credential) error {
+	_, err := s.db.Exec(s.query(`INSERT INTO webauthn_credentials (`+sqlCredentialColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		encodeSQLBytes(cred.ID),
+		encodeSQLBytes(userId),
+		encodeSQLBytes(cred.PublicKey),
+		cred.AttestationType,
+		cred.UserVerification,
+		encodeSQLBytes(cred.Authenticator.AAGUID),
+		int64(cred.Authenticator.SignCount),
+		cred.Name,
+		encodeSQLTime(cred.LastUsedAt),
+		strings.Join(cred.Transports, ","),
+		cred.AuthenticatorAttachment,
+		cred.BackupEligible != nil && *cred.BackupEligible,
+		cred.BackupState,
+		encodeSQLTime(cred.CreatedAt),
+		encodeSQLBytes(cred.AttestationObject),
+		cred.Discoverable,
+		cred.LargeBlobSupported,
+		cred.Quarantined,
+		cred.BackupEligible != nil,
+	)
+	if err != nil {
+		if exists, existsErr := s.ExistsCredential(cred.ID); existsErr == nil && exists {
+			return ErrCredentialAlreadyExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *SQLCredentialService) UpdateCredential(cred *Credential) error {
+	if cred.BackupEligible != nil {
+		result, err := s.db.Exec(s.query(`UPDATE webauthn_credentials SET sign_count = CASE WHEN sign_count < ? THEN ? ELSE sign_count END, last_used_at = ?, backup_state = ?, quarantined = ?, backup_eligible = ?, backup_eligible_known = ? WHERE id = ?`),
+			int64(cred.Authenticator.SignCount),
+			int64(cred.Authenticator.SignCount),
+			encodeSQLTime(cred.LastUsedAt),
+			cred.BackupState,
+			cred.Quarantined,
+			*cred.BackupEligible,
+			true,
+			encodeSQLBytes(cred.ID),
+		)
+		return requireAffectedRow(result, err)
+	}
+	result, err := s.db.Exec(s.query(`UPDATE webauthn_credentials SET sign_count = CASE WHEN sign_count < ? THEN ? ELSE sign_count END, last_used_at = ?, backup_state = ?, quarantined = ? WHERE id = ?`),
+		int64(cred.Authenticator.SignCount),
+		int64(cred.Authenticator.SignCount),
+		encodeSQLTime(cred.LastUsedAt),
+		cred.BackupState,
+		cred.Quarantined,
+		encodeSQLBytes(cred.ID),
+	)
+	return requireAffectedRow(result, err)
+}
+
+func (s *SQLCredentialService) RenameCredential(credentialId []byte, name string) error {
+	result, err := s.db.Exec(s.query(`UPDATE webauthn_credentials SET name = ? WHERE id = ?`), name, encodeSQLBytes(credentialId))
+	return requireAffectedRow(result, err)
+}
+
+func (s *SQLCredentialService) DeleteCredential(credentialId []byte) error {
+	result, err := s.db.Exec(s.query(`DELETE FROM webauthn_credentials WHERE id = ?`), encodeSQLBytes(credentialId))
+	return requireAffectedRow(result, err)
+}
+
+// query rewrites the question mark placeholders of the query to the placeholder style of the database
+func (s *SQLCredentialService) query(query string) string {
+	if s.placeholders != DollarPlaceholders {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+type sqlScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSQLCredential(row sqlScanner) (*Credential, []byte, error) {
+	var (
+		id, userId, publicKey, aaguid       string
+		transports, attestationObject       string
+		signCount, lastUsedAt, createdAt    int64
+		backupEligible, backupEligibleKnown bool
+		cred                                Credential
+	)
+	err := row.Scan(&id, &userId, &publicKey, &cred.AttestationType, &cred.UserVerification, &aaguid, &signCount, &cred.Name, &lastUsedAt,
+		&transports, &cred.AuthenticatorAttachment, &backupEligible, &cred.BackupState, &createdAt, &attestationObject, &cred.Discoverable,
+		&cred.LargeBlobSupported, &cred.Quarantined, &backupEligibleKnown)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	decodedUserId, err := decodeSQLBytes(userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cred.ID, err = decodeSQLBytes(id); err != nil {
+		return nil, nil, err
+	}
+	if cred.PublicKey, err = decodeSQLBytes(publicKey); err != nil {
+		return nil, nil, err
+	}
+	if cred.Authenticator.AAGUID, err = decodeSQLBytes(aaguid); err != nil {
+		return nil, nil, err
+	}
+	if cred.AttestationObject, err = decodeSQLBytes(attestationObject); err != nil {
+		return nil, nil, err
+	}
+	cred.Authenticator.SignCount = uint32(signCount)
+	if backupEligibleKnown {
+		cred.BackupEligible = &backupEligible
+	}
+	cred.LastUsedAt = decodeSQLTime(lastUsedAt)
+	cred.CreatedAt = decodeSQLTime(createdAt)
+	if transports != "" {
+		cred.Transports = strings.Split(transports, ",")
+	}
+
+	return &cred, decodedUserId, nil
+}
+
+func requireAffectedRow(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrCredentialNotFound
+	}
+	return nil
+}
+
+func encodeSQLBytes(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSQLBytes(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// encodeSQLTime stores the zero time as 0, because it is outside of the range of unix nanoseconds
+func encodeSQLTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func decodeSQLTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos).UTC()
+}
@@ -0,0 +1,56 @@
+package credential_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/teamhanko/webauthn-go/credential"
+	"github.com/teamhanko/webauthn-go/credential/credentialtest"
+	_ "modernc.org/sqlite"
+)
+
+func openTestDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "credentials.db"))
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	// SQLite does not support concurrent writers
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		db.Close()
+	})
+	return db
+}
+
+func TestSQLCredentialService(t *testing.T) {
+	credentialtest.RunConformanceTests(t, func(t *testing.T) credential.CredentialService {
+		service, err := credential.NewSQLCredentialService(openTestDatabase(t), credential.QuestionMarkPlaceholders)
+		if err != nil {
+			t.Fatalf("NewSQLCredentialService() error = %v", err)
+		}
+		return service
+	})
+}
+
+func TestSQLCredentialService_MigrateTwice(t *testing.T) {
+	db := openTestDatabase(t)
+	service, err := credential.NewSQLCredentialService(db, credential.QuestionMarkPlaceholders)
+	if err != nil {
+		t.Fatalf("NewSQLCredentialService() error = %v", err)
+	}
+
+	cred := credentialtest.NewTestCredential("credential-1")
+	if err := service.StoreCredential([]byte("user-1"), cred); err != nil {
+		t.Fatalf("StoreCredential() error = %v", err)
+	}
+
+	service, err = credential.NewSQLCredentialService(db, credential.QuestionMarkPlaceholders)
+	if err != nil {
+		t.Fatalf("NewSQLCredentialService() second migration error = %v", err)
+	}
+	exists, err := service.ExistsCredential(cred.ID)
+	if err != nil || !exists {
+		t.Errorf("ExistsCredential() = %v, %v, want true, nil", exists, err)
+	}
+}
@@ -78,6 +78,8 @@ func ParseCredentialRequestResponseBody(body io.Reader) (*ParsedCredentialAssert
 	}
 	var par ParsedCredentialAssertionData
 	par.ID, par.RawID, par.Type = car.ID, car.RawID, car.Type
+	par.AuthenticatorAttachment = car.AuthenticatorAttachment
+	par.Extensions = car.Extensions
 	par.Raw = car
 
 	par.Response.Signature = car.AssertionResponse.Signature
@@ -100,7 +102,30 @@ func ParseCredentialRequestResponseBody(body io.Reader) (*ParsedCredentialAssert
 // Verify follows the remaining steps outlined in §7.2 Verifying an authentication assertion
 // (https://www.w3.org/TR/webauthn-1/#verifying-assertion) and returns an error if there
 // is a failure during each step.
-func (p *ParsedCredentialAssertionData) Verify(storedChallenge string, relyingPartyID string, relyingPartyOrigins []string, verifyUser bool, credentialBytes []byte) error {
+func (p *ParsedCredentialAssertionData) Verify(storedChallenge string, relyingPartyID string, relyingPartyOrigins OriginRules, relyingPartyTopOrigins OriginRules, verifyUser bool, credentialBytes []byte) error {
+	return p.verify(AssertCeremony, storedChallenge, relyingPartyID, relyingPartyOrigins, relyingPartyTopOrigins, verifyUser, credentialBytes)
+}
+
+// VerifyPayment verifies a Secure Payment Confirmation assertion. It is verified like an authentication assertion,
+// but has the type payment.get and the client data must contain the payment details that were issued. The merchant
+// usually starts the payment on its own page, so besides the origins of the Relying Party the payee origin and the
+// top origin of the issued payment are allowed as origin and top origin.
+func (p *ParsedCredentialAssertionData) VerifyPayment(storedChallenge string, relyingPartyID string, relyingPartyOrigins OriginRules, relyingPartyTopOrigins OriginRules, verifyUser bool, credentialBytes []byte, payment *CollectedClientAdditionalPaymentData) error {
+	clientPayment := p.Response.CollectedClientData.Payment
+	if clientPayment == nil {
+		return ErrVerification.WithDetails("Error validating payment").WithInfo("Payment data missing in client data")
+	}
+	if err := clientPayment.Verify(relyingPartyID, payment); err != nil {
+		return err
+	}
+
+	merchantOrigins := payment.merchantOrigins()
+	origins := append(append(OriginRules{}, relyingPartyOrigins...), merchantOrigins...)
+	topOrigins := append(append(OriginRules{}, relyingPartyTopOrigins...), merchantOrigins...)
+	return p.verify(PaymentCeremony, storedChallenge, relyingPartyID, origins, topOrigins, verifyUser, credentialBytes)
+}
+
+func (p *ParsedCredentialAssertionData) verify(ceremony CeremonyType, storedChallenge string, relyingPartyID string, relyingPartyOrigins OriginRules, relyingPartyTopOrigins OriginRules, verifyUser bool, credentialBytes []byte) error {
 
 	// Steps 4 through 6 in verifying the assertion data (https://www.w3.org/TR/webauthn-1/#verifying-assertion) are
 	// "assertive" steps, i.e "Let JSONtext be the result of running UTF-8 decode on the value of cData."
@@ -108,7 +133,7 @@ func (p *ParsedCredentialAssertionData) Verify(storedChallenge string, relyingPa
 
 	// Handle steps 7 through 10 of assertion by verifying stored data against the Collected Client Data
 	// returned by the authenticator
-	validError := p.Response.CollectedClientData.Verify(storedChallenge, AssertCeremony, relyingPartyOrigins)
+	validError := p.Response.CollectedClientData.Verify(storedChallenge, ceremony, relyingPartyOrigins, relyingPartyTopOrigins)
 	if validError != nil {
 		return validError
 	}
@@ -119,7 +144,7 @@ func (p *ParsedCredentialAssertionData) Verify(storedChallenge string, relyingPa
 	// Handle steps 11 through 14, verifying the authenticator data.
 	validError = p.Response.AuthenticatorData.Verify(rpIDHash[:], verifyUser)
 	if validError != nil {
-		return ErrAuthData.WithInfo(validError.Error())
+		return ErrAuthData.WithInfo(validError.Error()).Wrap(validError)
 	}
 
 	// allowedUserCredentialIDs := session.AllowedCredentialIDs
@@ -35,12 +35,15 @@ type AuthenticatorAttestationResponse struct {
 	// requires to validate the attestation statement, as well as to decode and
 	// validate the authenticator data along with the JSON-serialized client data.
 	AttestationObject URLEncodedBase64 `json:"attestationObject"`
+	// The transports the authenticator is believed to support, as returned by getTransports() on the client
+	Transports []string `json:"transports,omitempty"`
 }
 
 // The parsed out version of AuthenticatorAttestationResponse.
 type ParsedAttestationResponse struct {
 	CollectedClientData CollectedClientData
 	AttestationObject   AttestationObject
+	Transports          []AuthenticatorTransport
 }
 
 // From §6.4. Authenticators MUST also provide some form of attestation. The basic requirement is that the
@@ -102,11 +105,22 @@ func (ccr *AuthenticatorAttestationResponse) Parse() (*ParsedAttestationResponse
 		return nil, ErrAttestationFormat.WithInfo("Attestation missing attested credential data flag")
 	}
 
+	for _, transport := range ccr.Transports {
+		p.Transports = append(p.Transports, AuthenticatorTransport(transport))
+	}
+
 	return &p, nil
 }
 
 // Verify - Perform Steps 9 through 14 of registration verification, delegating Steps
 func (attestationObject *AttestationObject) Verify(relyingPartyID string, clientDataHash []byte, verificationRequired bool) error {
+	_, _, err := attestationObject.verify(relyingPartyID, clientDataHash, verificationRequired)
+	return err
+}
+
+// verify performs the steps of Verify and returns the attestation type and the trust path (the x5c certificate chain)
+// reported by the verification procedure of the attestation statement format
+func (attestationObject *AttestationObject) verify(relyingPartyID string, clientDataHash []byte, verificationRequired bool) (string, []interface{}, error) {
 	// Steps 9 through 12 are verified against the auth data.
 	// These steps are identical to 11 through 14 for assertion
 	// so we handle them with AuthData
@@ -117,7 +131,7 @@ func (attestationObject *AttestationObject) Verify(relyingPartyID string, client
 	// Handle Steps 9 through 12
 	authDataVerificationError := attestationObject.AuthData.Verify(rpIDHash[:], verificationRequired)
 	if authDataVerificationError != nil {
-		return authDataVerificationError
+		return "", nil, authDataVerificationError
 	}
 
 	// Step 13. Determine the attestation statement format by performing a
@@ -134,30 +148,30 @@ func (attestationObject *AttestationObject) Verify(relyingPartyID string, client
 	// any of the following steps
 	if attestationObject.Format == "none" {
 		if len(attestationObject.AttStatement) != 0 {
-			return ErrAttestationFormat.WithInfo("Attestation format none with attestation present")
+			return "", nil, ErrAttestationFormat.WithInfo("Attestation format none with attestation present")
 		}
-		return nil
+		return "", nil, nil
 	}
 
 	formatHandler, valid := attestationRegistry[attestationObject.Format]
 	if !valid {
-		return ErrAttestationFormat.WithInfo(fmt.Sprintf("Attestation format %s is unsupported", attestationObject.Format))
+		return "", nil, ErrAttestationFormat.WithInfo(fmt.Sprintf("Attestation format %s is unsupported", attestationObject.Format))
 	}
 
 	// Step 14. Verify that attStmt is a correct attestation statement, conveying a valid attestation signature, by using
 	// the attestation statement format fmt’s verification procedure given attStmt, authData and the hash of the serialized
 	// client data computed in step 7.
-	attestationType, _, err := formatHandler(*attestationObject, clientDataHash)
+	attestationType, trustPath, err := formatHandler(*attestationObject, clientDataHash)
 	if err != nil {
 		switch err.(type) {
 		case *Error:
-			return err.(*Error).WithInfo(attestationType)
+			return "", nil, err.(*Error).WithInfo(attestationType)
 		case *webauthncose.Error:
-			return err
+			return "", nil, err
 		default:
-			return ErrAttestation.WithInfo(err.Error())
+			return "", nil, ErrAttestation.WithInfo(err.Error())
 		}
 	}
 
-	return nil
+	return attestationType, trustPath, nil
 }
@@ -30,10 +30,16 @@ func TestAttestationVerify(t *testing.T) {
 			pcc.Response = *parsedAttestationResponse
 
 			// Test Base Verification
-			err = pcc.Verify(options.Response.Challenge.String(), false, options.Response.RelyingParty.ID, []string{options.Response.RelyingParty.Name}, nil, nil, nil)
+			result, err := pcc.Verify(options.Response.Challenge.String(), false, options.Response.RelyingParty.ID, testOriginRules(options.Response.RelyingParty.Name), nil, nil, nil, nil)
 			if err != nil {
 				t.Fatalf("Not valid: %+v (%+s)", err, err.(*Error).DevInfo)
 			}
+			if result.Format != pcc.Response.AttestationObject.Format {
+				t.Errorf("AttestationResult.Format = %s, want %s", result.Format, pcc.Response.AttestationObject.Format)
+			}
+			if x5c, ok := pcc.Response.AttestationObject.AttStatement["x5c"].([]interface{}); ok && len(result.TrustPath) != len(x5c) {
+				t.Errorf("AttestationResult.TrustPath has %d certificates, want %d", len(result.TrustPath), len(x5c))
+			}
 		})
 	}
 }
@@ -85,7 +91,7 @@ var testAttestationOptions = []string{
 	`{"publicKey": {
 		"challenge": "rWiex8xDOPfiCgyFu4BLW6vVOmXKgPwHrlMCgEs9SBA",
 		"rp": {
-		"name": "http://localhost",
+		"name": "http://localhost:9005",
 		"id": "localhost"
 		},
 		"user": {
@@ -84,6 +84,9 @@ const (
 	BLE AuthenticatorTransport = "ble"
 	// Internal the client should use an internal source like a TPM or SE
 	Internal AuthenticatorTransport = "internal"
+	// Hybrid the authenticator is reached through a combination of data transport and proximity mechanisms, e.g. a
+	// phone which is used to sign in on a desktop
+	Hybrid AuthenticatorTransport = "hybrid"
 )
 
 // UserVerificationRequirement represents the UserVerfication string.
@@ -115,9 +118,13 @@ const (
 	// FlagUserVerified Bit 00000100 in the byte sequence. Tells us if user is verified
 	// by the authenticator using a biometric or PIN
 	FlagUserVerified // Referred to as UV
-	_                // Reserved
-	_                // Reserved
-	_                // Reserved
+	// FlagBackupEligible Bit 00001000 in the byte sequence. Indicates whether the credential
+	// can be backed up, e.g. synced to other devices of the user.
+	FlagBackupEligible // Referred to as BE
+	// FlagBackupState Bit 00010000 in the byte sequence. Indicates whether the credential
+	// is currently backed up.
+	FlagBackupState // Referred to as BS
+	_               // Reserved
 	// FlagAttestedCredentialData Bit 01000000 in the byte sequence. Indicates whether
 	// the authenticator added attested credential data.
 	FlagAttestedCredentialData // Referred to as AT
@@ -130,6 +137,16 @@ func (flag AuthenticatorFlags) UserPresent() bool {
 	return (flag & FlagUserPresent) == FlagUserPresent
 }
 
+// BackupEligible returns if the BE flag was set
+func (flag AuthenticatorFlags) BackupEligible() bool {
+	return (flag & FlagBackupEligible) == FlagBackupEligible
+}
+
+// BackupState returns if the BS flag was set
+func (flag AuthenticatorFlags) BackupState() bool {
+	return (flag & FlagBackupState) == FlagBackupState
+}
+
 // UserVerified returns if the UV flag was set
 func (flag AuthenticatorFlags) UserVerified() bool {
 	return (flag & FlagUserVerified) == FlagUserVerified
@@ -243,20 +260,26 @@ func (a *AuthenticatorData) Verify(rpIdHash []byte, userVerificationRequired boo
 	// Verify that the RP ID hash in authData is indeed the SHA-256
 	// hash of the RP ID expected by the RP.
 	if !bytes.Equal(a.RPIDHash[:], rpIdHash) {
-		return ErrVerification.WithInfo(fmt.Sprintf("RP Hash mismatch. Expected %+s and Received %+s\n", a.RPIDHash, rpIdHash))
+		return ErrRPIDHashMismatch.WithInfo(fmt.Sprintf("RP Hash mismatch. Expected %+s and Received %+s\n", a.RPIDHash, rpIdHash))
 	}
 
 	// Registration Step 10 & Assertion Step 12
 	// Verify that the User Present bit of the flags in authData is set.
 	if userVerificationRequired && !a.Flags.UserPresent() {
-		return ErrVerification.WithInfo(fmt.Sprintln("User presence flag not set by authenticator"))
+		return ErrUserPresenceMissing.WithInfo(fmt.Sprintln("User presence flag not set by authenticator"))
 	}
 
 	// Registration Step 11 & Assertion Step 13
 	// If user verification is required for this assertion, verify that
 	// the User Verified bit of the flags in authData is set.
 	if userVerificationRequired && !a.Flags.UserVerified() {
-		return ErrVerification.WithInfo(fmt.Sprintln("User verification required but flag not set by authenticator"))
+		return ErrUserVerificationMissing.WithInfo(fmt.Sprintln("User verification required but flag not set by authenticator"))
+	}
+
+	// The backup state of a credential can only be set if the credential is backup eligible,
+	// see §6.1.3. Credential Backup State https://www.w3.org/TR/webauthn-3/#sctn-credential-backup
+	if a.Flags.BackupState() && !a.Flags.BackupEligible() {
+		return ErrInvalidBackupState.WithInfo("Backup state flag set by authenticator without backup eligible flag")
 	}
 
 	// Registration Step 12 & Assertion Step 14
@@ -269,7 +292,7 @@ func (a *AuthenticatorData) Verify(rpIdHash []byte, userVerificationRequired boo
 	// extensions are present that were not requested. In the general case, the meaning
 	// of "are as expected" is specific to the Relying Party and which extensions are in use.
 
-	// This is not yet fully implemented by the spec or by browsers
+	// This is done by VerifyExtensions, because it needs the extension inputs of the request
 
 	return nil
 }
@@ -1,6 +1,7 @@
 package protocol
 
 import (
+	"bytes"
 	"encoding/base64"
 	"reflect"
 	"testing"
@@ -62,6 +63,62 @@ func TestAuthenticatorFlags_UserVerified(t *testing.T) {
 	}
 }
 
+func TestAuthenticatorFlags_BackupEligible(t *testing.T) {
+	var goodByte byte = 0x08
+	var badByte byte = 0x01
+	tests := []struct {
+		name string
+		flag AuthenticatorFlags
+		want bool
+	}{
+		{
+			"Present",
+			AuthenticatorFlags(goodByte),
+			true,
+		},
+		{
+			"Missing",
+			AuthenticatorFlags(badByte),
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.flag.BackupEligible(); got != tt.want {
+				t.Errorf("AuthenticatorFlags.BackupEligible() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorFlags_BackupState(t *testing.T) {
+	var goodByte byte = 0x10
+	var badByte byte = 0x01
+	tests := []struct {
+		name string
+		flag AuthenticatorFlags
+		want bool
+	}{
+		{
+			"Present",
+			AuthenticatorFlags(goodByte),
+			true,
+		},
+		{
+			"Missing",
+			AuthenticatorFlags(badByte),
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.flag.BackupState(); got != tt.want {
+				t.Errorf("AuthenticatorFlags.BackupState() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestAuthenticatorFlags_HasAttestedCredentialData(t *testing.T) {
 	var goodByte byte = 0x40
 	var badByte byte = 0x01
@@ -225,6 +282,7 @@ func Test_unmarshalCredentialPublicKey(t *testing.T) {
 }
 
 func TestAuthenticatorData_Verify(t *testing.T) {
+	rpIdHash := bytes.Repeat([]byte{0x01}, 32)
 	type fields struct {
 		RPIDHash []byte
 		Flags    AuthenticatorFlags
@@ -242,7 +300,36 @@ func TestAuthenticatorData_Verify(t *testing.T) {
 		args    args
 		wantErr bool
 	}{
-		// TODO: Add test cases.
+		{
+			"Valid",
+			fields{RPIDHash: rpIdHash, Flags: FlagUserPresent | FlagUserVerified},
+			args{rpIdHash, true},
+			false,
+		},
+		{
+			"RP ID hash mismatch",
+			fields{RPIDHash: make([]byte, 32), Flags: FlagUserPresent},
+			args{rpIdHash, false},
+			true,
+		},
+		{
+			"User verification missing",
+			fields{RPIDHash: rpIdHash, Flags: FlagUserPresent},
+			args{rpIdHash, true},
+			true,
+		},
+		{
+			"Backup eligible and backed up",
+			fields{RPIDHash: rpIdHash, Flags: FlagUserPresent | FlagBackupEligible | FlagBackupState},
+			args{rpIdHash, false},
+			false,
+		},
+		{
+			"Backup state without backup eligible",
+			fields{RPIDHash: rpIdHash, Flags: FlagUserPresent | FlagBackupState},
+			args{rpIdHash, false},
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -16,12 +16,19 @@ type CollectedClientData struct {
 	// and "webauthn.get" when getting an assertion from an existing credential. The
 	// purpose of this member is to prevent certain types of signature confusion attacks
 	//(where an attacker substitutes one legitimate signature for another).
-	Type         CeremonyType  `json:"type"`
-	Challenge    string        `json:"challenge"`
-	Origin       string        `json:"origin"`
+	Type      CeremonyType `json:"type"`
+	Challenge string       `json:"challenge"`
+	Origin    string       `json:"origin"`
+	// CrossOrigin is true if the ceremony was made from an iframe which is not same-origin with its ancestors
+	CrossOrigin bool `json:"crossOrigin,omitempty"`
+	// TopOrigin is the origin of the top-level document of a cross-origin ceremony
+	TopOrigin    string        `json:"topOrigin,omitempty"`
 	TokenBinding *TokenBinding `json:"tokenBinding,omitempty"`
 	// Chromium (Chrome) returns a hint sometimes about how to handle clientDataJSON in a safe manner
 	Hint string `json:"new_keys_may_be_added_here,omitempty"`
+	// Payment contains the payment details the user confirmed in a Secure Payment Confirmation, it is only present
+	// for the payment.get ceremony
+	Payment *CollectedClientAdditionalPaymentData `json:"payment,omitempty"`
 }
 
 type CeremonyType string
@@ -29,6 +36,9 @@ type CeremonyType string
 const (
 	CreateCeremony CeremonyType = "webauthn.create"
 	AssertCeremony CeremonyType = "webauthn.get"
+	// PaymentCeremony is the type of a Secure Payment Confirmation, which is an assertion made by the browser on
+	// behalf of a merchant (https://www.w3.org/TR/secure-payment-confirmation/)
+	PaymentCeremony CeremonyType = "payment.get"
 )
 
 type TokenBinding struct {
@@ -59,13 +69,15 @@ func FullyQualifiedOrigin(u *url.URL) string {
 // new credential and steps 7 through 10 of verifying an authentication assertion
 // See https://www.w3.org/TR/webauthn-1/#registering-a-new-credential
 // and https://www.w3.org/TR/webauthn-1/#verifying-assertion
-func (c *CollectedClientData) Verify(storedChallenge string, ceremony CeremonyType, relyingPartyOrigins []string) error {
+// The relyingPartyOrigins include the related origins of the RP ID. A cross-origin ceremony is only accepted if its top
+// origin matches the relyingPartyTopOrigins.
+func (c *CollectedClientData) Verify(storedChallenge string, ceremony CeremonyType, relyingPartyOrigins OriginRules, relyingPartyTopOrigins OriginRules) error {
 
 	// Registration Step 3. Verify that the value of C.type is webauthn.create.
 
 	// Assertion Step 7. Verify that the value of C.type is the string webauthn.get.
 	if c.Type != ceremony {
-		err := ErrVerification.WithDetails("Error validating ceremony type")
+		err := ErrCeremonyMismatch.WithDetails("Error validating ceremony type")
 		err.WithInfo(fmt.Sprintf("Expected Value: %s\n Received: %s\n", ceremony, c.Type))
 		return err
 	}
@@ -74,7 +86,7 @@ func (c *CollectedClientData) Verify(storedChallenge string, ceremony CeremonyTy
 	// NOT-NORMATIVE
 	// Verify that C.challenge is not an empty string
 	if challenge == "" {
-		err := ErrVerification.WithDetails("Error validating challenge")
+		err := ErrChallengeMismatch.WithDetails("Error validating challenge")
 		return err.WithInfo("Expected CollectedClientData.Challenge not be an empty string")
 	}
 
@@ -82,7 +94,7 @@ func (c *CollectedClientData) Verify(storedChallenge string, ceremony CeremonyTy
 	// Verify that C.challenge is base64url encoded
 	_, base64Err := base64.RawURLEncoding.DecodeString(challenge)
 	if base64Err != nil {
-		err := ErrVerification.WithDetails("Error validating challenge")
+		err := ErrChallengeMismatch.WithDetails("Error validating challenge")
 		return err.WithInfo("Expected CollectedClientData.Challenge to be base64url encoded")
 	}
 
@@ -94,31 +106,30 @@ func (c *CollectedClientData) Verify(storedChallenge string, ceremony CeremonyTy
 	// passed to the get() call.
 
 	if 0 != strings.Compare(storedChallenge, challenge) {
-		err := ErrVerification.WithDetails("Error validating challenge")
+		err := ErrChallengeMismatch.WithDetails("Error validating challenge")
 		return err.WithInfo(fmt.Sprintf("Expected b Value: %#v\nReceived b: %#v\n", storedChallenge, challenge))
 	}
 
 	// Registration Step 5 & Assertion Step 9. Verify that the value of C.origin matches
 	// the Relying Party's origin.
-	clientDataOrigin, err := url.Parse(c.Origin)
-	if err != nil {
+	if _, err := url.Parse(c.Origin); err != nil {
 		return ErrParsingData.WithDetails("Error decoding clientData origin as URL")
 	}
-
-	foundOriginMatch := false
-	fullyQualifiedClientDataOrigin := c.Origin
-	if clientDataOrigin.Scheme == "https" || clientDataOrigin.Scheme == "http" {
-		fullyQualifiedClientDataOrigin = FullyQualifiedOrigin(clientDataOrigin)
-	}
-	for _, origin := range relyingPartyOrigins {
-		if strings.EqualFold(fullyQualifiedClientDataOrigin, origin) {
-			foundOriginMatch = true
-		}
+	if err := relyingPartyOrigins.Match(c.Origin); err != nil {
+		return ErrOriginMismatch.WithDetails("Error validating origin").WithInfo(err.Error())
 	}
 
-	if !foundOriginMatch {
-		err := ErrVerification.WithDetails("Error validating origin")
-		return err.WithInfo(fmt.Sprintf("Expected Value list: %q\n Received: %s\n", relyingPartyOrigins, FullyQualifiedOrigin(clientDataOrigin)))
+	// Registration Step 9 and Assertion Step 13 of WebAuthn Level 3. If C.topOrigin is present, verify that the
+	// Relying Party expects this credential to be used within an iframe that is not same-origin with its ancestors
+	// and that C.topOrigin matches the origin of a page that the Relying Party expects to be sub-framed within.
+	if c.CrossOrigin || c.TopOrigin != "" {
+		if c.TopOrigin == "" {
+			err := ErrTopOriginMismatch.WithDetails("Error validating top origin")
+			return err.WithInfo("Cross-origin ceremony without topOrigin")
+		}
+		if err := relyingPartyTopOrigins.Match(c.TopOrigin); err != nil {
+			return ErrTopOriginMismatch.WithDetails("Error validating top origin").WithInfo(err.Error())
+		}
 	}
 
 	// Registration Step 6 and Assertion Step 10. Verify that the value of C.tokenBinding.status
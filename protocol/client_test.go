@@ -26,7 +26,7 @@ func TestVerifyCollectedClientData(t *testing.T) {
 	var storedChallenge = newChallenge
 
 	originURL, _ := url.Parse(ccd.Origin)
-	err = ccd.Verify(storedChallenge.String(), ccd.Type, []string{FullyQualifiedOrigin(originURL)})
+	err = ccd.Verify(storedChallenge.String(), ccd.Type, testOriginRules(FullyQualifiedOrigin(originURL)), nil)
 	if err != nil {
 		t.Fatalf("error verifying challenge: expected %#v got %#v", Challenge(ccd.Challenge), storedChallenge)
 	}
@@ -43,8 +43,44 @@ func TestVerifyCollectedClientDataIncorrectChallenge(t *testing.T) {
 		t.Fatalf("error creating challenge: %s", err)
 	}
 	storedChallenge := Challenge(bogusChallenge)
-	err = ccd.Verify(storedChallenge.String(), ccd.Type, []string{ccd.Origin})
+	err = ccd.Verify(storedChallenge.String(), ccd.Type, testOriginRules(ccd.Origin), nil)
 	if err == nil {
 		t.Fatalf("error expected but not received. expected %#v got %#v", Challenge(ccd.Challenge), storedChallenge)
 	}
 }
+
+func TestVerifyCollectedClientDataTopOrigin(t *testing.T) {
+	newChallenge, err := CreateChallenge()
+	if err != nil {
+		t.Fatalf("error creating challenge: %s", err)
+	}
+	topOrigins := []string{"https://partner.example"}
+
+	tests := []struct {
+		name        string
+		crossOrigin bool
+		topOrigin   string
+		topOrigins  []string
+		wantErr     bool
+	}{
+		{"Same origin", false, "", nil, false},
+		{"Allowed top origin", true, "https://partner.example", topOrigins, false},
+		{"Allowed top origin with port", true, "https://partner.example:443", topOrigins, false},
+		{"Unknown top origin", true, "https://evil.example", topOrigins, true},
+		{"No top origins allowed", true, "https://partner.example", nil, true},
+		{"Cross origin without top origin", true, "", topOrigins, true},
+		{"Top origin without cross origin", false, "https://evil.example", topOrigins, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ccd := setupCollectedClientData(newChallenge)
+			ccd.CrossOrigin = tt.crossOrigin
+			ccd.TopOrigin = tt.topOrigin
+
+			err := ccd.Verify(Challenge(newChallenge).String(), ccd.Type, testOriginRules(ccd.Origin), testOriginRules(tt.topOrigins...))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
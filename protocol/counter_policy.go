@@ -0,0 +1,70 @@
+package protocol
+
+import "github.com/teamhanko/webauthn-go/credential"
+
+// CounterAction is the decision of a CounterPolicy about a login whose signature counter did not increase
+type CounterAction int
+
+const (
+	// CounterAccept lets the login succeed
+	CounterAccept CounterAction = iota
+	// CounterFlag lets the login succeed, but warns that the authenticator may be cloned
+	CounterFlag
+	// CounterReject fails the login
+	CounterReject
+	// CounterQuarantine fails the login and quarantines the credential, so it can not be used until it is released
+	CounterQuarantine
+)
+
+// CounterPolicy decides what happens if the signature counter of an assertion is not greater than the stored
+// counter, which is a signal that the authenticator may be cloned or is malfunctioning.
+// See Step 17 of §7.2. https://www.w3.org/TR/webauthn-2/#sctn-verifying-assertion
+type CounterPolicy interface {
+	VerifyCounter(storedCount, signCount uint32) CounterAction
+}
+
+// CheckCounter compares the signature counter of the assertion with the counter stored for the authenticator. The
+// policy is only asked if the counter did not increase and is not zero on both sides, because authenticators
+// without a counter always return zero. A nil policy rejects the login.
+func CheckCounter(policy CounterPolicy, authenticator *credential.Authenticator, signCount uint32) CounterAction {
+	if authenticator.CheckCounter(signCount) == nil {
+		return CounterAccept
+	}
+	if policy == nil {
+		return CounterReject
+	}
+	return policy.VerifyCounter(authenticator.SignCount, signCount)
+}
+
+// RejectCounterPolicy fails every login whose counter did not increase. This is the default.
+type RejectCounterPolicy struct{}
+
+func (RejectCounterPolicy) VerifyCounter(storedCount, signCount uint32) CounterAction {
+	return CounterReject
+}
+
+// FlagCounterPolicy lets every login succeed, but flags the logins whose counter did not increase, so that they can
+// be reviewed
+type FlagCounterPolicy struct{}
+
+func (FlagCounterPolicy) VerifyCounter(storedCount, signCount uint32) CounterAction {
+	return CounterFlag
+}
+
+// AllowZeroCounterPolicy accepts logins with a counter of zero, which some platform authenticators return for
+// synced credentials although they returned a counter before. All other logins whose counter did not increase fail.
+type AllowZeroCounterPolicy struct{}
+
+func (AllowZeroCounterPolicy) VerifyCounter(storedCount, signCount uint32) CounterAction {
+	if signCount == 0 {
+		return CounterAccept
+	}
+	return CounterReject
+}
+
+// QuarantineCounterPolicy fails the login and quarantines the credential if its counter did not increase
+type QuarantineCounterPolicy struct{}
+
+func (QuarantineCounterPolicy) VerifyCounter(storedCount, signCount uint32) CounterAction {
+	return CounterQuarantine
+}
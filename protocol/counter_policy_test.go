@@ -0,0 +1,35 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/teamhanko/webauthn-go/credential"
+)
+
+func TestCheckCounter(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      CounterPolicy
+		storedCount uint32
+		signCount   uint32
+		want        CounterAction
+	}{
+		{"Increased", nil, 5, 6, CounterAccept},
+		{"No counter", nil, 0, 0, CounterAccept},
+		{"Default policy", nil, 5, 5, CounterReject},
+		{"Reject", RejectCounterPolicy{}, 5, 4, CounterReject},
+		{"Flag", FlagCounterPolicy{}, 5, 4, CounterFlag},
+		{"Flag increased", FlagCounterPolicy{}, 5, 6, CounterAccept},
+		{"Allow zero", AllowZeroCounterPolicy{}, 5, 0, CounterAccept},
+		{"Allow zero with lower counter", AllowZeroCounterPolicy{}, 5, 4, CounterReject},
+		{"Quarantine", QuarantineCounterPolicy{}, 5, 5, CounterQuarantine},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := &credential.Authenticator{SignCount: tt.storedCount}
+			if got := CheckCounter(tt.policy, authenticator, tt.signCount); got != tt.want {
+				t.Errorf("CheckCounter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
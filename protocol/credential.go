@@ -43,12 +43,16 @@ type PublicKeyCredential struct {
 	Credential
 	RawID      URLEncodedBase64                      `json:"rawId"`
 	Extensions AuthenticationExtensionsClientOutputs `json:"extensions,omitempty"`
+	// The attachment of the authenticator which created the credential, as reported by the client
+	AuthenticatorAttachment AuthenticatorAttachment `json:"authenticatorAttachment,omitempty"`
 }
 
 type ParsedPublicKeyCredential struct {
 	ParsedCredential
 	RawID      []byte                                `json:"rawId"`
 	Extensions AuthenticationExtensionsClientOutputs `json:"extensions,omitempty"`
+	// The attachment of the authenticator which created the credential, as reported by the client
+	AuthenticatorAttachment AuthenticatorAttachment `json:"authenticatorAttachment,omitempty"`
 }
 
 type CredentialCreationResponse struct {
@@ -62,6 +66,21 @@ type ParsedCredentialCreationData struct {
 	Raw      CredentialCreationResponse
 }
 
+// AttestationResult contains what was learned about the authenticator while verifying the attestation of a new
+// credential
+type AttestationResult struct {
+	// The attestation statement format, e.g. packed or none
+	Format string
+	// The attestation type reported by the verification procedure of the format, empty for the none format
+	Type string
+	// The attestation certificate followed by the certificates it chains up to, as contained in x5c. Empty if the
+	// format does not use a certificate chain, e.g. for self attestation.
+	TrustPath []*x509.Certificate
+	// The metadata statement of the authenticator, nil if no MetadataService was given or it has no statement for
+	// the authenticator
+	MetadataStatement *metadata.MetadataStatement
+}
+
 func ParseCredentialCreationResponse(response *http.Request) (*ParsedCredentialCreationData, error) {
 	if response == nil || response.Body == nil {
 		return nil, ErrBadRequest.WithDetails("No response given")
@@ -95,6 +114,8 @@ func ParseCredentialCreationResponseBody(body io.Reader) (*ParsedCredentialCreat
 
 	var pcc ParsedCredentialCreationData
 	pcc.ID, pcc.RawID, pcc.Type = ccr.ID, ccr.RawID, ccr.Type
+	pcc.AuthenticatorAttachment = ccr.AuthenticatorAttachment
+	pcc.Extensions = ccr.Extensions
 	pcc.Raw = ccr
 
 	parsedAttestationResponse, err := ccr.AttestationResponse.Parse()
@@ -109,12 +130,12 @@ func ParseCredentialCreationResponseBody(body io.Reader) (*ParsedCredentialCreat
 
 // Verifies the Client and Attestation data as laid out by §7.1. Registering a new credential
 // https://www.w3.org/TR/webauthn-1/#registering-a-new-credential
-func (pcc *ParsedCredentialCreationData) Verify(storedChallenge string, verifyUser bool, relyingPartyID string, relyingPartyOrigins []string, metadataService metadata.MetadataService, credentialStore credential.CredentialService, rpPolicy RelyingPartyPolicy) error {
+func (pcc *ParsedCredentialCreationData) Verify(storedChallenge string, verifyUser bool, relyingPartyID string, relyingPartyOrigins OriginRules, relyingPartyTopOrigins OriginRules, metadataService metadata.MetadataService, credentialStore credential.CredentialService, rpPolicy RelyingPartyPolicy) (*AttestationResult, error) {
 
 	// Handles steps 3 through 6 - Verifying the Client Data against the Relying Party's stored data
-	verifyError := pcc.Response.CollectedClientData.Verify(storedChallenge, CreateCeremony, relyingPartyOrigins)
+	verifyError := pcc.Response.CollectedClientData.Verify(storedChallenge, CreateCeremony, relyingPartyOrigins, relyingPartyTopOrigins)
 	if verifyError != nil {
-		return verifyError
+		return nil, verifyError
 	}
 
 	// Step 7. Compute the hash of response.clientDataJSON using SHA-256.
@@ -128,9 +149,14 @@ func (pcc *ParsedCredentialCreationData) Verify(storedChallenge string, verifyUs
 
 	// We do the above step while parsing and decoding the CredentialCreationResponse
 	// Handle steps 9 through 14 - This verifies the attestaion object and
-	verifyError = pcc.Response.AttestationObject.Verify(relyingPartyID, clientDataHash[:], verifyUser)
+	attestationType, trustPath, verifyError := pcc.Response.AttestationObject.verify(relyingPartyID, clientDataHash[:], verifyUser)
 	if verifyError != nil {
-		return verifyError
+		return nil, verifyError
+	}
+	result := &AttestationResult{
+		Format:    pcc.Response.AttestationObject.Format,
+		Type:      attestationType,
+		TrustPath: parseTrustPath(trustPath),
 	}
 
 	// Step 15. If validation is successful, obtain a list of acceptable trust anchors (attestation root
@@ -144,6 +170,7 @@ func (pcc *ParsedCredentialCreationData) Verify(storedChallenge string, verifyUs
 	var metadataStatement *metadata.MetadataStatement
 	if metadataService != nil {
 		metadataStatement = GetMetadataStatement(pcc, metadataService)
+		result.MetadataStatement = metadataStatement
 		// TODO: When Apple send the right AAGUID, and authenticator is in metadata service, then remove check if format is `apple`
 		if metadataStatement == nil && pcc.Response.AttestationObject.Format != "none" && pcc.Response.AttestationObject.Format != "apple" {
 			attestationTrustworthinessError = ErrMetadataNotFound
@@ -187,7 +214,7 @@ func (pcc *ParsedCredentialCreationData) Verify(storedChallenge string, verifyUs
 			}
 
 			if attestationTrustworthinessError != nil {
-				return attestationTrustworthinessError
+				return nil, ErrUntrustedAttestation.WithDetails("Attestation is not trustworthy").Wrap(attestationTrustworthinessError)
 			}
 		}
 	}
@@ -199,10 +226,10 @@ func (pcc *ParsedCredentialCreationData) Verify(storedChallenge string, verifyUs
 	if credentialStore != nil {
 		cred, err := credentialStore.ExistsCredential(pcc.Response.AttestationObject.AuthData.AttData.CredentialID)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if cred {
-			return ErrCredentialAlreadyExists
+			return nil, ErrCredentialAlreadyExists
 		}
 	}
 
@@ -211,7 +238,7 @@ func (pcc *ParsedCredentialCreationData) Verify(storedChallenge string, verifyUs
 	// associating it with the credentialId and credentialPublicKey in the attestedCredentialData in authData, as
 	// appropriate for the Relying Party's system.
 
-	// Can't be done here, because the library has no database access
+	// This is done by the caller after the verification succeeded, see webauthn.CreateCredential
 
 	// Step 19. If the attestation statement attStmt successfully verified but is not trustworthy per step 16 above,
 	// the Relying Party SHOULD fail the registration ceremony.
@@ -219,15 +246,33 @@ func (pcc *ParsedCredentialCreationData) Verify(storedChallenge string, verifyUs
 	if rpPolicy != nil {
 		policyError := rpPolicy.Verify(pcc, attestationTrustworthinessError, metadataStatement)
 		if policyError != nil {
-			return policyError
+			return nil, policyError
 		}
 	} else {
 		if attestationTrustworthinessError != nil {
-			return attestationTrustworthinessError
+			return nil, ErrUntrustedAttestation.WithDetails("Attestation is not trustworthy").Wrap(attestationTrustworthinessError)
 		}
 	}
 
-	return nil
+	return result, nil
+}
+
+// parseTrustPath parses the certificates of the x5c chain returned by an attestation format, entries which are no valid
+// certificates are skipped as they were already checked by the format
+func parseTrustPath(x5c []interface{}) []*x509.Certificate {
+	var certificates []*x509.Certificate
+	for _, c := range x5c {
+		certBytes, ok := c.([]byte)
+		if !ok {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(certBytes)
+		if err != nil {
+			continue
+		}
+		certificates = append(certificates, certificate)
+	}
+	return certificates
 }
 
 func (pcc *ParsedCredentialCreationData) isBasicOrAttCaAttestation() bool {
@@ -352,7 +352,7 @@ func TestParsedCredentialCreationData_Verify(t *testing.T) {
 				Response:                  tt.fields.Response,
 				Raw:                       tt.fields.Raw,
 			}
-			if err := pcc.Verify(tt.args.storedChallenge.String(), tt.args.verifyUser, tt.args.relyingPartyID, tt.args.relyingPartyOrigin, nil, tt.args.credentialStore, nil); (err != nil) != tt.wantErr {
+			if _, err := pcc.Verify(tt.args.storedChallenge.String(), tt.args.verifyUser, tt.args.relyingPartyID, testOriginRules(tt.args.relyingPartyOrigin...), nil, nil, tt.args.credentialStore, nil); (err != nil) != tt.wantErr {
 				t.Errorf("ParsedCredentialCreationData.Verify() error = %+v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -641,7 +641,7 @@ func TestParsedCredentialCreationData_Verify_With_Metadata(t *testing.T) {
 				Response:                  tt.fields.Response,
 				Raw:                       tt.fields.Raw,
 			}
-			if err := pcc.Verify(tt.args.storedChallenge.String(), tt.args.verifyUser, tt.args.relyingPartyID, tt.args.relyingPartyOrigin, tt.args.metadataService, nil, nil); (err != nil) != tt.wantErr {
+			if _, err := pcc.Verify(tt.args.storedChallenge.String(), tt.args.verifyUser, tt.args.relyingPartyID, testOriginRules(tt.args.relyingPartyOrigin...), nil, tt.args.metadataService, nil, nil); (err != nil) != tt.wantErr {
 				t.Errorf("ParsedCredentialCreationData.Verify() error = %+v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -788,6 +788,22 @@ func (store *testCredentialStore) GetCredentialForUser(userId []byte) ([]credent
 	return nil, nil
 }
 
+func (store *testCredentialStore) StoreCredential(userId []byte, cred *credential.Credential) error {
+	return nil
+}
+
+func (store *testCredentialStore) UpdateCredential(cred *credential.Credential) error {
+	return nil
+}
+
+func (store *testCredentialStore) RenameCredential(credentialId []byte, name string) error {
+	return nil
+}
+
+func (store *testCredentialStore) DeleteCredential(credentialId []byte) error {
+	return nil
+}
+
 func (store *testCredentialStore) ExistsCredential(credentialId []byte) (bool, error) {
 	storedId, _ := base64.RawURLEncoding.DecodeString("6xrtBhJQW6QU4tOaB4rrHaS2Ks0yDDL_q8jDC16DEjZ-VLVf4kCRkvl2xp2D71sTPYns-exsHQHTy3G-zJRK8g")
 	if bytes.Equal(credentialId, storedId) {
@@ -1,23 +1,51 @@
 package protocol
 
+import "net/http"
+
 type Error struct {
 	// Short name for the type of error that has occurred
 	Type string `json:"type"`
+	// Code identifies the verification step which failed, it is empty for errors which are not tied to a step
+	Code ErrorCode `json:"code,omitempty"`
 	// Additional details about the error
 	Details string `json:"error"`
 	// Information to help debug the error
 	DevInfo string `json:"debug"`
+	// Err is the cause of the error, if it was caused by another error
+	Err error `json:"-"`
+
+	// parent is the error this error was derived from with one of the With methods or Wrap
+	parent *Error
 }
 
+// ErrorCode identifies the step of a ceremony which failed. The codes are stable, so callers can tell e.g. a
+// challenge mismatch from an origin mismatch without matching the details of an error.
+type ErrorCode string
+
+const (
+	CodeCeremonyMismatch         ErrorCode = "ceremony_mismatch"
+	CodeChallengeMismatch        ErrorCode = "challenge_mismatch"
+	CodeOriginMismatch           ErrorCode = "origin_mismatch"
+	CodeTopOriginMismatch        ErrorCode = "top_origin_mismatch"
+	CodeRPIDHashMismatch         ErrorCode = "rp_id_hash_mismatch"
+	CodeUserPresenceMissing      ErrorCode = "user_presence_missing"
+	CodeUserVerificationMissing  ErrorCode = "user_verification_missing"
+	CodeInvalidBackupState       ErrorCode = "invalid_backup_state"
+	CodeBackupEligibilityChanged ErrorCode = "backup_eligibility_changed"
+	CodeCredentialNotAllowed     ErrorCode = "credential_not_allowed"
+	CodeUserMismatch             ErrorCode = "user_mismatch"
+	CodeInvalidSignature         ErrorCode = "invalid_signature"
+	CodeCounterRegression        ErrorCode = "counter_regression"
+	CodeUntrustedAttestation     ErrorCode = "untrusted_attestation"
+	CodeAuthenticatorNotAllowed  ErrorCode = "authenticator_not_allowed"
+	CodeCredentialQuarantined    ErrorCode = "credential_quarantined"
+)
+
 var (
 	ErrBadRequest = &Error{
 		Type:    "invalid_request",
 		Details: "Error reading the requst data",
 	}
-	ErrChallengeMismatch = &Error{
-		Type:    "challenge_mismatch",
-		Details: "Stored challenge and received challenge do not match",
-	}
 	ErrParsingData = &Error{
 		Type:    "parse_error",
 		Details: "Error parsing the authenticator response",
@@ -48,6 +76,7 @@ var (
 	}
 	ErrAssertionSignature = &Error{
 		Type:    "invalid_signature",
+		Code:    CodeInvalidSignature,
 		Details: "Assertion Signature against auth data and client hash is not valid",
 	}
 	ErrUnsupportedKey = &Error{
@@ -76,6 +105,7 @@ var (
 	}
 	ErrAuthenticatorNotAllowed = &Error{
 		Type:    "authenticator_not_allowed",
+		Code:    CodeAuthenticatorNotAllowed,
 		Details: "The Authenticator is not allowed",
 	}
 	ErrCredentialNotFound = &Error{
@@ -84,22 +114,152 @@ var (
 	}
 	ErrCounterError = &Error{
 		Type:    "counter_not_updated",
+		Code:    CodeCounterRegression,
 		Details: "The Counter is not valid, because it was not updated.",
 	}
+	ErrExtension = &Error{
+		Type:    "invalid_extension",
+		Details: "Invalid extension output",
+	}
+	ErrSessionNotFound = &Error{
+		Type:    "session_not_found",
+		Details: "No session found for the given challenge",
+	}
+	ErrSessionAlreadyUsed = &Error{
+		Type:    "session_already_used",
+		Details: "The session has already been used",
+	}
+	ErrSessionExpired = &Error{
+		Type:    "session_expired",
+		Details: "The session has expired",
+	}
+	ErrCredentialQuarantined = &Error{
+		Type:    "credential_quarantined",
+		Code:    CodeCredentialQuarantined,
+		Details: "The credential is quarantined, because the authenticator may be cloned",
+	}
+	ErrTenantNotFound = &Error{
+		Type:    "tenant_not_found",
+		Details: "No tenant found for the given key",
+	}
+)
+
+// Errors of the single verification steps. They are derived from ErrVerification, so errors.Is(err, ErrVerification)
+// is true for all of them, while e.g. errors.Is(err, ErrOriginMismatch) is only true if the origin did not match.
+var (
+	ErrCeremonyMismatch         = ErrVerification.WithCode(CodeCeremonyMismatch)
+	ErrChallengeMismatch        = ErrVerification.WithCode(CodeChallengeMismatch).WithDetails("Stored challenge and received challenge do not match")
+	ErrOriginMismatch           = ErrVerification.WithCode(CodeOriginMismatch)
+	ErrTopOriginMismatch        = ErrVerification.WithCode(CodeTopOriginMismatch)
+	ErrRPIDHashMismatch         = ErrVerification.WithCode(CodeRPIDHashMismatch)
+	ErrUserPresenceMissing      = ErrVerification.WithCode(CodeUserPresenceMissing)
+	ErrUserVerificationMissing  = ErrVerification.WithCode(CodeUserVerificationMissing)
+	ErrInvalidBackupState       = ErrVerification.WithCode(CodeInvalidBackupState)
+	ErrBackupEligibilityChanged = ErrVerification.WithCode(CodeBackupEligibilityChanged)
+	ErrCredentialNotAllowed     = ErrBadRequest.WithCode(CodeCredentialNotAllowed)
+	ErrUserMismatch             = ErrBadRequest.WithCode(CodeUserMismatch)
+	ErrUntrustedAttestation     = ErrAttestation.WithCode(CodeUntrustedAttestation)
 )
 
+// httpStatus maps the types of the errors to the HTTP status a server should answer with:
+//   - 400 Bad Request if the request is malformed or does not belong to a valid session
+//   - 401 Unauthorized if the response of the authenticator could not be verified
+//   - 403 Forbidden if the response is valid, but the authenticator, its attestation or the credential is not
+//     accepted
+//   - 404 Not Found if the tenant does not exist
+//   - 409 Conflict if the credential is already registered
+//   - 501 Not Implemented if the library does not support the request
+//
+// All other errors are internal server errors.
+var httpStatus = map[string]int{
+	ErrBadRequest.Type:              http.StatusBadRequest,
+	ErrParsingData.Type:             http.StatusBadRequest,
+	ErrUnsupportedKey.Type:          http.StatusBadRequest,
+	ErrUnsupportedAlgorithm.Type:    http.StatusBadRequest,
+	ErrSessionNotFound.Type:         http.StatusBadRequest,
+	ErrSessionAlreadyUsed.Type:      http.StatusBadRequest,
+	ErrSessionExpired.Type:          http.StatusBadRequest,
+	ErrAuthData.Type:                http.StatusUnauthorized,
+	ErrVerification.Type:            http.StatusUnauthorized,
+	ErrAssertionSignature.Type:      http.StatusUnauthorized,
+	ErrCounterError.Type:            http.StatusUnauthorized,
+	ErrExtension.Type:               http.StatusUnauthorized,
+	ErrCredentialNotFound.Type:      http.StatusUnauthorized,
+	ErrAttestation.Type:             http.StatusForbidden,
+	ErrInvalidAttestation.Type:      http.StatusForbidden,
+	ErrAttestationCertificate.Type:  http.StatusForbidden,
+	ErrMetadataNotFound.Type:        http.StatusForbidden,
+	ErrAuthenticatorNotAllowed.Type: http.StatusForbidden,
+	ErrCredentialQuarantined.Type:   http.StatusForbidden,
+	ErrTenantNotFound.Type:          http.StatusNotFound,
+	ErrCredentialAlreadyExists.Type: http.StatusConflict,
+	ErrNotSpecImplemented.Type:      http.StatusNotImplemented,
+	ErrNotImplemented.Type:          http.StatusNotImplemented,
+}
+
 func (err *Error) Error() string {
+	if err.Err != nil {
+		return err.Details + ": " + err.Err.Error()
+	}
 	return err.Details
 }
 
+// Unwrap returns the cause of the error
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+// Is reports whether the error was derived from target, or has the same code as target. Errors are derived with the
+// With methods and Wrap, so errors.Is(err, ErrVerification) is true for every error derived from ErrVerification.
+func (err *Error) Is(target error) bool {
+	targetErr, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	for e := err; e != nil; e = e.parent {
+		if e == targetErr {
+			return true
+		}
+	}
+	return targetErr.Code != "" && targetErr.Code == err.Code
+}
+
+// HTTPStatus returns the HTTP status a server should answer with, see httpStatus
+func (err *Error) HTTPStatus() int {
+	if status, ok := httpStatus[err.Type]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
 func (passedError *Error) WithDetails(details string) *Error {
-	err := *passedError
+	err := passedError.derive()
 	err.Details = details
-	return &err
+	return err
 }
 
 func (passedError *Error) WithInfo(info string) *Error {
-	err := *passedError
+	err := passedError.derive()
 	err.DevInfo = info
+	return err
+}
+
+// WithCode returns a copy of the error for the failed verification step
+func (passedError *Error) WithCode(code ErrorCode) *Error {
+	err := passedError.derive()
+	err.Code = code
+	return err
+}
+
+// Wrap returns a copy of the error which was caused by the given error
+func (passedError *Error) Wrap(cause error) *Error {
+	err := passedError.derive()
+	err.Err = cause
+	return err
+}
+
+func (passedError *Error) derive() *Error {
+	err := *passedError
+	err.parent = passedError
 	return &err
 }
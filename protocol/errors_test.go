@@ -0,0 +1,120 @@
+package protocol
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestError_Is(t *testing.T) {
+	cause := errors.New("cause")
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{"Same error", ErrVerification, ErrVerification, true},
+		{"Derived with details", ErrVerification.WithDetails("details").WithInfo("info"), ErrVerification, true},
+		{"Step of verification", ErrOriginMismatch.WithDetails("Error validating origin"), ErrVerification, true},
+		{"Same step", ErrOriginMismatch.WithDetails("Error validating origin"), ErrOriginMismatch, true},
+		{"Other step", ErrOriginMismatch.WithDetails("Error validating origin"), ErrTopOriginMismatch, false},
+		{"Parent is not the step", ErrVerification.WithDetails("details"), ErrOriginMismatch, false},
+		{"Same code", &Error{Type: ErrVerification.Type, Code: CodeOriginMismatch}, ErrOriginMismatch, true},
+		{"Same type of other error", ErrAttestationFormat, ErrInvalidAttestation, false},
+		{"Wrapped in error", fmt.Errorf("login failed: %w", ErrRPIDHashMismatch.WithInfo("info")), ErrRPIDHashMismatch, true},
+		{"Cause", ErrCounterError.Wrap(cause), cause, true},
+		{"Wrapped step", ErrAuthData.Wrap(ErrUserVerificationMissing), ErrUserVerificationMissing, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.want {
+				t.Errorf("errors.Is(%v, %v) = %v, want %v", tt.err, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestError_As(t *testing.T) {
+	err := fmt.Errorf("login failed: %w", ErrCounterError.Wrap(errors.New("Counter was not updated.")))
+
+	var protocolErr *Error
+	if !errors.As(err, &protocolErr) {
+		t.Fatalf("errors.As() = false, want true")
+	}
+	if protocolErr.Code != CodeCounterRegression {
+		t.Errorf("Code = %s, want %s", protocolErr.Code, CodeCounterRegression)
+	}
+	if want := "The Counter is not valid, because it was not updated.: Counter was not updated."; protocolErr.Error() != want {
+		t.Errorf("Error() = %s, want %s", protocolErr.Error(), want)
+	}
+
+	body, jsonErr := json.Marshal(protocolErr)
+	if jsonErr != nil {
+		t.Fatal(jsonErr)
+	}
+	if want := `{"type":"counter_not_updated","code":"counter_regression","error":"The Counter is not valid, because it was not updated.","debug":""}`; string(body) != want {
+		t.Errorf("json.Marshal() = %s, want %s", body, want)
+	}
+}
+
+func TestError_HTTPStatus(t *testing.T) {
+	tests := []struct {
+		err  *Error
+		want int
+	}{
+		{ErrBadRequest.WithDetails("details"), http.StatusBadRequest},
+		{ErrSessionExpired, http.StatusBadRequest},
+		{ErrOriginMismatch, http.StatusUnauthorized},
+		{ErrAssertionSignature, http.StatusUnauthorized},
+		{ErrUntrustedAttestation, http.StatusForbidden},
+		{ErrAuthenticatorNotAllowed, http.StatusForbidden},
+		{ErrTenantNotFound, http.StatusNotFound},
+		{ErrCredentialAlreadyExists, http.StatusConflict},
+		{ErrNotImplemented, http.StatusNotImplemented},
+		{&Error{Type: "unknown"}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Type, func(t *testing.T) {
+			if got := tt.err.HTTPStatus(); got != tt.want {
+				t.Errorf("HTTPStatus() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerificationErrorCodes(t *testing.T) {
+	clientData := CollectedClientData{
+		Type:      AssertCeremony,
+		Challenge: "AAAA",
+		Origin:    "https://example.com",
+	}
+	rpIDHash := sha256.Sum256([]byte("example.com"))
+	authData := AuthenticatorData{Flags: FlagUserPresent}
+	authData.RPIDHash = rpIDHash[:]
+
+	tests := []struct {
+		name string
+		err  error
+		want *Error
+	}{
+		{"Ceremony", clientData.Verify("AAAA", CreateCeremony, testOriginRules("https://example.com"), nil), ErrCeremonyMismatch},
+		{"Challenge", clientData.Verify("BBBB", AssertCeremony, testOriginRules("https://example.com"), nil), ErrChallengeMismatch},
+		{"Origin", clientData.Verify("AAAA", AssertCeremony, testOriginRules("https://example.org"), nil), ErrOriginMismatch},
+		{"RP ID hash", authData.Verify(make([]byte, 32), false), ErrRPIDHashMismatch},
+		{"User verification", authData.Verify(rpIDHash[:], true), ErrUserVerificationMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !errors.Is(tt.err, tt.want) {
+				t.Errorf("error = %v, want %s", tt.err, tt.want.Code)
+			}
+			if !errors.Is(tt.err, ErrVerification) {
+				t.Errorf("error = %v, want error derived from ErrVerification", tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+const (
+	// ExtensionAppID is the identifier of the FIDO AppID Extension, which allows to log in with credentials that
+	// were registered with the FIDO U2F JavaScript API. See §10.1.
+	// https://www.w3.org/TR/webauthn-2/#sctn-appid-extension
+	ExtensionAppID = "appid"
+	// ExtensionAppIDExclude is the identifier of the FIDO AppID Exclusion Extension, which excludes authenticators
+	// that contain a U2F credential registered under the AppID. See §10.2.
+	// https://www.w3.org/TR/webauthn-2/#sctn-appid-exclude-extension
+	ExtensionAppIDExclude = "appidExclude"
+)
+
+func init() {
+	mustRegisterExtension(appIDExtension{identifier: ExtensionAppID, ceremony: AssertCeremony})
+	mustRegisterExtension(appIDExtension{identifier: ExtensionAppIDExclude, ceremony: CreateCeremony})
+}
+
+// AppIDUsed returns true if the client reports that the AppID was used instead of the RP ID, which means that the
+// RP ID hash in the authenticator data is the hash of the AppID
+func (outputs AuthenticationExtensionsClientOutputs) AppIDUsed() bool {
+	var used bool
+	if err := json.Unmarshal(outputs[ExtensionAppID], &used); err != nil {
+		return false
+	}
+	return used
+}
+
+// RelyingPartyID returns the RP ID the authenticator data of the assertion is scoped to. This is the AppID if the
+// appid extension was requested and the client reports that it was used, otherwise the given RP ID.
+func (p *ParsedCredentialAssertionData) RelyingPartyID(relyingPartyID string, requested AuthenticationExtensions) string {
+	var appID string
+	if err := json.Unmarshal(requested[ExtensionAppID], &appID); err == nil && appID != "" && p.Extensions.AppIDUsed() {
+		return appID
+	}
+	return relyingPartyID
+}
+
+// appIDExtension handles the appid and the appidExclude extension, which both take the AppID as input and return
+// a boolean client output
+type appIDExtension struct {
+	identifier string
+	ceremony   CeremonyType
+}
+
+func (e appIDExtension) Identifier() string {
+	return e.identifier
+}
+
+func (e appIDExtension) AuthenticatorIdentifier() string {
+	return ""
+}
+
+func (e appIDExtension) ClientInput(ceremony CeremonyType, input interface{}) (json.RawMessage, error) {
+	if ceremony != e.ceremony {
+		return nil, ErrExtension.WithDetails(fmt.Sprintf("%s is not allowed for %s", e.identifier, ceremony))
+	}
+	var appID string
+	if err := decodeExtensionInput(input, &appID); err != nil || appID == "" {
+		return nil, ErrExtension.WithDetails(fmt.Sprintf("%s input must be an AppID", e.identifier))
+	}
+	return json.Marshal(appID)
+}
+
+func (e appIDExtension) ParseClientOutput(output json.RawMessage) (interface{}, error) {
+	var used bool
+	if err := json.Unmarshal(output, &used); err != nil {
+		return nil, err
+	}
+	return used, nil
+}
+
+func (e appIDExtension) ParseAuthenticatorOutput(output cbor.RawMessage) (interface{}, error) {
+	return nil, errors.New(e.identifier + " is a client extension without authenticator output")
+}
+
+func (e appIDExtension) Validate(ceremony CeremonyType, input json.RawMessage, output ExtensionOutput) error {
+	if ceremony != e.ceremony && output.Client != nil {
+		return ErrExtension.WithDetails(fmt.Sprintf("%s output is not allowed for %s", e.identifier, ceremony))
+	}
+	var appID string
+	if err := json.Unmarshal(input, &appID); err != nil || appID == "" {
+		return ErrExtension.WithDetails(fmt.Sprintf("%s input must be an AppID", e.identifier))
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// ExtensionCredProps is the identifier of the Credential Properties Extension, see §10.4.
+// https://www.w3.org/TR/webauthn-2/#sctn-authenticator-credential-properties-extension
+const ExtensionCredProps = "credProps"
+
+func init() {
+	mustRegisterExtension(credPropsExtension{})
+}
+
+// CredentialPropertiesOutput is the client extension output of the credProps extension
+type CredentialPropertiesOutput struct {
+	// ResidentKey is true if the created credential is client-side discoverable, false if it is not and nil if the
+	// client does not know
+	ResidentKey *bool `json:"rk,omitempty"`
+}
+
+// CredProps returns the output of the credProps extension and whether the client returned it at all
+func (outputs AuthenticationExtensionsClientOutputs) CredProps() (*CredentialPropertiesOutput, bool) {
+	output, ok := outputs[ExtensionCredProps]
+	if !ok {
+		return nil, false
+	}
+	parsed, err := credPropsExtension{}.ParseClientOutput(output)
+	if err != nil {
+		return nil, false
+	}
+	return parsed.(*CredentialPropertiesOutput), true
+}
+
+// CredProps returns the verified output of the credProps extension and whether the client returned it at all
+func (outputs ExtensionOutputs) CredProps() (*CredentialPropertiesOutput, bool) {
+	credProps, ok := outputs[ExtensionCredProps].Client.(*CredentialPropertiesOutput)
+	return credProps, ok
+}
+
+type credPropsExtension struct{}
+
+func (credPropsExtension) Identifier() string {
+	return ExtensionCredProps
+}
+
+func (credPropsExtension) AuthenticatorIdentifier() string {
+	return ""
+}
+
+func (credPropsExtension) ClientInput(ceremony CeremonyType, input interface{}) (json.RawMessage, error) {
+	if ceremony != CreateCeremony {
+		return nil, ErrExtension.WithDetails("credProps is only allowed for registrations")
+	}
+	var requested bool
+	if err := decodeExtensionInput(input, &requested); err != nil || !requested {
+		return nil, ErrExtension.WithDetails("credProps input must be true")
+	}
+	return json.Marshal(requested)
+}
+
+func (credPropsExtension) ParseClientOutput(output json.RawMessage) (interface{}, error) {
+	var credProps CredentialPropertiesOutput
+	if err := json.Unmarshal(output, &credProps); err != nil {
+		return nil, err
+	}
+	return &credProps, nil
+}
+
+func (credPropsExtension) ParseAuthenticatorOutput(output cbor.RawMessage) (interface{}, error) {
+	return nil, errors.New("credProps is a client extension without authenticator output")
+}
+
+func (credPropsExtension) Validate(ceremony CeremonyType, input json.RawMessage, output ExtensionOutput) error {
+	if ceremony != CreateCeremony && output.Client != nil {
+		return ErrExtension.WithDetails("credProps output is only allowed for registrations")
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/teamhanko/webauthn-go/cbor_options"
+)
+
+const (
+	// ExtensionCredentialProtectionPolicy is the identifier of the client input of the credProtect extension, which
+	// requests the protection level of a new credential.
+	// https://fidoalliance.org/specs/fido-v2.1-ps-20210615/fido-client-to-authenticator-protocol-v2.1-ps-20210615.html#sctn-credProtect-extension
+	ExtensionCredentialProtectionPolicy = "credentialProtectionPolicy"
+	// ExtensionEnforceCredentialProtectionPolicy is the identifier of the client input which makes the client fail
+	// the registration if the authenticator does not support the requested protection level
+	ExtensionEnforceCredentialProtectionPolicy = "enforceCredentialProtectionPolicy"
+	// ExtensionCredProtect is the identifier of the authenticator extension of credProtect
+	ExtensionCredProtect = "credProtect"
+)
+
+func init() {
+	mustRegisterExtension(credProtectExtension{})
+}
+
+// CredentialProtectionPolicy is the protection level of a credential requested with the credProtect extension
+type CredentialProtectionPolicy string
+
+const (
+	// CredentialProtectionUserVerificationOptional allows to use the credential without user verification, which
+	// is the default of authenticators
+	CredentialProtectionUserVerificationOptional CredentialProtectionPolicy = "userVerificationOptional"
+	// CredentialProtectionUserVerificationOptionalWithCredentialIDList requires user verification for discoverable
+	// logins, the credential can be used without user verification if its ID is in the allow list
+	CredentialProtectionUserVerificationOptionalWithCredentialIDList CredentialProtectionPolicy = "userVerificationOptionalWithCredentialIDList"
+	// CredentialProtectionUserVerificationRequired requires user verification for every use of the credential
+	CredentialProtectionUserVerificationRequired CredentialProtectionPolicy = "userVerificationRequired"
+)
+
+// Level returns the numeric protection level used by the authenticator, which is 0 for an unknown policy
+func (policy CredentialProtectionPolicy) Level() uint {
+	switch policy {
+	case CredentialProtectionUserVerificationOptional:
+		return 1
+	case CredentialProtectionUserVerificationOptionalWithCredentialIDList:
+		return 2
+	case CredentialProtectionUserVerificationRequired:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// CredProtect returns the protection level the authenticator applied to the credential and whether it was returned
+func (outputs ExtensionOutputs) CredProtect() (uint, bool) {
+	level, ok := outputs[ExtensionCredentialProtectionPolicy].Authenticator.(uint)
+	return level, ok
+}
+
+type credProtectExtension struct{}
+
+func (credProtectExtension) Identifier() string {
+	return ExtensionCredentialProtectionPolicy
+}
+
+func (credProtectExtension) AuthenticatorIdentifier() string {
+	return ExtensionCredProtect
+}
+
+func (credProtectExtension) ClientInput(ceremony CeremonyType, input interface{}) (json.RawMessage, error) {
+	if ceremony != CreateCeremony {
+		return nil, ErrExtension.WithDetails("credProtect is only allowed for registrations")
+	}
+	var policy CredentialProtectionPolicy
+	if err := decodeExtensionInput(input, &policy); err != nil || policy.Level() == 0 {
+		return nil, ErrExtension.WithDetails(fmt.Sprintf("Invalid credentialProtectionPolicy %v", input))
+	}
+	return json.Marshal(policy)
+}
+
+func (credProtectExtension) ParseClientOutput(output json.RawMessage) (interface{}, error) {
+	return nil, errors.New("credProtect is an authenticator extension without client output")
+}
+
+func (credProtectExtension) ParseAuthenticatorOutput(output cbor.RawMessage) (interface{}, error) {
+	var level uint
+	if err := cbor_options.CborDecMode.Unmarshal(output, &level); err != nil {
+		return nil, err
+	}
+	return level, nil
+}
+
+func (credProtectExtension) Validate(ceremony CeremonyType, input json.RawMessage, output ExtensionOutput) error {
+	var policy CredentialProtectionPolicy
+	if err := json.Unmarshal(input, &policy); err != nil || policy.Level() == 0 {
+		return ErrExtension.WithDetails(fmt.Sprintf("Invalid credentialProtectionPolicy %s", string(input)))
+	}
+	if output.Authenticator == nil {
+		return nil
+	}
+	if ceremony != CreateCeremony {
+		return ErrExtension.WithDetails("credProtect output is only allowed for registrations")
+	}
+	if level := output.Authenticator.(uint); level < 1 || level > 3 {
+		return ErrExtension.WithDetails(fmt.Sprintf("Invalid credProtect level %d", level))
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// ExtensionLargeBlob is the identifier of the Large blob storage extension, which allows to store opaque data
+// associated with a credential on the authenticator.
+// https://www.w3.org/TR/webauthn-3/#sctn-large-blob-extension
+const ExtensionLargeBlob = "largeBlob"
+
+func init() {
+	mustRegisterExtension(largeBlobExtension{})
+}
+
+// LargeBlobSupport describes if large blob storage is required for a new credential
+type LargeBlobSupport string
+
+const (
+	// LargeBlobSupportRequired means that the credential must only be created if the authenticator supports large blobs
+	LargeBlobSupportRequired LargeBlobSupport = "required"
+	// LargeBlobSupportPreferred means that the credential is also created if the authenticator has no large blob storage
+	LargeBlobSupportPreferred LargeBlobSupport = "preferred"
+)
+
+// LargeBlobInputs are the inputs of the largeBlob extension. Support is only allowed for registrations, Read and
+// Write only for logins and never together.
+type LargeBlobInputs struct {
+	Support LargeBlobSupport `json:"support,omitempty"`
+	Read    bool             `json:"read,omitempty"`
+	Write   URLEncodedBase64 `json:"write,omitempty"`
+}
+
+// LargeBlobOutputs is the client extension output of the largeBlob extension
+type LargeBlobOutputs struct {
+	// Supported is returned during registration and tells if the created credential supports large blobs
+	Supported *bool `json:"supported,omitempty"`
+	// Blob contains the large blob of the credential after a login which requested Read, it is missing if the
+	// authenticator has no blob stored for the credential
+	Blob URLEncodedBase64 `json:"blob,omitempty"`
+	// Written tells if the blob of a login which requested Write was stored successfully
+	Written *bool `json:"written,omitempty"`
+}
+
+// LargeBlob returns the output of the largeBlob extension and whether the client returned it at all
+func (outputs AuthenticationExtensionsClientOutputs) LargeBlob() (*LargeBlobOutputs, bool) {
+	output, ok := outputs[ExtensionLargeBlob]
+	if !ok {
+		return nil, false
+	}
+	parsed, err := largeBlobExtension{}.ParseClientOutput(output)
+	if err != nil {
+		return nil, false
+	}
+	return parsed.(*LargeBlobOutputs), true
+}
+
+// LargeBlob returns the verified output of the largeBlob extension and whether the client returned it at all
+func (outputs ExtensionOutputs) LargeBlob() (*LargeBlobOutputs, bool) {
+	largeBlob, ok := outputs[ExtensionLargeBlob].Client.(*LargeBlobOutputs)
+	return largeBlob, ok
+}
+
+type largeBlobExtension struct{}
+
+func (largeBlobExtension) Identifier() string {
+	return ExtensionLargeBlob
+}
+
+func (largeBlobExtension) AuthenticatorIdentifier() string {
+	return ""
+}
+
+func (largeBlobExtension) ClientInput(ceremony CeremonyType, input interface{}) (json.RawMessage, error) {
+	var inputs LargeBlobInputs
+	if err := decodeExtensionInput(input, &inputs); err != nil {
+		return nil, ErrExtension.WithDetails("Invalid largeBlob input").WithInfo(err.Error())
+	}
+	if err := inputs.validate(ceremony); err != nil {
+		return nil, err
+	}
+	return json.Marshal(inputs)
+}
+
+// validate checks which inputs are allowed for the ceremony
+func (inputs LargeBlobInputs) validate(ceremony CeremonyType) error {
+	switch ceremony {
+	case CreateCeremony:
+		if inputs.Read || inputs.Write != nil {
+			return ErrExtension.WithDetails("largeBlob read and write are only allowed for logins")
+		}
+	case AssertCeremony:
+		if inputs.Support != "" {
+			return ErrExtension.WithDetails("largeBlob support is only allowed for registrations")
+		}
+		if inputs.Read && inputs.Write != nil {
+			return ErrExtension.WithDetails("largeBlob read and write must not be requested together")
+		}
+	}
+	return nil
+}
+
+func (largeBlobExtension) ParseClientOutput(output json.RawMessage) (interface{}, error) {
+	var largeBlob LargeBlobOutputs
+	if err := json.Unmarshal(output, &largeBlob); err != nil {
+		return nil, err
+	}
+	return &largeBlob, nil
+}
+
+func (largeBlobExtension) ParseAuthenticatorOutput(output cbor.RawMessage) (interface{}, error) {
+	return nil, errors.New("largeBlob is a client extension without authenticator output")
+}
+
+func (largeBlobExtension) Validate(ceremony CeremonyType, input json.RawMessage, output ExtensionOutput) error {
+	var inputs LargeBlobInputs
+	if err := json.Unmarshal(input, &inputs); err != nil {
+		return ErrExtension.WithDetails("Invalid largeBlob input").WithInfo(err.Error())
+	}
+	if err := inputs.validate(ceremony); err != nil {
+		return err
+	}
+	largeBlob, _ := output.Client.(*LargeBlobOutputs)
+
+	switch ceremony {
+	case CreateCeremony:
+		if largeBlob != nil && (largeBlob.Blob != nil || largeBlob.Written != nil) {
+			return ErrExtension.WithDetails("largeBlob registration output must only contain supported")
+		}
+		if inputs.Support == LargeBlobSupportRequired && largeBlob != nil && largeBlob.Supported != nil && !*largeBlob.Supported {
+			return ErrExtension.WithDetails("largeBlob support required but the credential does not support large blobs")
+		}
+	case AssertCeremony:
+		if largeBlob == nil {
+			return nil
+		}
+		if largeBlob.Supported != nil {
+			return ErrExtension.WithDetails("largeBlob supported is only returned for registrations")
+		}
+		if largeBlob.Blob != nil && !inputs.Read {
+			return ErrExtension.WithDetails("largeBlob blob returned without read")
+		}
+		if largeBlob.Written != nil && inputs.Write == nil {
+			return ErrExtension.WithDetails("largeBlob written returned without write")
+		}
+	}
+	return nil
+}
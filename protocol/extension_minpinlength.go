@@ -0,0 +1,69 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/teamhanko/webauthn-go/cbor_options"
+)
+
+// ExtensionMinPinLength is the identifier of the Minimum PIN Length extension, which returns the minimum PIN length
+// the authenticator enforces. Authenticators only return it to Relying Parties which are configured on the
+// authenticator.
+// https://fidoalliance.org/specs/fido-v2.1-ps-20210615/fido-client-to-authenticator-protocol-v2.1-ps-20210615.html#sctn-minpinlength-extension
+const ExtensionMinPinLength = "minPinLength"
+
+func init() {
+	mustRegisterExtension(minPinLengthExtension{})
+}
+
+// MinPinLength returns the minimum PIN length reported by the authenticator and whether it was returned
+func (outputs ExtensionOutputs) MinPinLength() (uint, bool) {
+	length, ok := outputs[ExtensionMinPinLength].Authenticator.(uint)
+	return length, ok
+}
+
+type minPinLengthExtension struct{}
+
+func (minPinLengthExtension) Identifier() string {
+	return ExtensionMinPinLength
+}
+
+func (minPinLengthExtension) AuthenticatorIdentifier() string {
+	return ExtensionMinPinLength
+}
+
+func (minPinLengthExtension) ClientInput(ceremony CeremonyType, input interface{}) (json.RawMessage, error) {
+	if ceremony != CreateCeremony {
+		return nil, ErrExtension.WithDetails("minPinLength is only allowed for registrations")
+	}
+	var requested bool
+	if err := decodeExtensionInput(input, &requested); err != nil || !requested {
+		return nil, ErrExtension.WithDetails("minPinLength input must be true")
+	}
+	return json.Marshal(requested)
+}
+
+func (minPinLengthExtension) ParseClientOutput(output json.RawMessage) (interface{}, error) {
+	return nil, errors.New("minPinLength is an authenticator extension without client output")
+}
+
+func (minPinLengthExtension) ParseAuthenticatorOutput(output cbor.RawMessage) (interface{}, error) {
+	var length uint
+	if err := cbor_options.CborDecMode.Unmarshal(output, &length); err != nil {
+		return nil, err
+	}
+	return length, nil
+}
+
+func (minPinLengthExtension) Validate(ceremony CeremonyType, input json.RawMessage, output ExtensionOutput) error {
+	var requested bool
+	if err := json.Unmarshal(input, &requested); err != nil || !requested {
+		return ErrExtension.WithDetails("minPinLength input must be true")
+	}
+	if ceremony != CreateCeremony && output.Authenticator != nil {
+		return ErrExtension.WithDetails("minPinLength output is only allowed for registrations")
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/teamhanko/webauthn-go/cbor_options"
+)
+
+const (
+	// ExtensionPRF is the identifier of the Pseudo-random function extension, which lets the authenticator derive
+	// symmetric secrets from salts chosen by the Relying Party.
+	// https://w3c.github.io/webauthn/#prf-extension
+	ExtensionPRF = "prf"
+	// ExtensionHMACSecret is the identifier of the CTAP2 hmac-secret extension, which implements the prf extension on
+	// the authenticator.
+	// https://fidoalliance.org/specs/fido-v2.1-ps-20210615/fido-client-to-authenticator-protocol-v2.1-ps-20210615.html#sctn-hmac-secret-extension
+	ExtensionHMACSecret = "hmac-secret"
+)
+
+func init() {
+	mustRegisterExtension(prfExtension{})
+}
+
+// PRFValues are the salts sent to the authenticator or the secrets derived from them
+type PRFValues struct {
+	First  URLEncodedBase64 `json:"first"`
+	Second URLEncodedBase64 `json:"second,omitempty"`
+}
+
+// PRFInputs are the inputs of the prf extension. The keys of EvalByCredential are the base64url encoded IDs of the
+// allowed credentials, the values are used instead of Eval when the matching credential is used.
+type PRFInputs struct {
+	Eval             *PRFValues           `json:"eval,omitempty"`
+	EvalByCredential map[string]PRFValues `json:"evalByCredential,omitempty"`
+}
+
+// PRFOutputs is the client extension output of the prf extension
+type PRFOutputs struct {
+	// Enabled is returned during registration and tells if the created credential supports the prf extension
+	Enabled *bool `json:"enabled,omitempty"`
+	// Results contains the secrets derived from the salts of the used credential
+	Results *PRFValues `json:"results,omitempty"`
+}
+
+// HMACSecretOutput is the authenticator extension output of the hmac-secret extension. During registration the
+// authenticator reports whether the extension is enabled, during a login it returns the derived secrets encrypted
+// for the client, which is why they can not be used by the Relying Party.
+type HMACSecretOutput struct {
+	Enabled         *bool
+	EncryptedOutput []byte
+}
+
+// PRF returns the output of the prf extension and whether the client returned it at all
+func (outputs AuthenticationExtensionsClientOutputs) PRF() (*PRFOutputs, bool) {
+	output, ok := outputs[ExtensionPRF]
+	if !ok {
+		return nil, false
+	}
+	parsed, err := prfExtension{}.ParseClientOutput(output)
+	if err != nil {
+		return nil, false
+	}
+	return parsed.(*PRFOutputs), true
+}
+
+// PRF returns the verified output of the prf extension and whether the client returned it at all
+func (outputs ExtensionOutputs) PRF() (*PRFOutputs, bool) {
+	prf, ok := outputs[ExtensionPRF].Client.(*PRFOutputs)
+	return prf, ok
+}
+
+// HMACSecret returns the verified hmac-secret output of the authenticator and whether it was returned at all
+func (outputs ExtensionOutputs) HMACSecret() (*HMACSecretOutput, bool) {
+	hmacSecret, ok := outputs[ExtensionPRF].Authenticator.(*HMACSecretOutput)
+	return hmacSecret, ok
+}
+
+type prfExtension struct{}
+
+func (prfExtension) Identifier() string {
+	return ExtensionPRF
+}
+
+func (prfExtension) AuthenticatorIdentifier() string {
+	return ExtensionHMACSecret
+}
+
+func (prfExtension) ClientInput(ceremony CeremonyType, input interface{}) (json.RawMessage, error) {
+	var inputs PRFInputs
+	if err := decodeExtensionInput(input, &inputs); err != nil {
+		return nil, ErrExtension.WithDetails("Invalid prf input").WithInfo(err.Error())
+	}
+	if err := inputs.validate(ceremony); err != nil {
+		return nil, err
+	}
+	return json.Marshal(inputs)
+}
+
+// validate checks which inputs are allowed for the ceremony
+func (inputs PRFInputs) validate(ceremony CeremonyType) error {
+	if ceremony == CreateCeremony && len(inputs.EvalByCredential) > 0 {
+		return ErrExtension.WithDetails("prf evalByCredential is only allowed for logins")
+	}
+	return nil
+}
+
+func (prfExtension) ParseClientOutput(output json.RawMessage) (interface{}, error) {
+	var prf PRFOutputs
+	if err := json.Unmarshal(output, &prf); err != nil {
+		return nil, err
+	}
+	return &prf, nil
+}
+
+func (prfExtension) ParseAuthenticatorOutput(output cbor.RawMessage) (interface{}, error) {
+	var value interface{}
+	if err := cbor_options.CborDecMode.Unmarshal(output, &value); err != nil {
+		return nil, err
+	}
+	switch value := value.(type) {
+	case bool:
+		return &HMACSecretOutput{Enabled: &value}, nil
+	case []byte:
+		return &HMACSecretOutput{EncryptedOutput: value}, nil
+	default:
+		return nil, fmt.Errorf("hmac-secret output has unexpected type %T", value)
+	}
+}
+
+func (prfExtension) Validate(ceremony CeremonyType, input json.RawMessage, output ExtensionOutput) error {
+	var inputs PRFInputs
+	if err := json.Unmarshal(input, &inputs); err != nil {
+		return ErrExtension.WithDetails("Invalid prf input").WithInfo(err.Error())
+	}
+	if err := inputs.validate(ceremony); err != nil {
+		return err
+	}
+
+	if prf, ok := output.Client.(*PRFOutputs); ok {
+		if prf.Enabled != nil && ceremony != CreateCeremony {
+			return ErrExtension.WithDetails("prf enabled is only returned for registrations")
+		}
+		if prf.Results != nil && inputs.Eval == nil && len(inputs.EvalByCredential) == 0 {
+			return ErrExtension.WithDetails("prf results returned without salts")
+		}
+	}
+	if hmacSecret, ok := output.Authenticator.(*HMACSecretOutput); ok {
+		if ceremony == CreateCeremony && hmacSecret.Enabled == nil {
+			return ErrExtension.WithDetails("hmac-secret registration output must be a boolean")
+		}
+		if ceremony == AssertCeremony && hmacSecret.EncryptedOutput == nil {
+			return ErrExtension.WithDetails("hmac-secret login output must be a byte string")
+		}
+	}
+	return nil
+}
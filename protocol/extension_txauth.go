@@ -0,0 +1,204 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/teamhanko/webauthn-go/cbor_options"
+)
+
+const (
+	// ExtensionTxAuthSimple is the identifier of the Simple Transaction Authorization Extension, which asks the
+	// authenticator to display a text and to confirm it. See §10.2 of WebAuthn Level 1.
+	// https://www.w3.org/TR/webauthn-1/#sctn-simple-txauth-extension
+	ExtensionTxAuthSimple = "txAuthSimple"
+	// ExtensionTxAuthGeneric is the identifier of the Generic Transaction Authorization Extension, which asks the
+	// authenticator to display arbitrary content, e.g. an image. See §10.3 of WebAuthn Level 1.
+	// https://www.w3.org/TR/webauthn-1/#sctn-generic-txauth-extension
+	ExtensionTxAuthGeneric = "txAuthGeneric"
+)
+
+func init() {
+	mustRegisterExtension(txAuthExtension{identifier: ExtensionTxAuthSimple})
+	mustRegisterExtension(txAuthExtension{identifier: ExtensionTxAuthGeneric})
+}
+
+// TxAuthGenericArg is the input of the txAuthGeneric extension
+type TxAuthGenericArg struct {
+	ContentType string           `json:"contentType"`
+	Content     URLEncodedBase64 `json:"content"`
+}
+
+// Transaction is a transaction the user confirms during a login. Either Text is shown with txAuthSimple or the
+// Generic content with txAuthGeneric. The transaction is bound to the login by its challenge, and the authenticator
+// must return the confirmed transaction in its extension outputs.
+//
+// The txAuth extensions were removed in WebAuthn Level 2 and browsers do not return their outputs, so a login which
+// requires the confirmation fails in all current browsers. OptionalConfirmation accepts a login without the outputs,
+// which only proves that the signature was made for the challenge derived from the transaction, not that the user
+// saw the transaction.
+type Transaction struct {
+	Text    string            `json:"text,omitempty"`
+	Generic *TxAuthGenericArg `json:"generic,omitempty"`
+	// OptionalConfirmation lets Verify accept a login whose authenticator did not return the confirmed transaction.
+	// A returned output must still match the transaction.
+	OptionalConfirmation bool `json:"optionalConfirmation,omitempty"`
+}
+
+// Empty returns true if the transaction has no text or content to confirm
+func (tx *Transaction) Empty() bool {
+	if tx.Generic != nil {
+		return len(tx.Generic.Content) == 0
+	}
+	return tx.Text == ""
+}
+
+// Hash returns the SHA-256 hash of the text or the generic content of the transaction
+func (tx *Transaction) Hash() []byte {
+	var hash [32]byte
+	if tx.Generic != nil {
+		hash = sha256.Sum256(tx.Generic.Content)
+	} else {
+		hash = sha256.Sum256([]byte(tx.Text))
+	}
+	return hash[:]
+}
+
+// Challenge derives the challenge of the login from a random nonce and the hash of the transaction, which binds the
+// signature of the assertion to the transaction even if the authenticator does not support the extension
+func (tx *Transaction) Challenge(nonce []byte) []byte {
+	return append(append([]byte{}, nonce...), tx.Hash()...)
+}
+
+// Extensions returns the extension inputs which request the confirmation of the transaction
+func (tx *Transaction) Extensions() AuthenticationExtensions {
+	identifier, input := tx.extensionInput()
+	encoded, _ := json.Marshal(input)
+	return AuthenticationExtensions{identifier: encoded}
+}
+
+// extensionInput returns the identifier and the input of the extension which displays the transaction
+func (tx *Transaction) extensionInput() (string, interface{}) {
+	if tx.Generic != nil {
+		return ExtensionTxAuthGeneric, *tx.Generic
+	}
+	return ExtensionTxAuthSimple, tx.Text
+}
+
+// Verify checks that the base64url encoded challenge was derived from the transaction and that the authenticator
+// confirmed the transaction in its extension outputs. A missing output is accepted if OptionalConfirmation is set.
+func (tx *Transaction) Verify(challenge string, outputs ExtensionOutputs) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(challenge)
+	if err != nil || !bytes.HasSuffix(decoded, tx.Hash()) {
+		return ErrVerification.WithDetails("Challenge was not derived from the transaction")
+	}
+
+	identifier, _ := tx.extensionInput()
+	output := outputs[identifier]
+	if output.Authenticator == nil {
+		if !tx.OptionalConfirmation {
+			return ErrVerification.WithDetails("Transaction was not confirmed by the authenticator")
+		}
+		return nil
+	}
+	return tx.confirm(identifier, output)
+}
+
+// confirm compares the outputs which were returned with the transaction. The client and authenticator may insert
+// line breaks into the text of txAuthSimple to display it.
+func (tx *Transaction) confirm(identifier string, output ExtensionOutput) error {
+	switch identifier {
+	case ExtensionTxAuthSimple:
+		for _, confirmed := range []interface{}{output.Client, output.Authenticator} {
+			if text, ok := confirmed.(string); ok && strings.ReplaceAll(text, "\n", "") != strings.ReplaceAll(tx.Text, "\n", "") {
+				return ErrVerification.WithDetails("Confirmed transaction differs from the issued transaction")
+			}
+		}
+	case ExtensionTxAuthGeneric:
+		if hash, ok := output.Authenticator.([]byte); ok && !bytes.Equal(hash, tx.Hash()) {
+			return ErrVerification.WithDetails("Confirmed transaction differs from the issued transaction")
+		}
+	}
+	return nil
+}
+
+// txAuthExtension handles txAuthSimple, whose outputs are the displayed text, and txAuthGeneric, whose client output
+// is true and whose authenticator output is the hash of the displayed content
+type txAuthExtension struct {
+	identifier string
+}
+
+func (e txAuthExtension) Identifier() string {
+	return e.identifier
+}
+
+func (e txAuthExtension) AuthenticatorIdentifier() string {
+	return e.identifier
+}
+
+func (e txAuthExtension) ClientInput(ceremony CeremonyType, input interface{}) (json.RawMessage, error) {
+	tx, err := e.transaction(ceremony, input)
+	if err != nil {
+		return nil, err
+	}
+	if tx.Empty() {
+		return nil, ErrExtension.WithDetails(fmt.Sprintf("%s input must not be empty", e.identifier))
+	}
+	_, decoded := tx.extensionInput()
+	return json.Marshal(decoded)
+}
+
+// transaction decodes the input of the extension into the transaction it displays
+func (e txAuthExtension) transaction(ceremony CeremonyType, input interface{}) (*Transaction, error) {
+	if ceremony != AssertCeremony {
+		return nil, ErrExtension.WithDetails(fmt.Sprintf("%s is only allowed for logins", e.identifier))
+	}
+
+	var tx Transaction
+	var err error
+	if e.identifier == ExtensionTxAuthGeneric {
+		tx.Generic = &TxAuthGenericArg{}
+		err = decodeExtensionInput(input, tx.Generic)
+	} else {
+		err = decodeExtensionInput(input, &tx.Text)
+	}
+	if err != nil {
+		return nil, ErrExtension.WithDetails(fmt.Sprintf("Invalid %s input", e.identifier)).WithInfo(err.Error())
+	}
+	return &tx, nil
+}
+
+func (e txAuthExtension) ParseClientOutput(output json.RawMessage) (interface{}, error) {
+	if e.identifier == ExtensionTxAuthGeneric {
+		var confirmed bool
+		err := json.Unmarshal(output, &confirmed)
+		return confirmed, err
+	}
+	var text string
+	err := json.Unmarshal(output, &text)
+	return text, err
+}
+
+func (e txAuthExtension) ParseAuthenticatorOutput(output cbor.RawMessage) (interface{}, error) {
+	if e.identifier == ExtensionTxAuthGeneric {
+		var hash []byte
+		err := cbor_options.CborDecMode.Unmarshal(output, &hash)
+		return hash, err
+	}
+	var text string
+	err := cbor_options.CborDecMode.Unmarshal(output, &text)
+	return text, err
+}
+
+func (e txAuthExtension) Validate(ceremony CeremonyType, input json.RawMessage, output ExtensionOutput) error {
+	tx, err := e.transaction(ceremony, input)
+	if err != nil {
+		return err
+	}
+	return tx.confirm(e.identifier, output)
+}
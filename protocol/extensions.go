@@ -1,8 +1,260 @@
 package protocol
 
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/teamhanko/webauthn-go/cbor_options"
+)
+
 // Extensions are discussed in §9. WebAuthn Extensions (https://www.w3.org/TR/webauthn-1/#extensions).
 
 // For a list of commonly supported extenstions, see §10. Defined Extensions
 // (https://www.w3.org/TR/webauthn-1/#sctn-defined-extensions).
 
-type AuthenticationExtensionsClientOutputs map[interface{}]interface{}
+// AuthenticationExtensionsClientOutputs contains the client extension outputs of a response, keyed by the extension
+// identifier. The outputs are kept as raw JSON until they are parsed by the registered Extension.
+type AuthenticationExtensionsClientOutputs map[string]json.RawMessage
+
+// Extension implements the Relying Party processing of a WebAuthn extension. Extensions are registered with
+// RegisterExtension. They create the client inputs of the request options with ExtensionInput and are used by
+// VerifyExtensions to parse and validate the outputs of a response.
+type Extension interface {
+	// Identifier returns the identifier of the client extension, which is the key of its input in the request
+	// options and of its output in the client extension outputs.
+	Identifier() string
+	// AuthenticatorIdentifier returns the key of the authenticator extension output in the authenticator data,
+	// or an empty string if the extension has no authenticator output.
+	AuthenticatorIdentifier() string
+	// ClientInput checks the input of the extension for the ceremony and encodes it for the request options. The
+	// input is either the typed input of the extension or its JSON encoding as json.RawMessage.
+	ClientInput(ceremony CeremonyType, input interface{}) (json.RawMessage, error)
+	// ParseClientOutput decodes the client extension output from its JSON representation
+	ParseClientOutput(output json.RawMessage) (interface{}, error)
+	// ParseAuthenticatorOutput decodes the authenticator extension output from its CBOR representation
+	ParseAuthenticatorOutput(output cbor.RawMessage) (interface{}, error)
+	// Validate checks the parsed outputs against the JSON encoded input that was requested in the ceremony
+	Validate(ceremony CeremonyType, input json.RawMessage, output ExtensionOutput) error
+}
+
+// ExtensionOutput contains the parsed outputs of one extension. Outputs which were not returned are nil. Outputs of
+// extensions which are not registered are kept as json.RawMessage and cbor.RawMessage.
+type ExtensionOutput struct {
+	Client        interface{}
+	Authenticator interface{}
+}
+
+// ExtensionOutputs contains the outputs of all extensions of a response, keyed by the client extension identifier
+type ExtensionOutputs map[string]ExtensionOutput
+
+var (
+	extensionRegistry = make(map[string]Extension)
+	// extensionsByAuthenticatorIdentifier maps the authenticator identifiers to the registered extensions
+	extensionsByAuthenticatorIdentifier = make(map[string]Extension)
+	extensionRegistryMu                 sync.RWMutex
+)
+
+// RegisterExtension registers the handler of an extension, replacing a handler registered for the same identifier.
+// It fails if another extension is registered for the same authenticator identifier, as the authenticator outputs
+// could not be told apart.
+func RegisterExtension(extension Extension) error {
+	extensionRegistryMu.Lock()
+	defer extensionRegistryMu.Unlock()
+
+	identifier := extension.Identifier()
+	authenticatorIdentifier := extension.AuthenticatorIdentifier()
+	if registered, ok := extensionsByAuthenticatorIdentifier[authenticatorIdentifier]; ok && authenticatorIdentifier != "" && registered.Identifier() != identifier {
+		return fmt.Errorf("authenticator identifier %s is already used by extension %s", authenticatorIdentifier, registered.Identifier())
+	}
+
+	if previous, ok := extensionRegistry[identifier]; ok && previous.AuthenticatorIdentifier() != "" {
+		delete(extensionsByAuthenticatorIdentifier, previous.AuthenticatorIdentifier())
+	}
+	extensionRegistry[identifier] = extension
+	if authenticatorIdentifier != "" {
+		extensionsByAuthenticatorIdentifier[authenticatorIdentifier] = extension
+	}
+	return nil
+}
+
+// mustRegisterExtension registers the extensions of this package, which never conflict
+func mustRegisterExtension(extension Extension) {
+	if err := RegisterExtension(extension); err != nil {
+		panic(err)
+	}
+}
+
+// registeredExtension returns the extension registered for the client identifier
+func registeredExtension(identifier string) (Extension, bool) {
+	extensionRegistryMu.RLock()
+	defer extensionRegistryMu.RUnlock()
+	extension, ok := extensionRegistry[identifier]
+	return extension, ok
+}
+
+// clientIdentifier returns the identifier of the client extension which belongs to the authenticator identifier
+func clientIdentifier(authenticatorIdentifier string) string {
+	extensionRegistryMu.RLock()
+	defer extensionRegistryMu.RUnlock()
+	if extension, ok := extensionsByAuthenticatorIdentifier[authenticatorIdentifier]; ok {
+		return extension.Identifier()
+	}
+	return authenticatorIdentifier
+}
+
+// ExtensionInput creates the client input of an extension for the request options of the ceremony. The input of a
+// registered extension is checked and encoded by its ClientInput, the input of other extensions is encoded as JSON.
+func ExtensionInput(ceremony CeremonyType, identifier string, input interface{}) (json.RawMessage, error) {
+	extension, ok := registeredExtension(identifier)
+	if !ok {
+		encoded, err := json.Marshal(input)
+		if err != nil {
+			return nil, ErrExtension.WithDetails(fmt.Sprintf("Invalid input for extension %s", identifier)).WithInfo(err.Error())
+		}
+		return encoded, nil
+	}
+	encoded, err := extension.ClientInput(ceremony, input)
+	if err != nil {
+		if _, ok := err.(*Error); ok {
+			return nil, err
+		}
+		return nil, ErrExtension.WithDetails(fmt.Sprintf("Invalid input for extension %s", identifier)).WithInfo(err.Error())
+	}
+	return encoded, nil
+}
+
+// With returns a copy of the extensions with the client input of the extension added, see ExtensionInput
+func (extensions AuthenticationExtensions) With(ceremony CeremonyType, identifier string, input interface{}) (AuthenticationExtensions, error) {
+	encoded, err := ExtensionInput(ceremony, identifier, input)
+	if err != nil {
+		return nil, err
+	}
+	result := make(AuthenticationExtensions, len(extensions)+1)
+	for key, value := range extensions {
+		result[key] = value
+	}
+	result[identifier] = encoded
+	return result, nil
+}
+
+// BuildExtensionInputs passes every input of the request options through ExtensionInput, so that the inputs of the
+// registered extensions are checked before the options are sent to the client
+func BuildExtensionInputs(ceremony CeremonyType, extensions AuthenticationExtensions) (AuthenticationExtensions, error) {
+	if len(extensions) == 0 {
+		return extensions, nil
+	}
+	result := make(AuthenticationExtensions, len(extensions))
+	for identifier, input := range extensions {
+		encoded, err := ExtensionInput(ceremony, identifier, input)
+		if err != nil {
+			return nil, err
+		}
+		result[identifier] = encoded
+	}
+	return result, nil
+}
+
+// decodeExtensionInput decodes the input of an extension, which is either its typed input or its JSON encoding
+func decodeExtensionInput(input interface{}, target interface{}) error {
+	encoded, ok := input.(json.RawMessage)
+	if !ok {
+		var err error
+		if encoded, err = json.Marshal(input); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(encoded, target)
+}
+
+// VerifyExtensions handles Registration Step 12 and Assertion Step 14. It verifies that the client extension outputs
+// and the authenticator extension outputs are as expected, considering the extension inputs that were given in the
+// request options. Outputs of extensions which were not requested fail the ceremony. The outputs are parsed and
+// validated by the registered extensions.
+func VerifyExtensions(ceremony CeremonyType, requested AuthenticationExtensions, clientOutputs AuthenticationExtensionsClientOutputs, authData *AuthenticatorData) (ExtensionOutputs, error) {
+	authenticatorOutputs, err := authData.extensionOutputs()
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make(ExtensionOutputs)
+	for identifier, output := range clientOutputs {
+		if _, ok := requested[identifier]; !ok {
+			return nil, ErrExtension.WithDetails(fmt.Sprintf("Client returned extension %s which was not requested", identifier))
+		}
+		parsed, err := parseClientOutput(identifier, output)
+		if err != nil {
+			return nil, err
+		}
+		outputs[identifier] = ExtensionOutput{Client: parsed}
+	}
+	for authenticatorIdentifier, output := range authenticatorOutputs {
+		identifier := clientIdentifier(authenticatorIdentifier)
+		if _, ok := requested[identifier]; !ok {
+			return nil, ErrExtension.WithDetails(fmt.Sprintf("Authenticator returned extension %s which was not requested", authenticatorIdentifier))
+		}
+		parsed, err := parseAuthenticatorOutput(identifier, output)
+		if err != nil {
+			return nil, err
+		}
+		extensionOutput := outputs[identifier]
+		extensionOutput.Authenticator = parsed
+		outputs[identifier] = extensionOutput
+	}
+
+	// Validate in a stable order, so the same response always fails with the same error
+	identifiers := make([]string, 0, len(requested))
+	for identifier := range requested {
+		identifiers = append(identifiers, identifier)
+	}
+	sort.Strings(identifiers)
+	for _, identifier := range identifiers {
+		extension, ok := registeredExtension(identifier)
+		if !ok {
+			continue
+		}
+		if err := extension.Validate(ceremony, requested[identifier], outputs[identifier]); err != nil {
+			return nil, err
+		}
+	}
+
+	return outputs, nil
+}
+
+func parseClientOutput(identifier string, output json.RawMessage) (interface{}, error) {
+	extension, ok := registeredExtension(identifier)
+	if !ok {
+		return output, nil
+	}
+	parsed, err := extension.ParseClientOutput(output)
+	if err != nil {
+		return nil, ErrExtension.WithDetails(fmt.Sprintf("Invalid client output for extension %s", identifier)).WithInfo(err.Error())
+	}
+	return parsed, nil
+}
+
+func parseAuthenticatorOutput(identifier string, output cbor.RawMessage) (interface{}, error) {
+	extension, ok := registeredExtension(identifier)
+	if !ok {
+		return output, nil
+	}
+	parsed, err := extension.ParseAuthenticatorOutput(output)
+	if err != nil {
+		return nil, ErrExtension.WithDetails(fmt.Sprintf("Invalid authenticator output for extension %s", identifier)).WithInfo(err.Error())
+	}
+	return parsed, nil
+}
+
+// extensionOutputs decodes the CBOR map of authenticator extension outputs in ExtData
+func (a *AuthenticatorData) extensionOutputs() (map[string]cbor.RawMessage, error) {
+	if !a.Flags.HasExtensions() {
+		return nil, nil
+	}
+	var outputs map[string]cbor.RawMessage
+	if err := cbor_options.CborDecMode.Unmarshal(a.ExtData, &outputs); err != nil {
+		return nil, ErrExtension.WithDetails("Authenticator extension outputs are not a valid CBOR map").WithInfo(err.Error())
+	}
+	return outputs, nil
+}
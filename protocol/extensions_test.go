@@ -0,0 +1,532 @@
+package protocol
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestAuthenticationExtensionsClientOutputs_CredProps(t *testing.T) {
+	tests := []struct {
+		name            string
+		outputs         string
+		wantPresent     bool
+		wantResidentKey *bool
+	}{
+		{"Missing", `{}`, false, nil},
+		{"Discoverable", `{"credProps":{"rk":true}}`, true, boolPointer(true)},
+		{"Not discoverable", `{"credProps":{"rk":false}}`, true, boolPointer(false)},
+		{"Unknown", `{"credProps":{}}`, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var outputs AuthenticationExtensionsClientOutputs
+			if err := json.Unmarshal([]byte(tt.outputs), &outputs); err != nil {
+				t.Fatal(err)
+			}
+			credProps, present := outputs.CredProps()
+			if present != tt.wantPresent {
+				t.Fatalf("CredProps() present = %v, want %v", present, tt.wantPresent)
+			}
+			if !present {
+				return
+			}
+			if (credProps.ResidentKey == nil) != (tt.wantResidentKey == nil) ||
+				(credProps.ResidentKey != nil && *credProps.ResidentKey != *tt.wantResidentKey) {
+				t.Errorf("CredProps() ResidentKey = %v, want %v", credProps.ResidentKey, tt.wantResidentKey)
+			}
+		})
+	}
+}
+
+func TestParsedCredentialAssertionData_RelyingPartyID(t *testing.T) {
+	const appID = "https://example.com/u2f/app-id.json"
+	tests := []struct {
+		name      string
+		requested AuthenticationExtensions
+		outputs   string
+		want      string
+	}{
+		{"No extensions", nil, `{}`, "example.com"},
+		{"AppID used", AuthenticationExtensions{ExtensionAppID: rawInput(appID)}, `{"appid":true}`, appID},
+		{"AppID not used", AuthenticationExtensions{ExtensionAppID: rawInput(appID)}, `{"appid":false}`, "example.com"},
+		{"AppID not requested", nil, `{"appid":true}`, "example.com"},
+		{"Invalid output", AuthenticationExtensions{ExtensionAppID: rawInput(appID)}, `{"appid":"yes"}`, "example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ParsedCredentialAssertionData{}
+			if err := json.Unmarshal([]byte(tt.outputs), &p.Extensions); err != nil {
+				t.Fatal(err)
+			}
+			if got := p.RelyingPartyID("example.com", tt.requested); got != tt.want {
+				t.Errorf("RelyingPartyID() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyExtensions_AppID(t *testing.T) {
+	const appID = "https://example.com/u2f/app-id.json"
+	tests := []struct {
+		name      string
+		ceremony  CeremonyType
+		requested AuthenticationExtensions
+		outputs   string
+		wantErr   bool
+	}{
+		{"appid in get", AssertCeremony, AuthenticationExtensions{ExtensionAppID: rawInput(appID)}, `{"appid":true}`, false},
+		{"appid in create", CreateCeremony, AuthenticationExtensions{ExtensionAppID: rawInput(appID)}, `{"appid":true}`, true},
+		{"appid without AppID", AssertCeremony, AuthenticationExtensions{ExtensionAppID: rawInput(true)}, `{"appid":true}`, true},
+		{"appid invalid output", AssertCeremony, AuthenticationExtensions{ExtensionAppID: rawInput(appID)}, `{"appid":"yes"}`, true},
+		{"appidExclude in create", CreateCeremony, AuthenticationExtensions{ExtensionAppIDExclude: rawInput(appID)}, `{"appidExclude":true}`, false},
+		{"appidExclude in get", AssertCeremony, AuthenticationExtensions{ExtensionAppIDExclude: rawInput(appID)}, `{"appidExclude":true}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var outputs AuthenticationExtensionsClientOutputs
+			if err := json.Unmarshal([]byte(tt.outputs), &outputs); err != nil {
+				t.Fatal(err)
+			}
+			_, err := VerifyExtensions(tt.ceremony, tt.requested, outputs, &AuthenticatorData{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyExtensions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyExtensions_LargeBlob(t *testing.T) {
+	tests := []struct {
+		name     string
+		ceremony CeremonyType
+		input    LargeBlobInputs
+		outputs  string
+		wantErr  bool
+	}{
+		{"Support", CreateCeremony, LargeBlobInputs{Support: LargeBlobSupportPreferred}, `{"largeBlob":{"supported":false}}`, false},
+		{"Required support missing", CreateCeremony, LargeBlobInputs{Support: LargeBlobSupportRequired}, `{"largeBlob":{"supported":false}}`, true},
+		{"Read in registration", CreateCeremony, LargeBlobInputs{Read: true}, `{}`, true},
+		{"Read", AssertCeremony, LargeBlobInputs{Read: true}, `{"largeBlob":{"blob":"AQID"}}`, false},
+		{"Read without blob", AssertCeremony, LargeBlobInputs{Read: true}, `{"largeBlob":{}}`, false},
+		{"Write", AssertCeremony, LargeBlobInputs{Write: []byte{1}}, `{"largeBlob":{"written":false}}`, false},
+		{"Read and write", AssertCeremony, LargeBlobInputs{Read: true, Write: []byte{1}}, `{}`, true},
+		{"Support in login", AssertCeremony, LargeBlobInputs{Support: LargeBlobSupportRequired}, `{}`, true},
+		{"Written without write", AssertCeremony, LargeBlobInputs{Read: true}, `{"largeBlob":{"written":true}}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var outputs AuthenticationExtensionsClientOutputs
+			if err := json.Unmarshal([]byte(tt.outputs), &outputs); err != nil {
+				t.Fatal(err)
+			}
+			requested := AuthenticationExtensions{ExtensionLargeBlob: rawInput(tt.input)}
+			_, err := VerifyExtensions(tt.ceremony, requested, outputs, &AuthenticatorData{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyExtensions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyExtensions_PRF(t *testing.T) {
+	enabled, err := cbor.Marshal(map[string]interface{}{ExtensionHMACSecret: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := cbor.Marshal(map[string]interface{}{ExtensionHMACSecret: []byte{1, 2, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eval := PRFInputs{Eval: &PRFValues{First: []byte("salt")}}
+
+	tests := []struct {
+		name           string
+		ceremony       CeremonyType
+		input          PRFInputs
+		outputs        string
+		extData        []byte
+		wantHMACSecret *HMACSecretOutput
+		wantErr        bool
+	}{
+		{"Enabled", CreateCeremony, PRFInputs{}, `{"prf":{"enabled":true}}`, enabled, &HMACSecretOutput{Enabled: boolPointer(true)}, false},
+		{"Results", AssertCeremony, eval, `{"prf":{"results":{"first":"AQID"}}}`, encrypted, &HMACSecretOutput{EncryptedOutput: []byte{1, 2, 3}}, false},
+		{"Results without salts", AssertCeremony, PRFInputs{}, `{"prf":{"results":{"first":"AQID"}}}`, nil, nil, true},
+		{"Enabled in login", AssertCeremony, eval, `{"prf":{"enabled":true}}`, nil, nil, true},
+		{"Boolean hmac-secret in login", AssertCeremony, eval, `{}`, enabled, nil, true},
+		{"evalByCredential in registration", CreateCeremony, PRFInputs{EvalByCredential: map[string]PRFValues{"AQID": {First: []byte("salt")}}}, `{}`, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var outputs AuthenticationExtensionsClientOutputs
+			if err := json.Unmarshal([]byte(tt.outputs), &outputs); err != nil {
+				t.Fatal(err)
+			}
+			authData := &AuthenticatorData{}
+			if tt.extData != nil {
+				authData.Flags = FlagHasExtensions
+				authData.ExtData = tt.extData
+			}
+			verified, err := VerifyExtensions(tt.ceremony, AuthenticationExtensions{ExtensionPRF: rawInput(tt.input)}, outputs, authData)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyExtensions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil || tt.wantHMACSecret == nil {
+				return
+			}
+			hmacSecret, ok := verified.HMACSecret()
+			if !ok || !reflect.DeepEqual(hmacSecret, tt.wantHMACSecret) {
+				t.Errorf("VerifyExtensions() hmac-secret = %+v, want %+v", hmacSecret, tt.wantHMACSecret)
+			}
+		})
+	}
+}
+
+func TestVerifyExtensions_CredProtectAndMinPinLength(t *testing.T) {
+	extData, err := cbor.Marshal(map[string]interface{}{ExtensionCredProtect: 3, ExtensionMinPinLength: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authData := &AuthenticatorData{Flags: FlagHasExtensions, ExtData: extData}
+	requested := AuthenticationExtensions{
+		ExtensionCredentialProtectionPolicy:        rawInput(CredentialProtectionUserVerificationRequired),
+		ExtensionEnforceCredentialProtectionPolicy: rawInput(true),
+		ExtensionMinPinLength:                      rawInput(true),
+	}
+
+	outputs, err := VerifyExtensions(CreateCeremony, requested, nil, authData)
+	if err != nil {
+		t.Fatalf("VerifyExtensions() error = %v", err)
+	}
+	if level, ok := outputs.CredProtect(); !ok || level != 3 {
+		t.Errorf("CredProtect() = %d, %v, want 3, true", level, ok)
+	}
+	if length, ok := outputs.MinPinLength(); !ok || length != 8 {
+		t.Errorf("MinPinLength() = %d, %v, want 8, true", length, ok)
+	}
+
+	if _, err := VerifyExtensions(AssertCeremony, requested, nil, authData); err == nil {
+		t.Error("VerifyExtensions() for a login error = nil, want error")
+	}
+	requested[ExtensionCredentialProtectionPolicy] = rawInput("unknown")
+	if _, err := VerifyExtensions(CreateCeremony, requested, nil, authData); err == nil {
+		t.Error("VerifyExtensions() with unknown credentialProtectionPolicy error = nil, want error")
+	}
+}
+
+func boolPointer(b bool) *bool {
+	return &b
+}
+
+// rawInput encodes an extension input for the requested extensions of a test
+func rawInput(input interface{}) json.RawMessage {
+	encoded, err := json.Marshal(input)
+	if err != nil {
+		panic(err)
+	}
+	return encoded
+}
+
+// testExtension is registered for the tests in this file, it expects its inputs to be echoed by the authenticator
+type testExtension struct{}
+
+func (testExtension) Identifier() string {
+	return "testExtension"
+}
+
+func (testExtension) AuthenticatorIdentifier() string {
+	return "testAuthenticatorExtension"
+}
+
+func (testExtension) ClientInput(ceremony CeremonyType, input interface{}) (json.RawMessage, error) {
+	var value string
+	if err := decodeExtensionInput(input, &value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+func (testExtension) ParseClientOutput(output json.RawMessage) (interface{}, error) {
+	var value string
+	err := json.Unmarshal(output, &value)
+	return value, err
+}
+
+func (testExtension) ParseAuthenticatorOutput(output cbor.RawMessage) (interface{}, error) {
+	var value string
+	err := cbor.Unmarshal(output, &value)
+	return value, err
+}
+
+func (testExtension) Validate(ceremony CeremonyType, input json.RawMessage, output ExtensionOutput) error {
+	var value string
+	if err := json.Unmarshal(input, &value); err != nil {
+		return err
+	}
+	if output.Authenticator != nil && output.Authenticator != value {
+		return ErrExtension.WithDetails("Authenticator output does not match input")
+	}
+	return nil
+}
+
+// registerTestExtension registers the extension until the end of the test
+func registerTestExtension(t *testing.T, extension Extension) {
+	if err := RegisterExtension(extension); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		extensionRegistryMu.Lock()
+		defer extensionRegistryMu.Unlock()
+		delete(extensionRegistry, extension.Identifier())
+		delete(extensionsByAuthenticatorIdentifier, extension.AuthenticatorIdentifier())
+	})
+}
+
+func TestVerifyExtensions(t *testing.T) {
+	registerTestExtension(t, testExtension{})
+
+	authData := func(outputs map[string]interface{}) *AuthenticatorData {
+		if outputs == nil {
+			return &AuthenticatorData{Flags: FlagUserPresent}
+		}
+		extData, err := cbor.Marshal(outputs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &AuthenticatorData{Flags: FlagUserPresent | FlagHasExtensions, ExtData: extData}
+	}
+
+	tests := []struct {
+		name              string
+		ceremony          CeremonyType
+		requested         AuthenticationExtensions
+		clientOutputs     string
+		authData          *AuthenticatorData
+		wantClient        map[string]interface{}
+		wantAuthenticator map[string]interface{}
+		wantErr           bool
+	}{
+		{
+			name:     "No extensions",
+			ceremony: AssertCeremony,
+			authData: authData(nil),
+		},
+		{
+			name:              "Registered extension",
+			ceremony:          AssertCeremony,
+			requested:         AuthenticationExtensions{"testExtension": rawInput("value")},
+			clientOutputs:     `{"testExtension":"client"}`,
+			authData:          authData(map[string]interface{}{"testAuthenticatorExtension": "value"}),
+			wantClient:        map[string]interface{}{"testExtension": "client"},
+			wantAuthenticator: map[string]interface{}{"testExtension": "value"},
+		},
+		{
+			name:      "Registered extension fails validation",
+			ceremony:  AssertCeremony,
+			requested: AuthenticationExtensions{"testExtension": rawInput("value")},
+			authData:  authData(map[string]interface{}{"testAuthenticatorExtension": "other"}),
+			wantErr:   true,
+		},
+		{
+			name:          "Invalid client output",
+			ceremony:      AssertCeremony,
+			requested:     AuthenticationExtensions{"testExtension": rawInput("value")},
+			clientOutputs: `{"testExtension":42}`,
+			authData:      authData(nil),
+			wantErr:       true,
+		},
+		{
+			name:          "Client output not requested",
+			ceremony:      AssertCeremony,
+			clientOutputs: `{"testExtension":"client"}`,
+			authData:      authData(nil),
+			wantErr:       true,
+		},
+		{
+			name:     "Authenticator output not requested",
+			ceremony: AssertCeremony,
+			authData: authData(map[string]interface{}{"testAuthenticatorExtension": "value"}),
+			wantErr:  true,
+		},
+		{
+			name:          "Unregistered extension",
+			ceremony:      AssertCeremony,
+			requested:     AuthenticationExtensions{"unknown": rawInput(true)},
+			clientOutputs: `{"unknown":{"a":1}}`,
+			authData:      authData(nil),
+			wantClient:    map[string]interface{}{"unknown": json.RawMessage(`{"a":1}`)},
+		},
+		{
+			name:     "Invalid authenticator extension data",
+			ceremony: AssertCeremony,
+			authData: &AuthenticatorData{Flags: FlagUserPresent | FlagHasExtensions, ExtData: []byte{0xff}},
+			wantErr:  true,
+		},
+		{
+			name:          "credProps during registration",
+			ceremony:      CreateCeremony,
+			requested:     AuthenticationExtensions{ExtensionCredProps: rawInput(true)},
+			clientOutputs: `{"credProps":{"rk":true}}`,
+			authData:      authData(nil),
+			wantClient:    map[string]interface{}{ExtensionCredProps: &CredentialPropertiesOutput{ResidentKey: boolPointer(true)}},
+		},
+		{
+			name:          "credProps during login",
+			ceremony:      AssertCeremony,
+			requested:     AuthenticationExtensions{ExtensionCredProps: rawInput(true)},
+			clientOutputs: `{"credProps":{"rk":true}}`,
+			authData:      authData(nil),
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var clientOutputs AuthenticationExtensionsClientOutputs
+			if tt.clientOutputs != "" {
+				if err := json.Unmarshal([]byte(tt.clientOutputs), &clientOutputs); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			outputs, err := VerifyExtensions(tt.ceremony, tt.requested, clientOutputs, tt.authData)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyExtensions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for identifier, want := range tt.wantClient {
+				if got := outputs[identifier].Client; !reflect.DeepEqual(got, want) {
+					t.Errorf("VerifyExtensions() client output %s = %#v, want %#v", identifier, got, want)
+				}
+			}
+			for identifier, want := range tt.wantAuthenticator {
+				if got := outputs[identifier].Authenticator; !reflect.DeepEqual(got, want) {
+					t.Errorf("VerifyExtensions() authenticator output %s = %#v, want %#v", identifier, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterExtension_DuplicateAuthenticatorIdentifier(t *testing.T) {
+	if err := RegisterExtension(conflictingExtension{}); err == nil {
+		t.Fatal("RegisterExtension() error = nil, want error for the hmac-secret identifier of prf")
+	}
+	if extension, ok := registeredExtension("conflictingExtension"); ok {
+		t.Fatalf("RegisterExtension() registered %T despite the error", extension)
+	}
+	if got := clientIdentifier(ExtensionHMACSecret); got != ExtensionPRF {
+		t.Errorf("clientIdentifier() = %s, want %s", got, ExtensionPRF)
+	}
+
+	// Replacing a handler for the same identifier is allowed
+	registerTestExtension(t, testExtension{})
+	if err := RegisterExtension(testExtension{}); err != nil {
+		t.Errorf("RegisterExtension() for the same extension error = %v", err)
+	}
+}
+
+// conflictingExtension uses the authenticator identifier of the prf extension
+type conflictingExtension struct {
+	testExtension
+}
+
+func (conflictingExtension) Identifier() string {
+	return "conflictingExtension"
+}
+
+func (conflictingExtension) AuthenticatorIdentifier() string {
+	return ExtensionHMACSecret
+}
+
+func TestExtensionInput(t *testing.T) {
+	tests := []struct {
+		name       string
+		ceremony   CeremonyType
+		identifier string
+		input      interface{}
+		want       string
+		wantErr    bool
+	}{
+		{"appid", AssertCeremony, ExtensionAppID, "https://example.com/u2f/app-id.json", `"https://example.com/u2f/app-id.json"`, false},
+		{"appid in registration", CreateCeremony, ExtensionAppID, "https://example.com/u2f/app-id.json", "", true},
+		{"Empty appid", AssertCeremony, ExtensionAppID, "", "", true},
+		{"credProps", CreateCeremony, ExtensionCredProps, true, `true`, false},
+		{"credProps false", CreateCeremony, ExtensionCredProps, false, "", true},
+		{"credProtect", CreateCeremony, ExtensionCredentialProtectionPolicy, CredentialProtectionUserVerificationRequired, `"userVerificationRequired"`, false},
+		{"Unknown credProtect", CreateCeremony, ExtensionCredentialProtectionPolicy, "unknown", "", true},
+		{"largeBlob read", AssertCeremony, ExtensionLargeBlob, LargeBlobInputs{Read: true}, `{"read":true}`, false},
+		{"largeBlob read in registration", CreateCeremony, ExtensionLargeBlob, LargeBlobInputs{Read: true}, "", true},
+		{"minPinLength in login", AssertCeremony, ExtensionMinPinLength, true, "", true},
+		{"prf", AssertCeremony, ExtensionPRF, PRFInputs{Eval: &PRFValues{First: []byte{1, 2, 3}}}, `{"eval":{"first":"AQID"}}`, false},
+		{"Raw prf", AssertCeremony, ExtensionPRF, json.RawMessage(`{"eval":{"first":"AQID"}}`), `{"eval":{"first":"AQID"}}`, false},
+		{"Invalid prf", AssertCeremony, ExtensionPRF, json.RawMessage(`{"eval":1}`), "", true},
+		{"txAuthSimple", AssertCeremony, ExtensionTxAuthSimple, "Pay 10 EUR", `"Pay 10 EUR"`, false},
+		{"txAuthSimple in registration", CreateCeremony, ExtensionTxAuthSimple, "Pay 10 EUR", "", true},
+		{"Unregistered extension", CreateCeremony, "unknown", map[string]int{"a": 1}, `{"a":1}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtensionInput(tt.ceremony, tt.identifier, tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExtensionInput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && string(got) != tt.want {
+				t.Errorf("ExtensionInput() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildExtensionInputs(t *testing.T) {
+	extensions, err := AuthenticationExtensions{}.With(CreateCeremony, ExtensionCredProps, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extensions["unknown"] = json.RawMessage(`{ "a": 1 }`)
+
+	built, err := BuildExtensionInputs(CreateCeremony, extensions)
+	if err != nil {
+		t.Fatalf("BuildExtensionInputs() error = %v", err)
+	}
+	want := AuthenticationExtensions{ExtensionCredProps: json.RawMessage(`true`), "unknown": json.RawMessage(`{"a":1}`)}
+	if !reflect.DeepEqual(built, want) {
+		t.Errorf("BuildExtensionInputs() = %s, want %s", built, want)
+	}
+
+	if _, err := BuildExtensionInputs(AssertCeremony, extensions); err == nil {
+		t.Error("BuildExtensionInputs() with credProps in a login error = nil, want error")
+	}
+}
+
+func TestTransaction_Verify(t *testing.T) {
+	confirmed := ExtensionOutputs{ExtensionTxAuthSimple: {Authenticator: "Pay 100 EUR to Alice"}}
+	issued := Transaction{Text: "Pay 100 EUR to Alice"}
+	challenge := base64.RawURLEncoding.EncodeToString(issued.Challenge(make([]byte, 32)))
+	tests := []struct {
+		name      string
+		tx        Transaction
+		challenge string
+		outputs   ExtensionOutputs
+		wantErr   bool
+	}{
+		{"Confirmed", Transaction{Text: "Pay 100 EUR to Alice"}, challenge, confirmed, false},
+		{"Confirmation missing", Transaction{Text: "Pay 100 EUR to Alice"}, challenge, nil, true},
+		{"Optional confirmation", Transaction{Text: "Pay 100 EUR to Alice", OptionalConfirmation: true}, challenge, confirmed, false},
+		{"Optional confirmation missing", Transaction{Text: "Pay 100 EUR to Alice", OptionalConfirmation: true}, challenge, nil, false},
+		{"Different transaction confirmed", Transaction{Text: "Pay 900 EUR to Mallory", OptionalConfirmation: true}, challenge, confirmed, true},
+		{"Challenge of another transaction", Transaction{Text: "Pay 900 EUR to Mallory", OptionalConfirmation: true}, challenge, nil, true},
+		{"Challenge without transaction", Transaction{Text: "Pay 100 EUR to Alice"}, base64.RawURLEncoding.EncodeToString(make([]byte, 32)), confirmed, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.tx.Verify(tt.challenge, tt.outputs); (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
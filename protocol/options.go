@@ -1,6 +1,8 @@
 package protocol
 
 import (
+	"encoding/json"
+
 	"github.com/teamhanko/webauthn-go/protocol/webauthncose"
 )
 
@@ -8,10 +10,31 @@ type CredentialCreation struct {
 	Response PublicKeyCredentialCreationOptions `json:"publicKey"`
 }
 
+// CredentialAssertion mirrors the CredentialRequestOptions dictionary of the Credential Management API, so it can be
+// passed to navigator.credentials.get() as is. An AbortSignal can be added as "signal" on the client, which is needed
+// to cancel a pending conditional mediation request before starting another ceremony.
 type CredentialAssertion struct {
 	Response PublicKeyCredentialRequestOptions `json:"publicKey"`
+	// Mediation controls how the browser mediates the request, see CredentialMediationRequirement
+	Mediation CredentialMediationRequirement `json:"mediation,omitempty"`
 }
 
+// CredentialMediationRequirement describes how the browser should interact with the user for a credential request.
+// See §2.3.2. https://w3c.github.io/webappsec-credential-management/#mediation-requirements
+type CredentialMediationRequirement string
+
+const (
+	// MediationSilent The user is not shown any UI, the request fails if this is not possible
+	MediationSilent CredentialMediationRequirement = "silent"
+	// MediationOptional The browser decides if the user needs to interact. This is the default.
+	MediationOptional CredentialMediationRequirement = "optional"
+	// MediationConditional Discoverable credentials are offered in the autofill UI of a form field, the request
+	// only finishes when the user picks one of them
+	MediationConditional CredentialMediationRequirement = "conditional"
+	// MediationRequired The user always has to interact with the browser
+	MediationRequired CredentialMediationRequirement = "required"
+)
+
 // In order to create a Credential via create(), the caller specifies a few parameters in a CredentialCreationOptions object.
 // See §5.4. Options for Credential Creation https://www.w3.org/TR/webauthn-1/#dictionary-makecredentialoptions
 type PublicKeyCredentialCreationOptions struct {
@@ -36,6 +59,10 @@ type PublicKeyCredentialRequestOptions struct {
 	AllowedCredentials []CredentialDescriptor      `json:"allowCredentials,omitempty"`
 	UserVerification   UserVerificationRequirement `json:"userVerification,omitempty"` // Default is "preferred"
 	Extensions         AuthenticationExtensions    `json:"extensions,omitempty"`
+
+	// Mediation is not a member of the dictionary, the login of the webauthn package moves it to
+	// CredentialAssertion.Mediation, so that it can be set with a LoginOption
+	Mediation CredentialMediationRequirement `json:"-"`
 }
 
 // This dictionary contains the attributes that are specified by a caller when referring to a public
@@ -74,8 +101,8 @@ const (
 // AuthenticationExtensions - referred to as AuthenticationExtensionsClientInputs in the
 // spec document, this member contains additional parameters requesting additional processing
 // by the client and authenticator.
-// This is currently under development
-type AuthenticationExtensions map[string]interface{}
+// The inputs are kept in their JSON encoding, see ExtensionInput for creating them from the typed inputs.
+type AuthenticationExtensions map[string]json.RawMessage
 
 // WebAuthn Relying Parties may use the AuthenticatorSelectionCriteria dictionary to specify their requirements
 // regarding authenticator attributes. See §5.4.4. Authenticator Selection Criteria
@@ -88,12 +115,49 @@ type AuthenticatorSelection struct {
 	// credentials. If the parameter is set to true, the authenticator MUST create a client-side-resident
 	// public key credential source when creating a public key credential.
 	RequireResidentKey *bool `json:"requireResidentKey,omitempty"`
+	// ResidentKey this member describes the extent to which the Relying Party desires to create a client-side
+	// discoverable credential. It supersedes RequireResidentKey, which is kept for older clients, see
+	// SyncResidentKey.
+	ResidentKey ResidentKeyRequirement `json:"residentKey,omitempty"`
 	// UserVerification This member describes the Relying Party's requirements regarding user verification for
 	// the create() operation. Eligible authenticators are filtered to only those capable of satisfying this
 	// requirement.
 	UserVerification UserVerificationRequirement `json:"userVerification,omitempty"`
 }
 
+// SyncResidentKey makes ResidentKey and RequireResidentKey consistent. If ResidentKey is not set, it is derived from
+// RequireResidentKey, afterwards RequireResidentKey is set to true if and only if a resident key is required, as
+// specified in §5.4.4.
+func (selection *AuthenticatorSelection) SyncResidentKey() {
+	if selection.ResidentKey == "" {
+		selection.ResidentKey = ResidentKeyRequirementDiscouraged
+		if selection.RequireResidentKey != nil && *selection.RequireResidentKey {
+			selection.ResidentKey = ResidentKeyRequirementRequired
+		}
+	}
+	requireResidentKey := selection.ResidentKey == ResidentKeyRequirementRequired
+	if selection.RequireResidentKey == nil || *selection.RequireResidentKey != requireResidentKey {
+		selection.RequireResidentKey = &requireResidentKey
+	}
+}
+
+// ResidentKeyRequirement describes the Relying Party's requirements for client-side discoverable credentials,
+// which are also known as resident keys. See §5.4.6. Resident Key Requirement Enumeration
+// https://www.w3.org/TR/webauthn-2/#enum-residentKeyRequirement
+type ResidentKeyRequirement string
+
+const (
+	// ResidentKeyRequirementDiscouraged The Relying Party prefers creating a server-side credential, but will
+	// accept a client-side discoverable credential
+	ResidentKeyRequirementDiscouraged ResidentKeyRequirement = "discouraged"
+	// ResidentKeyRequirementPreferred The Relying Party strongly prefers creating a client-side discoverable
+	// credential, but will accept a server-side credential
+	ResidentKeyRequirementPreferred ResidentKeyRequirement = "preferred"
+	// ResidentKeyRequirementRequired The Relying Party requires a client-side discoverable credential and fails the
+	// ceremony if one can not be created
+	ResidentKeyRequirementRequired ResidentKeyRequirement = "required"
+)
+
 // WebAuthn Relying Parties may use AttestationConveyancePreference to specify their preference regarding
 // attestation conveyance during credential generation. See §5.4.6. https://www.w3.org/TR/webauthn-1/#attestation-convey
 type ConveyancePreference string
@@ -134,3 +198,34 @@ const (
 	StatusOk     ServerResponseStatus = "ok"
 	StatusFailed ServerResponseStatus = "failed"
 )
+
+// ServerPublicKeyCredentialCreationOptionsRequest is the request for registration options of the server API which
+// the FIDO conformance tools use to test a relying party.
+// See §7.1. https://fidoalliance.org/specs/fido-v2.0-rd-20180702/fido-server-v2.0-rd-20180702.html
+type ServerPublicKeyCredentialCreationOptionsRequest struct {
+	Username               string                   `json:"username"`
+	DisplayName            string                   `json:"displayName"`
+	AuthenticatorSelection *AuthenticatorSelection  `json:"authenticatorSelection,omitempty"`
+	Attestation            ConveyancePreference     `json:"attestation,omitempty"`
+	Extensions             AuthenticationExtensions `json:"extensions,omitempty"`
+}
+
+// ServerPublicKeyCredentialCreationOptionsResponse are the registration options of the conformance server API
+type ServerPublicKeyCredentialCreationOptionsResponse struct {
+	ServerResponse
+	PublicKeyCredentialCreationOptions
+}
+
+// ServerPublicKeyCredentialGetOptionsRequest is the request for login options of the conformance server API
+// See §7.3. https://fidoalliance.org/specs/fido-v2.0-rd-20180702/fido-server-v2.0-rd-20180702.html
+type ServerPublicKeyCredentialGetOptionsRequest struct {
+	Username         string                      `json:"username"`
+	UserVerification UserVerificationRequirement `json:"userVerification,omitempty"`
+	Extensions       AuthenticationExtensions    `json:"extensions,omitempty"`
+}
+
+// ServerPublicKeyCredentialGetOptionsResponse are the login options of the conformance server API
+type ServerPublicKeyCredentialGetOptionsResponse struct {
+	ServerResponse
+	PublicKeyCredentialRequestOptions
+}
@@ -54,3 +54,56 @@ func TestPublicKeyCredentialRequestOptions_GetAllowedCredentialIDs(t *testing.T)
 		})
 	}
 }
+
+func TestAuthenticatorSelection_SyncResidentKey(t *testing.T) {
+	requireResidentKey := true
+	noResidentKey := false
+	tests := []struct {
+		name                   string
+		selection              AuthenticatorSelection
+		wantResidentKey        ResidentKeyRequirement
+		wantRequireResidentKey bool
+	}{
+		{
+			"Nothing set",
+			AuthenticatorSelection{},
+			ResidentKeyRequirementDiscouraged,
+			false,
+		},
+		{
+			"Legacy requireResidentKey",
+			AuthenticatorSelection{RequireResidentKey: &requireResidentKey},
+			ResidentKeyRequirementRequired,
+			true,
+		},
+		{
+			"Legacy requireResidentKey false",
+			AuthenticatorSelection{RequireResidentKey: &noResidentKey},
+			ResidentKeyRequirementDiscouraged,
+			false,
+		},
+		{
+			"Resident key required",
+			AuthenticatorSelection{ResidentKey: ResidentKeyRequirementRequired, RequireResidentKey: &noResidentKey},
+			ResidentKeyRequirementRequired,
+			true,
+		},
+		{
+			"Resident key preferred",
+			AuthenticatorSelection{ResidentKey: ResidentKeyRequirementPreferred, RequireResidentKey: &requireResidentKey},
+			ResidentKeyRequirementPreferred,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.selection.SyncResidentKey()
+			if tt.selection.ResidentKey != tt.wantResidentKey {
+				t.Errorf("AuthenticatorSelection.ResidentKey = %v, want %v", tt.selection.ResidentKey, tt.wantResidentKey)
+			}
+			if tt.selection.RequireResidentKey == nil || *tt.selection.RequireResidentKey != tt.wantRequireResidentKey {
+				t.Errorf("AuthenticatorSelection.RequireResidentKey = %v, want %v", tt.selection.RequireResidentKey, tt.wantRequireResidentKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// androidAPKKeyHashPrefix is the prefix of the origin of Android apps, followed by the base64url encoded SHA-256
+// hash of the certificate the APK was signed with
+const androidAPKKeyHashPrefix = "android:apk-key-hash:"
+
+// OriginRule decides whether an origin in the client data is allowed. The rules are written as strings in the
+// allowed origins and parsed with ParseOriginRule.
+type OriginRule interface {
+	// Match returns nil if the origin is allowed by the rule, otherwise an error which explains why it was rejected
+	Match(origin string) error
+	// String returns the canonical form of the rule, which ParseOriginRule parses into the same rule
+	String() string
+}
+
+// ParseOriginRule parses an allowed origin:
+//   - "https://example.com" or "https://example.com:8443" allows exactly this origin, the port defaults to the
+//     default port of the scheme
+//   - "https://*.example.com" allows all subdomains of example.com, but not example.com itself
+//   - "android:apk-key-hash:<hash>" allows the Android app signed with the certificate of the hash
+//
+// Origins with other schemes are compared as they are.
+func ParseOriginRule(rule string) (OriginRule, error) {
+	if strings.HasPrefix(rule, androidAPKKeyHashPrefix) {
+		return parseAndroidAPKKeyHashOrigin(rule)
+	}
+
+	u, err := url.Parse(rule)
+	if err != nil {
+		return nil, fmt.Errorf("origin %s is not a valid URL: %w", rule, err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return ExactOrigin(rule), nil
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("origin %s has no host", rule)
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := effectivePort(scheme, u.Port())
+	if strings.HasPrefix(host, "*.") {
+		domain := strings.TrimPrefix(host, "*.")
+		if domain == "" || strings.Contains(domain, "*") {
+			return nil, fmt.Errorf("origin %s must only contain a wildcard for the leftmost label", rule)
+		}
+		return WildcardOrigin{Scheme: scheme, Domain: domain, Port: port}, nil
+	}
+	if strings.Contains(host, "*") {
+		return nil, fmt.Errorf("origin %s must only contain a wildcard for the leftmost label", rule)
+	}
+	return WebOrigin{Scheme: scheme, Host: host, Port: port}, nil
+}
+
+// OriginRules are the parsed allowed origins, which are parsed once instead of for every verification
+type OriginRules []OriginRule
+
+// ParseOriginRules parses the allowed origins with ParseOriginRule
+func ParseOriginRules(origins []string) (OriginRules, error) {
+	rules := make(OriginRules, 0, len(origins))
+	for _, origin := range origins {
+		rule, err := ParseOriginRule(origin)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Match checks the origin against the rules. The error explains for every rule why the origin was rejected.
+func (rules OriginRules) Match(origin string) error {
+	var reasons []string
+	for _, rule := range rules {
+		err := rule.Match(origin)
+		if err == nil {
+			return nil
+		}
+		reasons = append(reasons, rule.String()+": "+err.Error())
+	}
+	return rejectedOrigin(origin, reasons)
+}
+
+// MatchOrigin parses the allowed origins and checks the origin against them like OriginRules.Match. Allowed origins
+// which can not be parsed allow no origin.
+func MatchOrigin(origin string, allowedOrigins []string) error {
+	var reasons []string
+	for _, allowed := range allowedOrigins {
+		rule, err := ParseOriginRule(allowed)
+		if err != nil {
+			reasons = append(reasons, err.Error())
+			continue
+		}
+		err = rule.Match(origin)
+		if err == nil {
+			return nil
+		}
+		reasons = append(reasons, rule.String()+": "+err.Error())
+	}
+	return rejectedOrigin(origin, reasons)
+}
+
+func rejectedOrigin(origin string, reasons []string) error {
+	if len(reasons) == 0 {
+		reasons = append(reasons, "no origins are allowed")
+	}
+	return fmt.Errorf("origin %s was rejected: %s", origin, strings.Join(reasons, "; "))
+}
+
+// WebOrigin allows exactly one web origin
+type WebOrigin struct {
+	Scheme string
+	Host   string
+	// Port is always set, the default port of the scheme is used if the origin has no port
+	Port string
+}
+
+func (o WebOrigin) Match(origin string) error {
+	scheme, host, port, err := parseWebOrigin(origin)
+	if err != nil {
+		return err
+	}
+	if scheme != o.Scheme {
+		return fmt.Errorf("scheme %s does not match %s", scheme, o.Scheme)
+	}
+	if host != o.Host {
+		return fmt.Errorf("host %s does not match %s", host, o.Host)
+	}
+	if port != o.Port {
+		return fmt.Errorf("port %s does not match %s", port, o.Port)
+	}
+	return nil
+}
+
+func (o WebOrigin) String() string {
+	return o.Scheme + "://" + hostWithPort(o.Scheme, o.Host, o.Port)
+}
+
+// WildcardOrigin allows all subdomains of Domain, e.g. the rule https://*.example.com allows
+// https://login.example.com and https://a.b.example.com, but not https://example.com
+type WildcardOrigin struct {
+	Scheme string
+	Domain string
+	// Port is always set, the default port of the scheme is used if the origin has no port
+	Port string
+}
+
+func (o WildcardOrigin) Match(origin string) error {
+	scheme, host, port, err := parseWebOrigin(origin)
+	if err != nil {
+		return err
+	}
+	if scheme != o.Scheme {
+		return fmt.Errorf("scheme %s does not match %s", scheme, o.Scheme)
+	}
+	if !strings.HasSuffix(host, "."+o.Domain) {
+		return fmt.Errorf("host %s is not a subdomain of %s", host, o.Domain)
+	}
+	if port != o.Port {
+		return fmt.Errorf("port %s does not match %s", port, o.Port)
+	}
+	return nil
+}
+
+func (o WildcardOrigin) String() string {
+	return o.Scheme + "://" + hostWithPort(o.Scheme, "*."+o.Domain, o.Port)
+}
+
+// VerifyRelyingPartyID checks that the wildcard only allows origins which can use the RP ID, i.e. the domain is the
+// RP ID or one of its subdomains
+func (o WildcardOrigin) VerifyRelyingPartyID(relyingPartyID string) error {
+	relyingPartyID = strings.ToLower(relyingPartyID)
+	if o.Domain != relyingPartyID && !strings.HasSuffix(o.Domain, "."+relyingPartyID) {
+		return fmt.Errorf("origin %s allows subdomains outside of the RP ID %s", o.String(), relyingPartyID)
+	}
+	return nil
+}
+
+// AndroidAPKKeyHashOrigin allows the Android app whose APK is signed with the certificate of KeyHash
+type AndroidAPKKeyHashOrigin struct {
+	// KeyHash is the SHA-256 hash of the signing certificate
+	KeyHash []byte
+}
+
+// NewAndroidAPKKeyHashOrigin creates the origin of an Android app from the SHA-256 fingerprint of its signing
+// certificate, as printed by keytool or apksigner, e.g. "FA:C6:17:45:DC:09:03:78:...".
+func NewAndroidAPKKeyHashOrigin(fingerprint string) (*AndroidAPKKeyHashOrigin, error) {
+	keyHash, err := hex.DecodeString(strings.ReplaceAll(fingerprint, ":", ""))
+	if err != nil {
+		return nil, fmt.Errorf("fingerprint %s is not hex encoded: %w", fingerprint, err)
+	}
+	if len(keyHash) != 32 {
+		return nil, fmt.Errorf("fingerprint %s is not a SHA-256 fingerprint", fingerprint)
+	}
+	return &AndroidAPKKeyHashOrigin{KeyHash: keyHash}, nil
+}
+
+func parseAndroidAPKKeyHashOrigin(origin string) (*AndroidAPKKeyHashOrigin, error) {
+	encoded := strings.TrimRight(strings.TrimPrefix(origin, androidAPKKeyHashPrefix), "=")
+	keyHash, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("origin %s does not contain a base64url encoded key hash", origin)
+	}
+	if len(keyHash) != 32 {
+		return nil, fmt.Errorf("origin %s does not contain a SHA-256 key hash", origin)
+	}
+	return &AndroidAPKKeyHashOrigin{KeyHash: keyHash}, nil
+}
+
+func (o AndroidAPKKeyHashOrigin) Match(origin string) error {
+	if !strings.HasPrefix(origin, androidAPKKeyHashPrefix) {
+		return fmt.Errorf("origin is not the origin of an Android app")
+	}
+	app, err := parseAndroidAPKKeyHashOrigin(origin)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(app.KeyHash, o.KeyHash) {
+		return fmt.Errorf("app is signed with a different certificate")
+	}
+	return nil
+}
+
+func (o AndroidAPKKeyHashOrigin) String() string {
+	return androidAPKKeyHashPrefix + base64.RawURLEncoding.EncodeToString(o.KeyHash)
+}
+
+// ExactOrigin allows an origin with a scheme other than http and https, which is compared case-insensitively
+type ExactOrigin string
+
+func (o ExactOrigin) Match(origin string) error {
+	if !strings.EqualFold(origin, string(o)) {
+		return fmt.Errorf("origin does not match")
+	}
+	return nil
+}
+
+func (o ExactOrigin) String() string {
+	return string(o)
+}
+
+// parseWebOrigin returns the lower case scheme and host and the effective port of an http or https origin
+func parseWebOrigin(origin string) (scheme, host, port string, err error) {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return "", "", "", fmt.Errorf("origin is not a valid URL")
+	}
+	scheme = strings.ToLower(u.Scheme)
+	if scheme != "https" && scheme != "http" {
+		return "", "", "", fmt.Errorf("scheme %s is not a web scheme", u.Scheme)
+	}
+	return scheme, strings.ToLower(u.Hostname()), effectivePort(scheme, u.Port()), nil
+}
+
+func effectivePort(scheme, port string) string {
+	if port != "" {
+		return port
+	}
+	if scheme == "http" {
+		return "80"
+	}
+	return "443"
+}
+
+func hostWithPort(scheme, host, port string) string {
+	if port == effectivePort(scheme, "") {
+		if strings.Contains(host, ":") {
+			return "[" + host + "]"
+		}
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,144 @@
+package protocol
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testAndroidFingerprint = "FA:C6:17:45:DC:09:03:78:6F:B9:ED:E6:2A:96:2B:39:9F:73:48:F0:BB:6F:89:9B:83:32:66:75:91:03:3B:9C"
+
+func TestParseOriginRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		want    string
+		wantErr bool
+	}{
+		{rule: "https://example.com", want: "https://example.com"},
+		{rule: "HTTPS://Example.com:443/path", want: "https://example.com"},
+		{rule: "https://example.com:8443", want: "https://example.com:8443"},
+		{rule: "http://localhost:80", want: "http://localhost"},
+		{rule: "https://*.example.com", want: "https://*.example.com"},
+		{rule: "https://*.example.com:8443", want: "https://*.example.com:8443"},
+		{rule: "android:apk-key-hash:-sYXRdwJA3hvue3mKpYrOZ9zSPC7b4mbgzJmdZEDO5w", want: "android:apk-key-hash:-sYXRdwJA3hvue3mKpYrOZ9zSPC7b4mbgzJmdZEDO5w"},
+		{rule: "ios:bundle-id:com.example.app", want: "ios:bundle-id:com.example.app"},
+		{rule: "https://", wantErr: true},
+		{rule: "https://login.*.example.com", wantErr: true},
+		{rule: "https://*.*.example.com", wantErr: true},
+		{rule: "android:apk-key-hash:not-a-hash", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := ParseOriginRule(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOriginRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && rule.String() != tt.want {
+				t.Errorf("ParseOriginRule() = %s, want %s", rule.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchOrigin(t *testing.T) {
+	android, err := NewAndroidAPKKeyHashOrigin(testAndroidFingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherAndroid := AndroidAPKKeyHashOrigin{KeyHash: bytes.Repeat([]byte{1}, 32)}
+
+	tests := []struct {
+		name       string
+		origin     string
+		allowed    []string
+		wantReason string
+	}{
+		{name: "Exact", origin: "https://example.com", allowed: []string{"https://example.com"}},
+		{name: "Case-insensitive", origin: "https://EXAMPLE.com", allowed: []string{"https://example.com"}},
+		{name: "Explicit default port", origin: "https://example.com:443", allowed: []string{"https://example.com"}},
+		{name: "Other port", origin: "https://example.com:8443", allowed: []string{"https://example.com"}, wantReason: "port 8443 does not match 443"},
+		{name: "Same port", origin: "https://example.com:8443", allowed: []string{"https://example.com:8443"}},
+		{name: "Other scheme", origin: "http://example.com", allowed: []string{"https://example.com"}, wantReason: "scheme http does not match https"},
+		{name: "Other host", origin: "https://evil.com", allowed: []string{"https://example.com"}, wantReason: "host evil.com does not match example.com"},
+		{name: "Subdomain", origin: "https://login.example.com", allowed: []string{"https://*.example.com"}},
+		{name: "Nested subdomain", origin: "https://a.b.example.com", allowed: []string{"https://*.example.com"}},
+		{name: "Wildcard domain itself", origin: "https://example.com", allowed: []string{"https://*.example.com"}, wantReason: "host example.com is not a subdomain of example.com"},
+		{name: "Suffix without dot", origin: "https://evilexample.com", allowed: []string{"https://*.example.com"}, wantReason: "is not a subdomain"},
+		{name: "Android app", origin: android.String(), allowed: []string{android.String()}},
+		{name: "Android app with padding", origin: android.String() + "=", allowed: []string{android.String()}},
+		{name: "Other Android app", origin: otherAndroid.String(), allowed: []string{android.String()}, wantReason: "app is signed with a different certificate"},
+		{name: "Web origin for Android app", origin: "https://example.com", allowed: []string{android.String()}, wantReason: "not the origin of an Android app"},
+		{name: "Android app for web origin", origin: android.String(), allowed: []string{"https://example.com"}, wantReason: "is not a web scheme"},
+		{name: "Other scheme exact", origin: "ios:bundle-id:com.example.app", allowed: []string{"ios:bundle-id:com.example.app"}},
+		{name: "Nothing allowed", origin: "https://example.com", wantReason: "no origins are allowed"},
+		{name: "Second rule", origin: "https://example.com", allowed: []string{"https://other.com", "https://example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := MatchOrigin(tt.origin, tt.allowed)
+			rulesErr := testOriginRules(tt.allowed...).Match(tt.origin)
+			if tt.wantReason == "" {
+				if err != nil || rulesErr != nil {
+					t.Errorf("MatchOrigin() error = %v, OriginRules.Match() error = %v, want nil", err, rulesErr)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantReason) {
+				t.Errorf("MatchOrigin() error = %v, want reason %s", err, tt.wantReason)
+			}
+			if rulesErr == nil || rulesErr.Error() != err.Error() {
+				t.Errorf("OriginRules.Match() error = %v, want %v", rulesErr, err)
+			}
+		})
+	}
+}
+
+func TestNewAndroidAPKKeyHashOrigin(t *testing.T) {
+	origin, err := NewAndroidAPKKeyHashOrigin(testAndroidFingerprint)
+	if err != nil {
+		t.Fatalf("NewAndroidAPKKeyHashOrigin() error = %v", err)
+	}
+	if want := "android:apk-key-hash:-sYXRdwJA3hvue3mKpYrOZ9zSPC7b4mbgzJmdZEDO5w"; origin.String() != want {
+		t.Errorf("NewAndroidAPKKeyHashOrigin() = %s, want %s", origin.String(), want)
+	}
+
+	for _, fingerprint := range []string{"FA:C6", "not hex"} {
+		if _, err := NewAndroidAPKKeyHashOrigin(fingerprint); err == nil {
+			t.Errorf("NewAndroidAPKKeyHashOrigin(%s) error = nil, want error", fingerprint)
+		}
+	}
+}
+
+func TestWildcardOrigin_VerifyRelyingPartyID(t *testing.T) {
+	tests := []struct {
+		rule    string
+		rpID    string
+		wantErr bool
+	}{
+		{"https://*.example.com", "example.com", false},
+		{"https://*.login.example.com", "example.com", false},
+		{"https://*.com", "example.com", true},
+		{"https://*.evilexample.com", "example.com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := ParseOriginRule(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = rule.(WildcardOrigin).VerifyRelyingPartyID(tt.rpID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyRelyingPartyID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// testOriginRules parses the allowed origins of a test
+func testOriginRules(origins ...string) OriginRules {
+	rules, err := ParseOriginRules(origins)
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
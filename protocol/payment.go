@@ -0,0 +1,140 @@
+package protocol
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// SecurePaymentConfirmationMethod is the identifier of the payment method which the merchant passes to the Payment
+// Request API together with a SecurePaymentConfirmationRequest
+const SecurePaymentConfirmationMethod = "secure-payment-confirmation"
+
+// SecurePaymentConfirmationRequest is the data of the secure-payment-confirmation payment method, which makes the
+// browser perform the payment.get ceremony. The total of the payment is passed in the details of the payment request.
+// https://www.w3.org/TR/secure-payment-confirmation/#sctn-securepaymentconfirmationrequest-dictionary
+type SecurePaymentConfirmationRequest struct {
+	Challenge     Challenge                   `json:"challenge"`
+	RPID          string                      `json:"rpId"`
+	CredentialIDs []URLEncodedBase64          `json:"credentialIds"`
+	Instrument    PaymentCredentialInstrument `json:"instrument"`
+	Timeout       int                         `json:"timeout,omitempty"`
+	PayeeName     string                      `json:"payeeName,omitempty"`
+	PayeeOrigin   string                      `json:"payeeOrigin,omitempty"`
+	Extensions    AuthenticationExtensions    `json:"extensions,omitempty"`
+}
+
+// CollectedClientAdditionalPaymentData is the payment member of the client data of a Secure Payment Confirmation.
+// It contains the details of the payment the browser showed to the user.
+// https://www.w3.org/TR/secure-payment-confirmation/#sctn-collectedclientadditionalpaymentdata-dictionary
+type CollectedClientAdditionalPaymentData struct {
+	// RPID is the ID of the Relying Party which registered the credential, i.e. the bank or payment service
+	RPID string `json:"rpId"`
+	// TopOrigin is the origin of the top-level frame, usually the merchant
+	TopOrigin   string                      `json:"topOrigin"`
+	PayeeName   string                      `json:"payeeName,omitempty"`
+	PayeeOrigin string                      `json:"payeeOrigin,omitempty"`
+	Total       PaymentCurrencyAmount       `json:"total"`
+	Instrument  PaymentCredentialInstrument `json:"instrument"`
+}
+
+// PaymentCurrencyAmount is the amount of a payment, the value is a decimal string like "10.00"
+type PaymentCurrencyAmount struct {
+	Currency string `json:"currency"`
+	Value    string `json:"value"`
+}
+
+var (
+	// currencyCodePattern matches a well-formed ISO 4217 currency code
+	currencyCodePattern = regexp.MustCompile(`^[A-Za-z]{3}$`)
+	// monetaryValuePattern matches a valid decimal monetary value of the Payment Request API, without a sign
+	// because the total of a payment must not be negative
+	monetaryValuePattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+)
+
+// Validate checks that the amount has a well-formed currency code and a non-negative decimal value, like the
+// Payment Request API requires for the total of a payment
+func (a *PaymentCurrencyAmount) Validate() error {
+	if !currencyCodePattern.MatchString(a.Currency) {
+		return ErrBadRequest.WithDetails("Payment total requires a currency code of three letters").WithInfo(a.Currency)
+	}
+	if !monetaryValuePattern.MatchString(a.Value) {
+		return ErrBadRequest.WithDetails("Payment total requires a decimal value like 10.00").WithInfo(a.Value)
+	}
+	return nil
+}
+
+// PaymentCredentialInstrument is the payment instrument shown to the user, e.g. a card
+type PaymentCredentialInstrument struct {
+	DisplayName string `json:"displayName"`
+	Icon        string `json:"icon"`
+}
+
+// Verify checks that the payment details confirmed by the user match the payment that was issued. The rpId must be
+// the RP ID of the Relying Party, the top origin is only compared if it was issued.
+func (p *CollectedClientAdditionalPaymentData) Verify(relyingPartyID string, issued *CollectedClientAdditionalPaymentData) error {
+	if p.RPID != relyingPartyID {
+		return paymentMismatch("rpId", relyingPartyID, p.RPID)
+	}
+	if issued == nil {
+		return ErrVerification.WithDetails("Error validating payment").WithInfo("No payment was issued")
+	}
+	if issued.TopOrigin != "" && p.TopOrigin != issued.TopOrigin {
+		return paymentMismatch("topOrigin", issued.TopOrigin, p.TopOrigin)
+	}
+	if p.PayeeName != issued.PayeeName {
+		return paymentMismatch("payeeName", issued.PayeeName, p.PayeeName)
+	}
+	if p.PayeeOrigin != issued.PayeeOrigin {
+		return paymentMismatch("payeeOrigin", issued.PayeeOrigin, p.PayeeOrigin)
+	}
+	if !strings.EqualFold(p.Total.Currency, issued.Total.Currency) || p.Total.Value != issued.Total.Value {
+		return paymentMismatch("total", issued.Total.Value+" "+issued.Total.Currency, p.Total.Value+" "+p.Total.Currency)
+	}
+	if p.Instrument != issued.Instrument {
+		return paymentMismatch("instrument", issued.Instrument.DisplayName, p.Instrument.DisplayName)
+	}
+	return nil
+}
+
+// Validate checks that a payment can be issued. The payee must be named or identified by its origin, which must be
+// an https origin, and the total must be a valid amount.
+func (p *CollectedClientAdditionalPaymentData) Validate() error {
+	if p.PayeeName == "" && p.PayeeOrigin == "" {
+		return ErrBadRequest.WithDetails("Payment requires a payee name or a payee origin")
+	}
+	if p.PayeeOrigin != "" {
+		origin, err := url.Parse(p.PayeeOrigin)
+		if err != nil || origin.Scheme != "https" || origin.Host == "" || (origin.Path != "" && origin.Path != "/") || origin.RawQuery != "" || origin.Fragment != "" {
+			return ErrBadRequest.WithDetails("Payee origin must be an https origin").WithInfo(p.PayeeOrigin)
+		}
+	}
+	if err := p.Total.Validate(); err != nil {
+		return err
+	}
+	if p.Instrument.DisplayName == "" || p.Instrument.Icon == "" {
+		return ErrBadRequest.WithDetails("Payment instrument requires a display name and an icon")
+	}
+	return nil
+}
+
+// merchantOrigins returns the rules for the payee origin and the top origin of the issued payment, which are the
+// origins a merchant starts the payment from
+func (p *CollectedClientAdditionalPaymentData) merchantOrigins() OriginRules {
+	var rules OriginRules
+	for _, origin := range []string{p.PayeeOrigin, p.TopOrigin} {
+		if origin == "" {
+			continue
+		}
+		if rule, err := ParseOriginRule(origin); err == nil {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+func paymentMismatch(member, expected, received string) error {
+	err := ErrVerification.WithDetails("Error validating payment")
+	return err.WithInfo(fmt.Sprintf("Expected %s: %s\n Received: %s\n", member, expected, received))
+}
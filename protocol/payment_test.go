@@ -0,0 +1,75 @@
+package protocol
+
+import "testing"
+
+func TestCollectedClientAdditionalPaymentData_Verify(t *testing.T) {
+	issued := CollectedClientAdditionalPaymentData{
+		TopOrigin:   "https://merchant.example",
+		PayeeName:   "Merchant",
+		PayeeOrigin: "https://merchant.example",
+		Total:       PaymentCurrencyAmount{Currency: "EUR", Value: "10.00"},
+		Instrument:  PaymentCredentialInstrument{DisplayName: "Card 1234", Icon: "https://bank.example/card.png"},
+	}
+	confirmed := func(change func(p *CollectedClientAdditionalPaymentData)) *CollectedClientAdditionalPaymentData {
+		p := issued
+		p.RPID = "bank.example"
+		change(&p)
+		return &p
+	}
+
+	tests := []struct {
+		name      string
+		confirmed *CollectedClientAdditionalPaymentData
+		issued    *CollectedClientAdditionalPaymentData
+		wantErr   bool
+	}{
+		{"Same payment", confirmed(func(p *CollectedClientAdditionalPaymentData) {}), &issued, false},
+		{"Currency case", confirmed(func(p *CollectedClientAdditionalPaymentData) { p.Total.Currency = "eur" }), &issued, false},
+		{"Other RP ID", confirmed(func(p *CollectedClientAdditionalPaymentData) { p.RPID = "evil.example" }), &issued, true},
+		{"Other top origin", confirmed(func(p *CollectedClientAdditionalPaymentData) { p.TopOrigin = "https://evil.example" }), &issued, true},
+		{"Other payee", confirmed(func(p *CollectedClientAdditionalPaymentData) { p.PayeeName = "Mallory" }), &issued, true},
+		{"Other payee origin", confirmed(func(p *CollectedClientAdditionalPaymentData) { p.PayeeOrigin = "https://evil.example" }), &issued, true},
+		{"Other amount", confirmed(func(p *CollectedClientAdditionalPaymentData) { p.Total.Value = "1000.00" }), &issued, true},
+		{"Other instrument", confirmed(func(p *CollectedClientAdditionalPaymentData) { p.Instrument.DisplayName = "Card 9999" }), &issued, true},
+		{"Nothing issued", confirmed(func(p *CollectedClientAdditionalPaymentData) {}), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.confirmed.Verify("bank.example", tt.issued)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCollectedClientAdditionalPaymentData_Validate(t *testing.T) {
+	instrument := PaymentCredentialInstrument{DisplayName: "Card 1234", Icon: "https://bank.example/card.png"}
+	total := PaymentCurrencyAmount{Currency: "EUR", Value: "10.00"}
+	tests := []struct {
+		name    string
+		payment CollectedClientAdditionalPaymentData
+		wantErr bool
+	}{
+		{"Payee name", CollectedClientAdditionalPaymentData{PayeeName: "Merchant", Total: total, Instrument: instrument}, false},
+		{"Payee origin", CollectedClientAdditionalPaymentData{PayeeOrigin: "https://merchant.example", Total: total, Instrument: instrument}, false},
+		{"No payee", CollectedClientAdditionalPaymentData{Total: total, Instrument: instrument}, true},
+		{"http payee origin", CollectedClientAdditionalPaymentData{PayeeOrigin: "http://merchant.example", Total: total, Instrument: instrument}, true},
+		{"Payee origin with path", CollectedClientAdditionalPaymentData{PayeeOrigin: "https://merchant.example/checkout", Total: total, Instrument: instrument}, true},
+		{"No instrument", CollectedClientAdditionalPaymentData{PayeeName: "Merchant", Total: total}, true},
+		{"Integer value", CollectedClientAdditionalPaymentData{PayeeName: "Merchant", Total: PaymentCurrencyAmount{Currency: "JPY", Value: "1000"}, Instrument: instrument}, false},
+		{"No total", CollectedClientAdditionalPaymentData{PayeeName: "Merchant", Instrument: instrument}, true},
+		{"No currency", CollectedClientAdditionalPaymentData{PayeeName: "Merchant", Total: PaymentCurrencyAmount{Value: "10.00"}, Instrument: instrument}, true},
+		{"Invalid currency", CollectedClientAdditionalPaymentData{PayeeName: "Merchant", Total: PaymentCurrencyAmount{Currency: "EURO", Value: "10.00"}, Instrument: instrument}, true},
+		{"No value", CollectedClientAdditionalPaymentData{PayeeName: "Merchant", Total: PaymentCurrencyAmount{Currency: "EUR"}, Instrument: instrument}, true},
+		{"Negative value", CollectedClientAdditionalPaymentData{PayeeName: "Merchant", Total: PaymentCurrencyAmount{Currency: "EUR", Value: "-10.00"}, Instrument: instrument}, true},
+		{"Malformed value", CollectedClientAdditionalPaymentData{PayeeName: "Merchant", Total: PaymentCurrencyAmount{Currency: "EUR", Value: "10,00"}, Instrument: instrument}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.payment.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
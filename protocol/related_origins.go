@@ -0,0 +1,33 @@
+package protocol
+
+import "fmt"
+
+// WellKnownWebAuthnPath is the path under which browsers request the related origins of an RP ID, i.e.
+// https://<rpid>/.well-known/webauthn
+const WellKnownWebAuthnPath = "/.well-known/webauthn"
+
+// RelatedOrigins is the JSON document served at WellKnownWebAuthnPath. It lists the origins which are allowed to use
+// the RP ID, although they are not the RP ID or one of its subdomains.
+// See https://w3c.github.io/webauthn/#sctn-related-origins
+type RelatedOrigins struct {
+	Origins []string `json:"origins"`
+}
+
+// NewRelatedOrigins creates the related origins document for the given origins. Browsers compare the caller with
+// the listed origins exactly, so every origin must be a single https origin and no wildcard or app origin. The
+// origins are returned in their canonical form.
+func NewRelatedOrigins(origins []string) (*RelatedOrigins, error) {
+	related := &RelatedOrigins{Origins: []string{}}
+	for _, origin := range origins {
+		rule, err := ParseOriginRule(origin)
+		if err != nil {
+			return nil, err
+		}
+		webOrigin, ok := rule.(WebOrigin)
+		if !ok || webOrigin.Scheme != "https" {
+			return nil, fmt.Errorf("related origin %s must be a single https origin", origin)
+		}
+		related.Origins = append(related.Origins, webOrigin.String())
+	}
+	return related, nil
+}
@@ -0,0 +1,51 @@
+package protocol
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewRelatedOrigins(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "Success",
+			origins: []string{"https://example.de", "HTTPS://Example.co.uk:443"},
+			want:    []string{"https://example.de", "https://example.co.uk"},
+		},
+		{
+			name: "Empty",
+			want: []string{},
+		},
+		{
+			name:    "Insecure origin",
+			origins: []string{"http://example.de"},
+			wantErr: true,
+		},
+		{
+			name:    "Wildcard origin",
+			origins: []string{"https://*.example.de"},
+			wantErr: true,
+		},
+		{
+			name:    "App origin",
+			origins: []string{"android:apk-key-hash:-sYXRdwJA3hvue3mKpYrOZ9zSPC7b4mbgzJmdZEDO5w"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewRelatedOrigins(tt.origins)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRelatedOrigins() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got.Origins, tt.want) {
+				t.Errorf("NewRelatedOrigins() = %v, want %v", got.Origins, tt.want)
+			}
+		})
+	}
+}
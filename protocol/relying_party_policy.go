@@ -1,6 +1,9 @@
 package protocol
 
 import (
+	"encoding/json"
+	"fmt"
+
 	uuid "github.com/gofrs/uuid"
 	"github.com/teamhanko/webauthn-go/metadata"
 )
@@ -66,3 +69,98 @@ func (ap AllowlistPolicy) Verify(pcc *ParsedCredentialCreationData, attestationT
 	}
 	return nil
 }
+
+// This policy rejects credentials which are backup eligible, e.g. passkeys which are synced between the devices of
+// the user, so that only device-bound credentials can be registered. All other checks are delegated to Policy,
+// if Policy is nil the attestation is required to be trustworthy as without any policy.
+type DenyBackupEligiblePolicy struct {
+	Policy RelyingPartyPolicy
+}
+
+// DenyBackupEligiblePolicy - returns an error if the backup eligible flag is set, otherwise the result of the wrapped policy
+func (dp DenyBackupEligiblePolicy) Verify(pcc *ParsedCredentialCreationData, attestationTrustworthinessError error, metadataStatement *metadata.MetadataStatement) error {
+	if pcc != nil && pcc.Response.AttestationObject.AuthData.Flags.BackupEligible() {
+		return ErrAuthenticatorNotAllowed.WithDetails("Backup eligible credentials are not allowed by policy.")
+	}
+
+	if dp.Policy == nil {
+		return attestationTrustworthinessError
+	}
+	return dp.Policy.Verify(pcc, attestationTrustworthinessError, metadataStatement)
+}
+
+// ExtensionPolicy is implemented by a RelyingPartyPolicy which also checks the extension outputs of a registration
+// against the requested extension inputs. It is called after the outputs were verified by VerifyExtensions.
+type ExtensionPolicy interface {
+	VerifyExtensions(pcc *ParsedCredentialCreationData, requested AuthenticationExtensions, outputs ExtensionOutputs) error
+}
+
+// VerifyExtensionPolicy calls the policy if it implements ExtensionPolicy, otherwise the extensions are accepted
+func VerifyExtensionPolicy(policy RelyingPartyPolicy, pcc *ParsedCredentialCreationData, requested AuthenticationExtensions, outputs ExtensionOutputs) error {
+	extensionPolicy, ok := policy.(ExtensionPolicy)
+	if !ok {
+		return nil
+	}
+	return extensionPolicy.VerifyExtensions(pcc, requested, outputs)
+}
+
+// DenyBackupEligiblePolicy - returns the result of the extension checks of the wrapped policy
+func (dp DenyBackupEligiblePolicy) VerifyExtensions(pcc *ParsedCredentialCreationData, requested AuthenticationExtensions, outputs ExtensionOutputs) error {
+	return VerifyExtensionPolicy(dp.Policy, pcc, requested, outputs)
+}
+
+// This policy rejects credentials whose credProtect level or minimum PIN length is below what was requested, e.g.
+// for accounts which must only use credentials protected by user verification. A credProtect level is only checked
+// if credentialProtectionPolicy was requested and MinPinLength only if minPinLength was requested. All other checks
+// are delegated to Policy, if Policy is nil the attestation is required to be trustworthy as without any policy.
+type ExtensionRequirementsPolicy struct {
+	// The minimum PIN length the authenticator must enforce for registrations which request minPinLength
+	MinPinLength uint
+	Policy       RelyingPartyPolicy
+}
+
+// ExtensionRequirementsPolicy - returns the result of the wrapped policy
+func (ep ExtensionRequirementsPolicy) Verify(pcc *ParsedCredentialCreationData, attestationTrustworthinessError error, metadataStatement *metadata.MetadataStatement) error {
+	if ep.Policy == nil {
+		return attestationTrustworthinessError
+	}
+	return ep.Policy.Verify(pcc, attestationTrustworthinessError, metadataStatement)
+}
+
+// ExtensionRequirementsPolicy - returns an error if the credProtect level or the minimum PIN length returned by the authenticator is below the requested one, otherwise the result of the extension checks of the wrapped policy
+func (ep ExtensionRequirementsPolicy) VerifyExtensions(pcc *ParsedCredentialCreationData, requested AuthenticationExtensions, outputs ExtensionOutputs) error {
+	if err := VerifyExtensionPolicy(ep.Policy, pcc, requested, outputs); err != nil {
+		return err
+	}
+
+	if input, ok := requested[ExtensionCredentialProtectionPolicy]; ok {
+		var requestedPolicy CredentialProtectionPolicy
+		_ = json.Unmarshal(input, &requestedPolicy)
+		requestedLevel := requestedPolicy.Level()
+		// Authenticators without credProtect use userVerificationOptional
+		level, ok := outputs.CredProtect()
+		if !ok {
+			level = CredentialProtectionUserVerificationOptional.Level()
+		}
+		if level < requestedLevel {
+			return ErrAuthenticatorNotAllowed.WithDetails(fmt.Sprintf("The credProtect level %d is below the requested level %d.", level, requestedLevel))
+		}
+	}
+
+	if _, ok := requested[ExtensionMinPinLength]; ok && ep.MinPinLength > 0 {
+		length, ok := outputs.MinPinLength()
+		if !ok {
+			return ErrAuthenticatorNotAllowed.WithDetails("The authenticator did not return its minimum PIN length.")
+		}
+		if length < ep.MinPinLength {
+			return ErrAuthenticatorNotAllowed.WithDetails(fmt.Sprintf("The minimum PIN length %d is below the required length %d.", length, ep.MinPinLength))
+		}
+	}
+
+	return nil
+}
+
+var (
+	_ ExtensionPolicy = DenyBackupEligiblePolicy{}
+	_ ExtensionPolicy = ExtensionRequirementsPolicy{}
+)
@@ -295,6 +295,70 @@ func TestAllowlistPolicy_Verify(t *testing.T) {
 	}
 }
 
+func TestDenyBackupEligiblePolicy_Verify(t *testing.T) {
+	withFlags := func(flags AuthenticatorFlags) *ParsedCredentialCreationData {
+		pcc := &ParsedCredentialCreationData{}
+		pcc.Response.AttestationObject.AuthData.Flags = flags
+		return pcc
+	}
+	type args struct {
+		policy     DenyBackupEligiblePolicy
+		pcc        *ParsedCredentialCreationData
+		trustError error
+	}
+
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "Device-bound credential",
+			args: args{
+				policy: DenyBackupEligiblePolicy{Policy: AllowAllPolicy{}},
+				pcc:    withFlags(FlagUserPresent),
+			},
+			wantErr: false,
+		},
+		{
+			name: "Backup eligible credential",
+			args: args{
+				policy: DenyBackupEligiblePolicy{Policy: AllowAllPolicy{}},
+				pcc:    withFlags(FlagUserPresent | FlagBackupEligible),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Wrapped policy error",
+			args: args{
+				policy:     DenyBackupEligiblePolicy{Policy: AllowOnlyAuthenticatorFromMetadataServicePolicy{}},
+				pcc:        withFlags(FlagUserPresent),
+				trustError: ErrAttestation,
+			},
+			wantErr: true,
+		},
+		{
+			name: "No wrapped policy and TrustworthinessError",
+			args: args{
+				policy:     DenyBackupEligiblePolicy{},
+				pcc:        withFlags(FlagUserPresent),
+				trustError: ErrAttestation,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.args.policy.Verify(tt.args.pcc, tt.args.trustError, testMetadataStatement)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DenyBackupEligiblePolicy.Verify() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 var testMetadataStatement = &metadata.MetadataStatement{
 	LegalHeader:                          "Metadata Legal Header: Version 1.00.　Date: May 21, 2018.  To access, view and use any Metadata Statements or the TOC file (“METADATA”) from the MDS, You must be bound by the latest FIDO Alliance Metadata Usage Terms that can be found at http://mds2.fidoalliance.org/ . If you already have a valid token, access the above URL attaching your token such as http://mds2.fidoalliance.org?token=YOUR-VALID-TOKEN.  If You have not entered into the agreement, please visit the registration site found at http://fidoalliance.org/MDS/ and enter into the agreement and obtain a valid token.  You must not redistribute this file to any third party. Removal of this Legal Header or modifying any part of this file renders this file invalid.  The integrity of this file as originally provided from the MDS is validated by the hash value of this file that is recorded in the MDS. The use of invalid files is strictly prohibited. If the version number for the Legal Header is updated from Version 1.00, the METADATA below may also be updated or may not be available. Please use the METADATA with the Legal Header with the latest version number.  Dated: 2018-05-21 Version LH-1.00",
 	Aaid:                                 "",
@@ -323,3 +387,127 @@ var testMetadataStatement = &metadata.MetadataStatement{
 	Icon:                                 "",
 	SupportedExtensions:                  nil,
 }
+
+func TestExtensionRequirementsPolicy_VerifyExtensions(t *testing.T) {
+	withOutputs := func(outputs map[string]uint) ExtensionOutputs {
+		extensionOutputs := make(ExtensionOutputs)
+		if level, ok := outputs[ExtensionCredProtect]; ok {
+			extensionOutputs[ExtensionCredentialProtectionPolicy] = ExtensionOutput{Authenticator: level}
+		}
+		if length, ok := outputs[ExtensionMinPinLength]; ok {
+			extensionOutputs[ExtensionMinPinLength] = ExtensionOutput{Authenticator: length}
+		}
+		return extensionOutputs
+	}
+	type args struct {
+		policy    RelyingPartyPolicy
+		requested AuthenticationExtensions
+		outputs   ExtensionOutputs
+	}
+
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "Nothing requested",
+			args: args{
+				policy:  ExtensionRequirementsPolicy{MinPinLength: 8},
+				outputs: withOutputs(nil),
+			},
+			wantErr: false,
+		},
+		{
+			name: "credProtect level returned",
+			args: args{
+				policy:    ExtensionRequirementsPolicy{},
+				requested: AuthenticationExtensions{ExtensionCredentialProtectionPolicy: rawInput(CredentialProtectionUserVerificationRequired)},
+				outputs:   withOutputs(map[string]uint{ExtensionCredProtect: 3}),
+			},
+			wantErr: false,
+		},
+		{
+			name: "credProtect level below request",
+			args: args{
+				policy:    ExtensionRequirementsPolicy{},
+				requested: AuthenticationExtensions{ExtensionCredentialProtectionPolicy: rawInput(CredentialProtectionUserVerificationRequired)},
+				outputs:   withOutputs(map[string]uint{ExtensionCredProtect: 2}),
+			},
+			wantErr: true,
+		},
+		{
+			name: "credProtect not supported",
+			args: args{
+				policy:    ExtensionRequirementsPolicy{},
+				requested: AuthenticationExtensions{ExtensionCredentialProtectionPolicy: rawInput("userVerificationOptionalWithCredentialIDList")},
+				outputs:   withOutputs(nil),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Minimum PIN length returned",
+			args: args{
+				policy:    ExtensionRequirementsPolicy{MinPinLength: 8},
+				requested: AuthenticationExtensions{ExtensionMinPinLength: rawInput(true)},
+				outputs:   withOutputs(map[string]uint{ExtensionMinPinLength: 8}),
+			},
+			wantErr: false,
+		},
+		{
+			name: "Minimum PIN length below requirement",
+			args: args{
+				policy:    ExtensionRequirementsPolicy{MinPinLength: 8},
+				requested: AuthenticationExtensions{ExtensionMinPinLength: rawInput(true)},
+				outputs:   withOutputs(map[string]uint{ExtensionMinPinLength: 4}),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Minimum PIN length missing",
+			args: args{
+				policy:    ExtensionRequirementsPolicy{MinPinLength: 8},
+				requested: AuthenticationExtensions{ExtensionMinPinLength: rawInput(true)},
+				outputs:   withOutputs(nil),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Wrapped in DenyBackupEligiblePolicy",
+			args: args{
+				policy:    DenyBackupEligiblePolicy{Policy: ExtensionRequirementsPolicy{MinPinLength: 8}},
+				requested: AuthenticationExtensions{ExtensionMinPinLength: rawInput(true)},
+				outputs:   withOutputs(map[string]uint{ExtensionMinPinLength: 4}),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Wrapping a policy with extension checks",
+			args: args{
+				policy:    ExtensionRequirementsPolicy{Policy: ExtensionRequirementsPolicy{MinPinLength: 8}},
+				requested: AuthenticationExtensions{ExtensionMinPinLength: rawInput(true)},
+				outputs:   withOutputs(map[string]uint{ExtensionMinPinLength: 4}),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Policy without extension checks",
+			args: args{
+				policy:    AllowAllPolicy{},
+				requested: AuthenticationExtensions{ExtensionMinPinLength: rawInput(true)},
+				outputs:   withOutputs(nil),
+			},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyExtensionPolicy(tt.args.policy, &ParsedCredentialCreationData{}, tt.args.requested, tt.args.outputs)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyExtensionPolicy() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,13 +1,25 @@
 package webauthn
 
 import (
+	"time"
+
 	"github.com/teamhanko/webauthn-go/credential"
 	"github.com/teamhanko/webauthn-go/protocol"
 )
 
 // MakeNewCredential will return a credential pointer on successful validation of a registration response
 func MakeNewCredential(c *protocol.ParsedCredentialCreationData) (*credential.Credential, error) {
+	return makeNewCredential(c, time.Now())
+}
+
+// makeNewCredential creates the credential with the given creation time
+func makeNewCredential(c *protocol.ParsedCredentialCreationData, createdAt time.Time) (*credential.Credential, error) {
+	var transports []string
+	for _, transport := range c.Response.Transports {
+		transports = append(transports, string(transport))
+	}
 
+	backupEligible := c.Response.AttestationObject.AuthData.Flags.BackupEligible()
 	newCredential := &credential.Credential{
 		ID:               c.Response.AttestationObject.AuthData.AttData.CredentialID,
 		PublicKey:        c.Response.AttestationObject.AuthData.AttData.CredentialPublicKey,
@@ -17,6 +29,19 @@ func MakeNewCredential(c *protocol.ParsedCredentialCreationData) (*credential.Cr
 			AAGUID:    c.Response.AttestationObject.AuthData.AttData.AAGUID,
 			SignCount: c.Response.AttestationObject.AuthData.Counter,
 		},
+		Transports:              transports,
+		AuthenticatorAttachment: string(c.AuthenticatorAttachment),
+		BackupEligible:          &backupEligible,
+		BackupState:             c.Response.AttestationObject.AuthData.Flags.BackupState(),
+		CreatedAt:               createdAt,
+		AttestationObject:       c.Raw.AttestationResponse.AttestationObject,
+	}
+
+	if credProps, ok := c.Extensions.CredProps(); ok && credProps.ResidentKey != nil {
+		newCredential.Discoverable = *credProps.ResidentKey
+	}
+	if largeBlob, ok := c.Extensions.LargeBlob(); ok && largeBlob.Supported != nil {
+		newCredential.LargeBlobSupported = *largeBlob.Supported
 	}
 
 	return newCredential, nil
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/teamhanko/webauthn-go/protocol"
+	"github.com/teamhanko/webauthn-go/webauthn"
+)
+
+// ConformanceServer implements the server API which the FIDO conformance tools use to certify a relying party, with
+// the endpoints /attestation/options, /attestation/result, /assertion/options and /assertion/result. The users are
+// created on the fly and only kept in memory, so it must only be used for testing. To run the metadata tests, the
+// WebAuthn instance needs a MetadataService with the test metadata of the conformance tools.
+// See https://fidoalliance.org/specs/fido-v2.0-rd-20180702/fido-server-v2.0-rd-20180702.html
+type ConformanceServer struct {
+	webauthn *webauthn.WebAuthn
+	mux      *http.ServeMux
+
+	users map[string]*conformanceUser
+	mu    sync.Mutex
+}
+
+// conformanceUser is a user created by the conformance tools
+type conformanceUser struct {
+	id          []byte
+	name        string
+	displayName string
+}
+
+func (u *conformanceUser) WebAuthnID() []byte          { return u.id }
+func (u *conformanceUser) WebAuthnName() string        { return u.name }
+func (u *conformanceUser) WebAuthnDisplayName() string { return u.displayName }
+func (u *conformanceUser) WebAuthnIcon() string        { return "" }
+
+// NewConformanceServer creates the conformance server API for the WebAuthn instance, which must have a SessionStore
+// and a CredentialService
+func NewConformanceServer(w *webauthn.WebAuthn) (*ConformanceServer, error) {
+	if w.SessionStore == nil {
+		return nil, fmt.Errorf("SessionStore must not be nil")
+	}
+	if w.CredentialService == nil {
+		return nil, fmt.Errorf("CredentialService must not be nil")
+	}
+
+	s := &ConformanceServer{
+		webauthn: w,
+		mux:      http.NewServeMux(),
+		users:    make(map[string]*conformanceUser),
+	}
+	s.mux.Handle("/attestation/options", s.handle(s.attestationOptions))
+	s.mux.Handle("/attestation/result", s.handle(s.attestationResult))
+	s.mux.Handle("/assertion/options", s.handle(s.assertionOptions))
+	s.mux.Handle("/assertion/result", s.handle(s.assertionResult))
+
+	return s, nil
+}
+
+func (s *ConformanceServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *ConformanceServer) attestationOptions(r *http.Request) (interface{}, error) {
+	var request protocol.ServerPublicKeyCredentialCreationOptionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, protocol.ErrBadRequest.WithDetails("Parse error for attestation options").WithInfo(err.Error())
+	}
+	if request.Username == "" {
+		return nil, protocol.ErrBadRequest.WithDetails("Missing username")
+	}
+
+	user, err := s.user(request.Username, request.DisplayName)
+	if err != nil {
+		return nil, err
+	}
+	exclusions, err := s.credentialDescriptors(user)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []webauthn.RegistrationOption{
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithExtensions(request.Extensions),
+	}
+	if request.AuthenticatorSelection != nil {
+		opts = append(opts, webauthn.WithAuthenticatorSelection(*request.AuthenticatorSelection))
+	}
+	if request.Attestation != "" {
+		opts = append(opts, webauthn.WithConveyancePreference(request.Attestation))
+	}
+
+	creation, _, err := s.webauthn.BeginRegistration(user, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return protocol.ServerPublicKeyCredentialCreationOptionsResponse{
+		ServerResponse:                     protocol.ServerResponse{Status: protocol.StatusOk},
+		PublicKeyCredentialCreationOptions: creation.Response,
+	}, nil
+}
+
+func (s *ConformanceServer) attestationResult(r *http.Request) (interface{}, error) {
+	if err := normalizeConformanceCredential(r); err != nil {
+		return nil, err
+	}
+	if _, err := s.webauthn.FinishStoredRegistration(r); err != nil {
+		return nil, err
+	}
+	return protocol.ServerResponse{Status: protocol.StatusOk}, nil
+}
+
+func (s *ConformanceServer) assertionOptions(r *http.Request) (interface{}, error) {
+	var request protocol.ServerPublicKeyCredentialGetOptionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, protocol.ErrBadRequest.WithDetails("Parse error for assertion options").WithInfo(err.Error())
+	}
+
+	opts := []webauthn.LoginOption{
+		webauthn.WithAssertionExtensions(request.Extensions),
+	}
+	if request.UserVerification != "" {
+		opts = append(opts, webauthn.WithUserVerification(request.UserVerification))
+	}
+
+	var assertion *protocol.CredentialAssertion
+	var err error
+	if request.Username == "" {
+		assertion, _, err = s.webauthn.BeginDiscoverableLogin(opts...)
+	} else {
+		s.mu.Lock()
+		user, ok := s.users[request.Username]
+		s.mu.Unlock()
+		if !ok {
+			return nil, protocol.ErrBadRequest.WithDetails("User does not exist")
+		}
+		assertion, _, err = s.webauthn.BeginLogin(user, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return protocol.ServerPublicKeyCredentialGetOptionsResponse{
+		ServerResponse:                    protocol.ServerResponse{Status: protocol.StatusOk},
+		PublicKeyCredentialRequestOptions: assertion.Response,
+	}, nil
+}
+
+func (s *ConformanceServer) assertionResult(r *http.Request) (interface{}, error) {
+	if err := normalizeConformanceCredential(r); err != nil {
+		return nil, err
+	}
+	_, err := finishStoredLogin(s.webauthn, r, s.userByID)
+	if err != nil {
+		return nil, err
+	}
+	return protocol.ServerResponse{Status: protocol.StatusOk}, nil
+}
+
+// user returns the user with the name, it is created if it does not exist yet
+func (s *ConformanceServer) user(name, displayName string) (*conformanceUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.users[name]; ok {
+		return user, nil
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	user := &conformanceUser{id: id, name: name, displayName: displayName}
+	s.users[name] = user
+	return user, nil
+}
+
+func (s *ConformanceServer) userByID(userId []byte) (webauthn.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if bytes.Equal(user.id, userId) {
+			return user, nil
+		}
+	}
+	return nil, protocol.ErrBadRequest.WithDetails("User does not exist")
+}
+
+// credentialDescriptors returns the descriptors of the credentials the user has registered
+func (s *ConformanceServer) credentialDescriptors(user *conformanceUser) ([]protocol.CredentialDescriptor, error) {
+	credentials, err := s.webauthn.CredentialService.GetCredentialForUser(user.id)
+	if err != nil {
+		return nil, err
+	}
+	descriptors := make([]protocol.CredentialDescriptor, len(credentials))
+	for i, cred := range credentials {
+		descriptors[i] = protocol.CredentialDescriptor{
+			Type:         protocol.PublicKeyCredentialType,
+			CredentialID: cred.ID,
+		}
+	}
+	return descriptors, nil
+}
+
+// normalizeConformanceCredential rewrites the body of the request, because the conformance tools send the client
+// extension outputs as getClientExtensionResults and may omit the rawId
+func normalizeConformanceCredential(r *http.Request) error {
+	var credential map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&credential); err != nil {
+		return protocol.ErrBadRequest.WithDetails("Parse error for credential").WithInfo(err.Error())
+	}
+	if results, ok := credential["getClientExtensionResults"]; ok {
+		if _, ok := credential["extensions"]; !ok {
+			credential["extensions"] = results
+		}
+		delete(credential, "getClientExtensionResults")
+	}
+	if _, ok := credential["rawId"]; !ok {
+		credential["rawId"] = credential["id"]
+	}
+
+	body, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+// handle answers the request with the result of the endpoint. Errors are answered with the status failed and the
+// error message, which includes the debug information of the error to help with failing conformance tests.
+func (s *ConformanceServer) handle(endpoint func(r *http.Request) (interface{}, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			_ = writeJSON(w, http.StatusMethodNotAllowed, protocol.ServerResponse{Status: protocol.StatusFailed, Message: errMethodNotAllowed.Details})
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, DefaultMaxBodySize)
+		response, err := endpoint(r)
+		if err != nil {
+			message := err.Error()
+			var protocolErr *protocol.Error
+			if errors.As(err, &protocolErr) && protocolErr.DevInfo != "" {
+				message += ": " + protocolErr.DevInfo
+			}
+			_ = writeJSON(w, http.StatusBadRequest, protocol.ServerResponse{Status: protocol.StatusFailed, Message: message})
+			return
+		}
+		_ = writeJSON(w, http.StatusOK, response)
+	})
+}
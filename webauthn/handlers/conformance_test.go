@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/teamhanko/webauthn-go/credential"
+	"github.com/teamhanko/webauthn-go/protocol"
+	"github.com/teamhanko/webauthn-go/webauthn"
+)
+
+func newTestConformanceServer(t *testing.T) *httptest.Server {
+	w, err := webauthn.New(&webauthn.Config{
+		RPDisplayName: "Example",
+		RPID:          testRPID,
+		RPOrigin:      testOrigin,
+	}, nil, credential.NewInMemoryCredentialService(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SessionStore = webauthn.NewInMemorySessionStore(w.Clock)
+	s, err := NewConformanceServer(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// post sends the request to the endpoint and decodes the JSON response into value
+func post(t *testing.T, server *httptest.Server, endpoint string, request interface{}, value interface{}) int {
+	body, ok := request.([]byte)
+	if !ok {
+		var err error
+		if body, err = json.Marshal(request); err != nil {
+			t.Fatal(err)
+		}
+	}
+	response, err := http.Post(server.URL+endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if err := json.NewDecoder(response.Body).Decode(value); err != nil {
+		t.Fatalf("failed to decode response of %s: %v", endpoint, err)
+	}
+	return response.StatusCode
+}
+
+// withClientExtensionResults renames the extension outputs of the credential like the conformance tools do
+func withClientExtensionResults(t *testing.T, body []byte) []byte {
+	var credential map[string]interface{}
+	if err := json.Unmarshal(body, &credential); err != nil {
+		t.Fatal(err)
+	}
+	credential["getClientExtensionResults"] = map[string]interface{}{}
+	delete(credential, "rawId")
+	body, err := json.Marshal(credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestConformanceServer(t *testing.T) {
+	server := newTestConformanceServer(t)
+	authenticator := newTestAuthenticator(t)
+
+	var creation protocol.ServerPublicKeyCredentialCreationOptionsResponse
+	status := post(t, server, "/attestation/options", protocol.ServerPublicKeyCredentialCreationOptionsRequest{
+		Username:    "alice",
+		DisplayName: "Alice",
+		Attestation: protocol.PreferNoAttestation,
+	}, &creation)
+	if status != http.StatusOK || creation.Status != protocol.StatusOk {
+		t.Fatalf("/attestation/options = %d %+v, want ok", status, creation.ServerResponse)
+	}
+	if creation.User.Name != "alice" || creation.RelyingParty.ID != testRPID || len(creation.Challenge) == 0 {
+		t.Errorf("/attestation/options = %+v, want options for alice", creation.PublicKeyCredentialCreationOptions)
+	}
+
+	var result protocol.ServerResponse
+	registration := authenticator.Create(t, creation.PublicKeyCredentialCreationOptions)
+	if status := post(t, server, "/attestation/result", withClientExtensionResults(t, registration), &result); status != http.StatusOK || result.Status != protocol.StatusOk {
+		t.Fatalf("/attestation/result = %d %+v, want ok", status, result)
+	}
+
+	status = post(t, server, "/attestation/options", protocol.ServerPublicKeyCredentialCreationOptionsRequest{Username: "alice"}, &creation)
+	if status != http.StatusOK || len(creation.CredentialExcludeList) != 1 {
+		t.Errorf("/attestation/options for registered user = %d, excludeCredentials %v, want the registered credential", status, creation.CredentialExcludeList)
+	}
+
+	var assertion protocol.ServerPublicKeyCredentialGetOptionsResponse
+	status = post(t, server, "/assertion/options", protocol.ServerPublicKeyCredentialGetOptionsRequest{
+		Username:         "alice",
+		UserVerification: protocol.VerificationDiscouraged,
+	}, &assertion)
+	if status != http.StatusOK || assertion.Status != protocol.StatusOk || len(assertion.AllowedCredentials) != 1 {
+		t.Fatalf("/assertion/options = %d %+v, want ok with one allowed credential", status, assertion)
+	}
+
+	login := getAssertion(t, authenticator, assertion.PublicKeyCredentialRequestOptions)
+	if status := post(t, server, "/assertion/result", withClientExtensionResults(t, login), &result); status != http.StatusOK || result.Status != protocol.StatusOk {
+		t.Fatalf("/assertion/result = %d %+v, want ok", status, result)
+	}
+
+	if status := post(t, server, "/assertion/result", login, &result); status != http.StatusBadRequest || result.Status != protocol.StatusFailed || result.Message == "" {
+		t.Errorf("/assertion/result replay = %d %+v, want failed", status, result)
+	}
+}
+
+func TestConformanceServer_Errors(t *testing.T) {
+	server := newTestConformanceServer(t)
+
+	tests := []struct {
+		name     string
+		endpoint string
+		request  interface{}
+	}{
+		{"Registration without username", "/attestation/options", protocol.ServerPublicKeyCredentialCreationOptionsRequest{}},
+		{"Login of unknown user", "/assertion/options", protocol.ServerPublicKeyCredentialGetOptionsRequest{Username: "bob"}},
+		{"Invalid attestation", "/attestation/result", []byte(`{"id":"AAAA","type":"public-key","response":{}}`)},
+		{"Invalid JSON", "/assertion/result", []byte(`{`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var response protocol.ServerResponse
+			status := post(t, server, tt.endpoint, tt.request, &response)
+			if status != http.StatusBadRequest || response.Status != protocol.StatusFailed || response.Message == "" {
+				t.Errorf("%s = %d %+v, want failed with message", tt.endpoint, status, response)
+			}
+		})
+	}
+}
+
+func TestNewConformanceServer(t *testing.T) {
+	tests := []struct {
+		name              string
+		sessionStore      bool
+		credentialService bool
+		wantErr           bool
+	}{
+		{name: "Valid instance", sessionStore: true, credentialService: true},
+		{name: "Missing SessionStore", credentialService: true, wantErr: true},
+		{name: "Missing CredentialService", sessionStore: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := webauthn.New(&webauthn.Config{
+				RPDisplayName: "Example",
+				RPID:          testRPID,
+				RPOrigin:      testOrigin,
+			}, nil, credential.NewInMemoryCredentialService(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.sessionStore {
+				w.SessionStore = webauthn.NewInMemorySessionStore(w.Clock)
+			}
+			if !tt.credentialService {
+				w.CredentialService = nil
+			}
+
+			if _, err := NewConformanceServer(w); (err != nil) != tt.wantErr {
+				t.Errorf("NewConformanceServer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
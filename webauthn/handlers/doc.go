@@ -0,0 +1,7 @@
+// Package handlers provides net/http handlers for the four endpoints of the WebAuthn ceremonies. The begin handlers
+// return the options for navigator.credentials.create() and navigator.credentials.get() as JSON and save the
+// session in the SessionStore of the WebAuthn instance, the finish handlers consume the session and verify the
+// response of the client. Errors are answered with the JSON encoding of a protocol.Error.
+//
+// The ConformanceServer provides the server API of the FIDO conformance tools instead.
+package handlers
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/teamhanko/webauthn-go/protocol"
+)
+
+var (
+	errMethodNotAllowed = &protocol.Error{
+		Type:    "method_not_allowed",
+		Details: "Only POST requests are allowed",
+	}
+	errInternal = &protocol.Error{
+		Type:    "internal_error",
+		Details: "Internal server error",
+	}
+)
+
+// writeError writes the error as JSON. A *protocol.Error is returned to the client, its debug information only if
+// debug is set. All other errors are answered with an internal server error without details.
+func writeError(w http.ResponseWriter, err error, debug bool) {
+	var protocolErr *protocol.Error
+	if !errors.As(err, &protocolErr) {
+		protocolErr = errInternal
+	}
+	response := *protocolErr
+	if !debug {
+		response.DevInfo = ""
+	}
+	_ = writeJSON(w, statusCode(protocolErr), &response)
+}
+
+// statusCode returns the HTTP status of the error, the errors of the library are mapped by protocol.Error.HTTPStatus
+func statusCode(err *protocol.Error) int {
+	if err.Type == errMethodNotAllowed.Type {
+		return http.StatusMethodNotAllowed
+	}
+	return err.HTTPStatus()
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
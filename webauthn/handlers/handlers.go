@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/teamhanko/webauthn-go/protocol"
+	"github.com/teamhanko/webauthn-go/webauthn"
+)
+
+// UserResolver identifies the users of the ceremonies. Errors of type *protocol.Error are returned to the client,
+// all other errors are answered with an internal server error.
+type UserResolver interface {
+	// UserForRequest returns the user a ceremony is started for, e.g. the logged-in user for a registration or the
+	// user of the username in the request body for a login. If it returns nil for a login, a discoverable login is
+	// started. A registration requires a user.
+	UserForRequest(r *http.Request) (webauthn.User, error)
+	// UserByID returns the user with the given WebAuthn ID, which is used to finish a discoverable login
+	UserByID(r *http.Request, userId []byte) (webauthn.User, error)
+}
+
+// DefaultMaxBodySize is the maximum size of a request body in bytes, if Handlers.MaxBodySize is not set
+const DefaultMaxBodySize = 1 << 20
+
+// Handlers provides the http.Handlers of the ceremony endpoints. The WebAuthn instances must have a SessionStore.
+type Handlers struct {
+	webauthn func(r *http.Request) (*webauthn.WebAuthn, error)
+	users    UserResolver
+
+	// RegistrationOptions are applied to every registration
+	RegistrationOptions []webauthn.RegistrationOption
+	// LoginOptions are applied to every login
+	LoginOptions []webauthn.LoginOption
+	// MaxBodySize limits the size of request bodies in bytes. If it is not set, DefaultMaxBodySize is used.
+	MaxBodySize int64
+	// OnRegistration is called after a credential was registered and stored, before the response is written
+	OnRegistration func(w http.ResponseWriter, r *http.Request, result *webauthn.RegistrationResult) error
+	// OnLogin is called after a successful login, e.g. to start the session of the user. If it returns an error the
+	// login fails.
+	OnLogin func(w http.ResponseWriter, r *http.Request, result *webauthn.LoginResult) error
+}
+
+// New creates the handlers for a single WebAuthn instance
+func New(w *webauthn.WebAuthn, users UserResolver) *Handlers {
+	return &Handlers{
+		webauthn: func(*http.Request) (*webauthn.WebAuthn, error) {
+			return w, nil
+		},
+		users: users,
+	}
+}
+
+// NewForTenants creates the handlers for the tenants of the registry. The tenant is selected by the host of the
+// request.
+func NewForTenants(registry *webauthn.TenantRegistry, users UserResolver) *Handlers {
+	return &Handlers{
+		webauthn: registry.ForRequest,
+		users:    users,
+	}
+}
+
+// RegistrationResponse is returned by the FinishRegistration handler
+type RegistrationResponse struct {
+	CredentialID protocol.URLEncodedBase64 `json:"credential_id"`
+	UserID       protocol.URLEncodedBase64 `json:"user_id"`
+}
+
+// LoginResponse is returned by the FinishLogin handler
+type LoginResponse struct {
+	CredentialID protocol.URLEncodedBase64 `json:"credential_id"`
+	UserID       protocol.URLEncodedBase64 `json:"user_id"`
+}
+
+// BeginRegistration returns the handler which starts a registration for the user of the request. It responds with
+// the protocol.CredentialCreation.
+func (h *Handlers) BeginRegistration() http.Handler {
+	return h.handle(func(w http.ResponseWriter, r *http.Request, instance *webauthn.WebAuthn) error {
+		user, err := h.users.UserForRequest(r)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return protocol.ErrBadRequest.WithDetails("A registration requires a user")
+		}
+
+		creation, _, err := instance.BeginRegistration(user, h.RegistrationOptions...)
+		if err != nil {
+			return err
+		}
+		return writeJSON(w, http.StatusOK, creation)
+	})
+}
+
+// FinishRegistration returns the handler which verifies the response of navigator.credentials.create() and stores
+// the new credential. It responds with a RegistrationResponse.
+func (h *Handlers) FinishRegistration() http.Handler {
+	return h.handle(func(w http.ResponseWriter, r *http.Request, instance *webauthn.WebAuthn) error {
+		result, err := instance.FinishStoredRegistration(r)
+		if err != nil {
+			return err
+		}
+		if h.OnRegistration != nil {
+			if err := h.OnRegistration(w, r, result); err != nil {
+				return err
+			}
+		}
+		return writeJSON(w, http.StatusOK, RegistrationResponse{
+			CredentialID: result.Credential.ID,
+			UserID:       result.UserID,
+		})
+	})
+}
+
+// BeginLogin returns the handler which starts a login for the user of the request, or a discoverable login if the
+// user is not known. It responds with the protocol.CredentialAssertion.
+func (h *Handlers) BeginLogin() http.Handler {
+	return h.handle(func(w http.ResponseWriter, r *http.Request, instance *webauthn.WebAuthn) error {
+		user, err := h.users.UserForRequest(r)
+		if err != nil {
+			return err
+		}
+
+		var assertion *protocol.CredentialAssertion
+		if user == nil {
+			assertion, _, err = instance.BeginDiscoverableLogin(h.LoginOptions...)
+		} else {
+			assertion, _, err = instance.BeginLogin(user, h.LoginOptions...)
+		}
+		if err != nil {
+			return err
+		}
+		return writeJSON(w, http.StatusOK, assertion)
+	})
+}
+
+// FinishLogin returns the handler which verifies the response of navigator.credentials.get(). It responds with a
+// LoginResponse.
+func (h *Handlers) FinishLogin() http.Handler {
+	return h.handle(func(w http.ResponseWriter, r *http.Request, instance *webauthn.WebAuthn) error {
+		result, err := finishStoredLogin(instance, r, func(userId []byte) (webauthn.User, error) {
+			return h.users.UserByID(r, userId)
+		})
+		if err != nil {
+			return err
+		}
+
+		if h.OnLogin != nil {
+			if err := h.OnLogin(w, r, result); err != nil {
+				return err
+			}
+		}
+		return writeJSON(w, http.StatusOK, LoginResponse{
+			CredentialID: result.Credential.ID,
+			UserID:       result.UserID,
+		})
+	})
+}
+
+// finishStoredLogin validates the login response of the request against the stored session. The session decides
+// whether the login is a discoverable login, whose user is looked up by the userHandle.
+func finishStoredLogin(instance *webauthn.WebAuthn, r *http.Request, userByID func(userId []byte) (webauthn.User, error)) (*webauthn.LoginResult, error) {
+	parsedResponse, err := protocol.ParseCredentialRequestResponse(r)
+	if err != nil {
+		return nil, err
+	}
+	session, err := instance.ConsumeSession(parsedResponse.Response.CollectedClientData.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(session.UserID) == 0 {
+		return instance.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+			return userByID(userHandle)
+		}, *session, parsedResponse)
+	}
+	return instance.ValidateLogin(*session, parsedResponse)
+}
+
+// handle resolves the WebAuthn instance of the request and writes the error of the ceremony, if one occurs
+func (h *Handlers) handle(ceremony func(w http.ResponseWriter, r *http.Request, instance *webauthn.WebAuthn) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, errMethodNotAllowed, false)
+			return
+		}
+
+		instance, err := h.webauthn(r)
+		if err != nil {
+			writeError(w, err, false)
+			return
+		}
+		if instance.SessionStore == nil {
+			writeError(w, protocol.ErrNotImplemented.WithDetails("No SessionStore configured"), instance.Config.Debug)
+			return
+		}
+
+		maxBodySize := h.MaxBodySize
+		if maxBodySize <= 0 {
+			maxBodySize = DefaultMaxBodySize
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+
+		if err := ceremony(w, r, instance); err != nil {
+			writeError(w, err, instance.Config.Debug)
+		}
+	})
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/teamhanko/webauthn-go/credential"
+	"github.com/teamhanko/webauthn-go/protocol"
+	"github.com/teamhanko/webauthn-go/webauthn"
+	"github.com/teamhanko/webauthn-go/webauthn/webauthntest"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+)
+
+type testUser struct {
+	id []byte
+}
+
+func (u *testUser) WebAuthnID() []byte          { return u.id }
+func (u *testUser) WebAuthnName() string        { return "alice" }
+func (u *testUser) WebAuthnDisplayName() string { return "Alice" }
+func (u *testUser) WebAuthnIcon() string        { return "" }
+
+// testUsers resolves the user from the X-User header of the request
+type testUsers struct {
+	err error
+}
+
+func (u testUsers) UserForRequest(r *http.Request) (webauthn.User, error) {
+	if u.err != nil {
+		return nil, u.err
+	}
+	if id := r.Header.Get("X-User"); id != "" {
+		return &testUser{id: []byte(id)}, nil
+	}
+	return nil, nil
+}
+
+func (u testUsers) UserByID(r *http.Request, userId []byte) (webauthn.User, error) {
+	return &testUser{id: userId}, nil
+}
+
+func newTestAuthenticator(t *testing.T) *webauthntest.Authenticator {
+	return webauthntest.NewAuthenticator(t, testRPID, testOrigin)
+}
+
+// getAssertion returns the response of navigator.credentials.get() for the request options
+func getAssertion(t *testing.T, authenticator *webauthntest.Authenticator, options protocol.PublicKeyCredentialRequestOptions) []byte {
+	return authenticator.Get(t, webauthntest.Assertion{
+		Challenge: options.Challenge.String(),
+		Flags:     protocol.FlagUserPresent,
+	})
+}
+
+func newTestHandlers(t *testing.T, users UserResolver) *Handlers {
+	w, err := webauthn.New(&webauthn.Config{
+		RPDisplayName: "Example",
+		RPID:          testRPID,
+		RPOrigin:      testOrigin,
+	}, nil, credential.NewInMemoryCredentialService(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SessionStore = webauthn.NewInMemorySessionStore(w.Clock)
+	return New(w, users)
+}
+
+// serve sends the body to the handler and decodes the JSON response into value
+func serve(t *testing.T, handler http.Handler, user string, body []byte, value interface{}) int {
+	r := httptest.NewRequest(http.MethodPost, testOrigin+"/webauthn", bytes.NewReader(body))
+	if user != "" {
+		r.Header.Set("X-User", user)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, r)
+
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type = %s, want application/json", contentType)
+	}
+	if value != nil {
+		if err := json.Unmarshal(recorder.Body.Bytes(), value); err != nil {
+			t.Fatalf("failed to decode response %s: %v", recorder.Body.String(), err)
+		}
+	}
+	return recorder.Code
+}
+
+// register runs a registration for the user through the handlers
+func register(t *testing.T, h *Handlers, authenticator *webauthntest.Authenticator, user string) {
+	var creation protocol.CredentialCreation
+	if status := serve(t, h.BeginRegistration(), user, nil, &creation); status != http.StatusOK {
+		t.Fatalf("BeginRegistration status = %d, want 200", status)
+	}
+
+	var registration RegistrationResponse
+	if status := serve(t, h.FinishRegistration(), "", authenticator.Create(t, creation.Response), &registration); status != http.StatusOK {
+		t.Fatalf("FinishRegistration status = %d, want 200", status)
+	}
+	if !bytes.Equal(registration.CredentialID, authenticator.CredentialID) || string(registration.UserID) != user {
+		t.Errorf("FinishRegistration = %+v, want credential of %s", registration, user)
+	}
+}
+
+func TestHandlers_RegistrationAndLogin(t *testing.T) {
+	tests := []struct {
+		name      string
+		loginUser string
+	}{
+		{name: "Login of known user", loginUser: "user-1"},
+		{name: "Discoverable login"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandlers(t, testUsers{})
+			var loggedIn *webauthn.LoginResult
+			h.OnLogin = func(w http.ResponseWriter, r *http.Request, result *webauthn.LoginResult) error {
+				loggedIn = result
+				return nil
+			}
+			authenticator := newTestAuthenticator(t)
+			register(t, h, authenticator, "user-1")
+
+			var assertion protocol.CredentialAssertion
+			if status := serve(t, h.BeginLogin(), tt.loginUser, nil, &assertion); status != http.StatusOK {
+				t.Fatalf("BeginLogin status = %d, want 200", status)
+			}
+			if discoverable := len(assertion.Response.AllowedCredentials) == 0; discoverable != (tt.loginUser == "") {
+				t.Errorf("BeginLogin allowCredentials = %v, want discoverable %v", assertion.Response.AllowedCredentials, tt.loginUser == "")
+			}
+
+			response := getAssertion(t, authenticator, assertion.Response)
+			var login LoginResponse
+			if status := serve(t, h.FinishLogin(), "", response, &login); status != http.StatusOK {
+				t.Fatalf("FinishLogin status = %d, want 200", status)
+			}
+			if string(login.UserID) != "user-1" || loggedIn == nil || string(loggedIn.UserID) != "user-1" {
+				t.Errorf("FinishLogin = %+v, OnLogin = %+v, want login of user-1", login, loggedIn)
+			}
+
+			var replayErr protocol.Error
+			if status := serve(t, h.FinishLogin(), "", response, &replayErr); status != http.StatusBadRequest || replayErr.Type != protocol.ErrSessionAlreadyUsed.Type {
+				t.Errorf("FinishLogin replay = %d %+v, want 400 %s", status, replayErr, protocol.ErrSessionAlreadyUsed.Type)
+			}
+		})
+	}
+}
+
+func TestHandlers_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		users      UserResolver
+		handler    func(h *Handlers) http.Handler
+		method     string
+		wantStatus int
+		wantType   string
+	}{
+		{
+			name:       "Registration without user",
+			users:      testUsers{},
+			handler:    (*Handlers).BeginRegistration,
+			wantStatus: http.StatusBadRequest,
+			wantType:   protocol.ErrBadRequest.Type,
+		},
+		{
+			name:       "Error of user resolver",
+			users:      testUsers{err: protocol.ErrBadRequest.WithDetails("Unknown username")},
+			handler:    (*Handlers).BeginLogin,
+			wantStatus: http.StatusBadRequest,
+			wantType:   protocol.ErrBadRequest.Type,
+		},
+		{
+			name:       "Internal error",
+			users:      testUsers{err: errors.New("database unavailable")},
+			handler:    (*Handlers).BeginLogin,
+			wantStatus: http.StatusInternalServerError,
+			wantType:   "internal_error",
+		},
+		{
+			name:       "Invalid response",
+			users:      testUsers{},
+			handler:    (*Handlers).FinishLogin,
+			wantStatus: http.StatusBadRequest,
+			wantType:   protocol.ErrBadRequest.Type,
+		},
+		{
+			name:       "Wrong method",
+			users:      testUsers{},
+			handler:    (*Handlers).BeginLogin,
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantType:   "method_not_allowed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			recorder := httptest.NewRecorder()
+			tt.handler(newTestHandlers(t, tt.users)).ServeHTTP(recorder, httptest.NewRequest(method, testOrigin+"/webauthn", bytes.NewReader([]byte("{}"))))
+
+			var response protocol.Error
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to decode response %s: %v", recorder.Body.String(), err)
+			}
+			if recorder.Code != tt.wantStatus || response.Type != tt.wantType {
+				t.Errorf("response = %d %+v, want %d %s", recorder.Code, response, tt.wantStatus, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestHandlers_MaxBodySize(t *testing.T) {
+	h := newTestHandlers(t, testUsers{})
+	authenticator := newTestAuthenticator(t)
+	register(t, h, authenticator, "user-1")
+	h.MaxBodySize = 64
+
+	var assertion protocol.CredentialAssertion
+	if status := serve(t, h.BeginLogin(), "user-1", nil, &assertion); status != http.StatusOK {
+		t.Fatalf("BeginLogin status = %d, want 200", status)
+	}
+
+	var response protocol.Error
+	if status := serve(t, h.FinishLogin(), "", getAssertion(t, authenticator, assertion.Response), &response); status != http.StatusBadRequest || response.Type != protocol.ErrBadRequest.Type {
+		t.Errorf("FinishLogin with too large body = %d %+v, want 400 %s", status, response, protocol.ErrBadRequest.Type)
+	}
+}
@@ -2,8 +2,9 @@ package webauthn
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"github.com/teamhanko/webauthn-go/credential"
 	"net/http"
 
@@ -22,23 +23,94 @@ type LoginOption func(*protocol.PublicKeyCredentialRequestOptions)
 // (https://www.w3.org/TR/webauthn-1/#assertion-options). These default values can be amended by providing
 // additional LoginOption parameters. This function also returns sessionData, that must be stored by the
 // RP in a secure manner and then provided to the FinishLogin function. This data helps us verify the
-// ownership of the credential being retreived.
+// ownership of the credential being retreived. For a login without a known user use BeginDiscoverableLogin.
 func (webauthn *WebAuthn) BeginLogin(user User, opts ...LoginOption) (*protocol.CredentialAssertion, *SessionData, error) {
-	challenge, err := protocol.CreateChallenge()
+	if user == nil {
+		return webauthn.beginLogin(nil, nil, opts)
+	}
+
+	allowedCredentials, err := webauthn.allowedCredentials(user)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	var credentials []credential.Credential
+	return webauthn.beginLogin(user.WebAuthnID(), allowedCredentials, opts)
+}
+
+// BeginTransactionLogin creates the CredentialAssertion data payload for a login which confirms the transaction, like
+// BeginLogin with WithTransaction or WithGenericTransaction. Unless the transaction sets OptionalConfirmation,
+// ValidateLogin fails if the authenticator did not return the confirmed transaction in its extension outputs. An
+// empty transaction starts a login without transaction.
+func (webauthn *WebAuthn) BeginTransactionLogin(user User, tx protocol.Transaction, opts ...LoginOption) (*protocol.CredentialAssertion, *SessionData, error) {
+	if tx.Empty() {
+		return webauthn.BeginLogin(user, opts...)
+	}
+
+	var userId []byte
+	var allowedCredentials []protocol.CredentialDescriptor
 	if user != nil {
-		credentials, err = webauthn.CredentialService.GetCredentialForUser(user.WebAuthnID())
-		if err != nil {
+		var err error
+		if allowedCredentials, err = webauthn.allowedCredentials(user); err != nil {
 			return nil, nil, err
 		}
+		userId = user.WebAuthnID()
+	}
+
+	opts = append(append([]LoginOption{}, opts...), withTransaction(&tx))
+	return webauthn.beginLogin(userId, allowedCredentials, opts, func(session *SessionData) {
+		session.Transaction.OptionalConfirmation = tx.OptionalConfirmation
+	})
+}
+
+// BeginPaymentConfirmation creates the data of the secure-payment-confirmation payment method for a Secure Payment
+// Confirmation of the payment with a credential of the user. The merchant passes it to the Payment Request API with
+// the total of the payment, the response is validated with ValidatePaymentAssertion against the payment recorded in
+// the session.
+func (webauthn *WebAuthn) BeginPaymentConfirmation(user User, payment protocol.CollectedClientAdditionalPaymentData, opts ...LoginOption) (*protocol.SecurePaymentConfirmationRequest, *SessionData, error) {
+	if user == nil {
+		return nil, nil, protocol.ErrBadRequest.WithDetails("Secure Payment Confirmation requires the credentials of a user")
+	}
+	if err := payment.Validate(); err != nil {
+		return nil, nil, err
+	}
+	allowedCredentials, err := webauthn.allowedCredentials(user)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if len(credentials) == 0 && user != nil { // If the user does not have any credentials, we cannot do login
-		return nil, nil, protocol.ErrBadRequest.WithDetails("Found no credentials for user")
+	payment.RPID = webauthn.Config.RPID
+	assertion, session, err := webauthn.beginLogin(user.WebAuthnID(), allowedCredentials, opts, func(session *SessionData) {
+		session.Payment = &payment
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	options := assertion.Response
+	request := protocol.SecurePaymentConfirmationRequest{
+		Challenge:   options.Challenge,
+		RPID:        webauthn.Config.RPID,
+		Instrument:  payment.Instrument,
+		Timeout:     options.Timeout,
+		PayeeName:   payment.PayeeName,
+		PayeeOrigin: payment.PayeeOrigin,
+		Extensions:  options.Extensions,
+	}
+	for _, credential := range options.AllowedCredentials {
+		request.CredentialIDs = append(request.CredentialIDs, credential.CredentialID)
+	}
+	return &request, session, nil
+}
+
+// allowedCredentials returns the descriptors of the credentials of the user for the allow list of a login
+func (webauthn *WebAuthn) allowedCredentials(user User) ([]protocol.CredentialDescriptor, error) {
+	credentials, err := webauthn.CredentialService.GetCredentialForUser(user.WebAuthnID())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(credentials) == 0 { // If the user does not have any credentials, we cannot do login
+		return nil, protocol.ErrBadRequest.WithDetails("Found no credentials for user")
 	}
 
 	var allowedCredentials = make([]protocol.CredentialDescriptor, len(credentials))
@@ -47,9 +119,40 @@ func (webauthn *WebAuthn) BeginLogin(user User, opts ...LoginOption) (*protocol.
 		var credentialDescriptor protocol.CredentialDescriptor
 		credentialDescriptor.CredentialID = cred.ID
 		credentialDescriptor.Type = protocol.PublicKeyCredentialType
+		for _, transport := range cred.Transports {
+			credentialDescriptor.Transport = append(credentialDescriptor.Transport, protocol.AuthenticatorTransport(transport))
+		}
 		allowedCredentials[i] = credentialDescriptor
 	}
 
+	return allowedCredentials, nil
+}
+
+// BeginDiscoverableLogin creates the CredentialAssertion data payload for a login without a username. The allow list
+// is left empty, so the authenticator offers the discoverable credentials it has for the RP and returns the ID of
+// the user in the userHandle. The response must be finished with FinishDiscoverableLogin.
+func (webauthn *WebAuthn) BeginDiscoverableLogin(opts ...LoginOption) (*protocol.CredentialAssertion, *SessionData, error) {
+	return webauthn.beginLogin(nil, nil, opts)
+}
+
+// BeginConditionalLogin creates the CredentialAssertion data payload for a discoverable login with conditional
+// mediation, which lets the browser offer the discoverable credentials of the user in the autofill UI of a username
+// field instead of showing a modal dialog. It is BeginDiscoverableLogin with
+// WithMediation(protocol.MediationConditional), the response is finished with FinishDiscoverableLogin like any other
+// discoverable login.
+func (webauthn *WebAuthn) BeginConditionalLogin(opts ...LoginOption) (*protocol.CredentialAssertion, *SessionData, error) {
+	opts = append(append([]LoginOption{}, opts...), WithMediation(protocol.MediationConditional))
+	return webauthn.beginLogin(nil, nil, opts)
+}
+
+// beginLogin creates the request options and the session of a login. The session options complete the session data
+// of a Begin function before it is saved.
+func (webauthn *WebAuthn) beginLogin(userId []byte, allowedCredentials []protocol.CredentialDescriptor, opts []LoginOption, sessionOpts ...func(*SessionData)) (*protocol.CredentialAssertion, *SessionData, error) {
+	challenge, err := protocol.CreateChallenge()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	requestOptions := protocol.PublicKeyCredentialRequestOptions{
 		Challenge:          challenge,
 		Timeout:            webauthn.Config.Timeouts.Authentication,
@@ -61,15 +164,31 @@ func (webauthn *WebAuthn) BeginLogin(user User, opts ...LoginOption) (*protocol.
 	for _, setter := range opts {
 		setter(&requestOptions)
 	}
+	if requestOptions.Extensions, err = protocol.BuildExtensionInputs(protocol.AssertCeremony, requestOptions.Extensions); err != nil {
+		return nil, nil, err
+	}
+	transaction := requestedTransaction(requestOptions.Extensions)
+	if transaction != nil {
+		// Browsers ignore the txAuth extensions, the challenge binds the signature to the transaction anyway
+		requestOptions.Challenge = transaction.Challenge(requestOptions.Challenge)
+	}
 
-	newSessionData := SessionData{
-		Challenge:            base64.RawURLEncoding.EncodeToString(requestOptions.Challenge),
-		AllowedCredentialIDs: requestOptions.GetAllowedCredentialIDs(),
-		UserVerification:     requestOptions.UserVerification,
-		Timeout:              requestOptions.Timeout,
+	mediation := requestOptions.Mediation
+	requestOptions.Mediation = ""
+	response := protocol.CredentialAssertion{Response: requestOptions, Mediation: mediation}
+	newSessionData := newSessionData(base64.RawURLEncoding.EncodeToString(requestOptions.Challenge), requestOptions.Timeout, webauthn.Tenant, webauthn.now())
+	newSessionData.UserID = userId
+	newSessionData.AllowedCredentialIDs = requestOptions.GetAllowedCredentialIDs()
+	newSessionData.UserVerification = requestOptions.UserVerification
+	newSessionData.Extensions = requestOptions.Extensions
+	newSessionData.Transaction = transaction
+	for _, setter := range sessionOpts {
+		setter(&newSessionData)
 	}
 
-	response := protocol.CredentialAssertion{Response: requestOptions}
+	if err := webauthn.saveSession(&newSessionData); err != nil {
+		return nil, nil, err
+	}
 
 	return &response, &newSessionData, nil
 }
@@ -89,6 +208,14 @@ func WithUserVerification(userVerification protocol.UserVerificationRequirement)
 	}
 }
 
+// WithMediation requests how the browser mediates the login, e.g. protocol.MediationConditional to offer the
+// discoverable credentials in the autofill UI. It is returned in CredentialAssertion.Mediation.
+func WithMediation(mediation protocol.CredentialMediationRequirement) LoginOption {
+	return func(cco *protocol.PublicKeyCredentialRequestOptions) {
+		cco.Mediation = mediation
+	}
+}
+
 // WithAssertionExtensions requests additional extensions for assertion
 func WithAssertionExtensions(extensions protocol.AuthenticationExtensions) LoginOption {
 	return func(cco *protocol.PublicKeyCredentialRequestOptions) {
@@ -96,14 +223,100 @@ func WithAssertionExtensions(extensions protocol.AuthenticationExtensions) Login
 	}
 }
 
-// WithTransaction request with transaction context
+// WithAppID requests the appid extension with the AppID of a FIDO U2F application, which allows users to log in
+// with credentials that were registered with the U2F JavaScript API
+func WithAppID(appID string) LoginOption {
+	return func(cco *protocol.PublicKeyCredentialRequestOptions) {
+		cco.Extensions = withExtension(cco.Extensions, protocol.ExtensionAppID, appID)
+	}
+}
+
+// WithLargeBlobRead requests the largeBlob extension to read the large blob of the credential, which is returned in
+// the largeBlob client extension output
+func WithLargeBlobRead() LoginOption {
+	return func(cco *protocol.PublicKeyCredentialRequestOptions) {
+		cco.Extensions = withExtension(cco.Extensions, protocol.ExtensionLargeBlob, protocol.LargeBlobInputs{Read: true})
+	}
+}
+
+// WithLargeBlobWrite requests the largeBlob extension to store the blob for the credential. Browsers only write the
+// blob if exactly one credential is allowed, so the login should be started for a single credential with
+// WithAllowedCredentials.
+func WithLargeBlobWrite(blob []byte) LoginOption {
+	return func(cco *protocol.PublicKeyCredentialRequestOptions) {
+		cco.Extensions = withExtension(cco.Extensions, protocol.ExtensionLargeBlob, protocol.LargeBlobInputs{Write: blob})
+	}
+}
+
+// WithPRF requests the prf extension, which lets the authenticator derive secrets from the given salts. The salts
+// of eval are used for every credential, evalByCredential is called for every credential of the allow list built by
+// BeginLogin and may return nil to fall back to eval. It must be passed after WithAllowedCredentials to see a custom
+// allow list. The derived secrets are returned in the prf client extension output.
+func WithPRF(eval *protocol.PRFValues, evalByCredential func(credentialId []byte) *protocol.PRFValues) LoginOption {
+	return func(cco *protocol.PublicKeyCredentialRequestOptions) {
+		inputs := protocol.PRFInputs{Eval: eval}
+		if evalByCredential != nil {
+			for _, allowed := range cco.AllowedCredentials {
+				values := evalByCredential(allowed.CredentialID)
+				if values == nil {
+					continue
+				}
+				if inputs.EvalByCredential == nil {
+					inputs.EvalByCredential = make(map[string]protocol.PRFValues)
+				}
+				inputs.EvalByCredential[base64.RawURLEncoding.EncodeToString(allowed.CredentialID)] = *values
+			}
+		}
+		cco.Extensions = withExtension(cco.Extensions, protocol.ExtensionPRF, inputs)
+	}
+}
+
+// WithTransaction binds the login to the transaction text by deriving the challenge from it and requests its
+// confirmation with the txAuthSimple extension. ValidateLogin fails if the authenticator did not return the
+// confirmed text. Current browsers do not support txAuthSimple, so such a login always fails in them; use
+// BeginTransactionLogin with OptionalConfirmation to accept logins which are only bound by the challenge. The last
+// transaction option wins, an empty text requests no transaction.
 func WithTransaction(transaction string) LoginOption {
+	return withTransaction(&protocol.Transaction{Text: transaction})
+}
+
+// WithGenericTransaction binds the login to the transaction content, e.g. an image, like WithTransaction and requests
+// its confirmation with the txAuthGeneric extension.
+func WithGenericTransaction(contentType string, content []byte) LoginOption {
+	return withTransaction(&protocol.Transaction{Generic: &protocol.TxAuthGenericArg{ContentType: contentType, Content: content}})
+}
+
+// withTransaction replaces the transaction requested by a previous option, the challenge is derived from the
+// requested transaction by beginLogin
+func withTransaction(tx *protocol.Transaction) LoginOption {
 	return func(cco *protocol.PublicKeyCredentialRequestOptions) {
-		if transaction != "" {
-			transactionHash := sha256.Sum256([]byte(transaction))
-			cco.Challenge = append(cco.Challenge, transactionHash[:]...)
+		extensions := make(protocol.AuthenticationExtensions, len(cco.Extensions)+1)
+		for identifier, input := range cco.Extensions {
+			if identifier != protocol.ExtensionTxAuthSimple && identifier != protocol.ExtensionTxAuthGeneric {
+				extensions[identifier] = input
+			}
+		}
+		if !tx.Empty() {
+			for identifier, input := range tx.Extensions() {
+				extensions[identifier] = input
+			}
 		}
+		cco.Extensions = extensions
+	}
+}
+
+// requestedTransaction returns the transaction requested by WithTransaction or WithGenericTransaction, nil if no
+// transaction has to be confirmed
+func requestedTransaction(extensions protocol.AuthenticationExtensions) *protocol.Transaction {
+	var text string
+	if err := json.Unmarshal(extensions[protocol.ExtensionTxAuthSimple], &text); err == nil {
+		return &protocol.Transaction{Text: text}
 	}
+	var generic protocol.TxAuthGenericArg
+	if err := json.Unmarshal(extensions[protocol.ExtensionTxAuthGeneric], &generic); err == nil {
+		return &protocol.Transaction{Generic: &generic}
+	}
+	return nil
 }
 
 // WithLoginTimeout adds a custom timeout in milliseconds for the Login Operation
@@ -113,31 +326,173 @@ func WithLoginTimeout(timeout int) LoginOption {
 	}
 }
 
+// LoginResult contains the outcome of a successful login ceremony
+type LoginResult struct {
+	// The credential used for the login, with the updated sign count and backup state
+	Credential *credential.Credential
+	// The ID of the user the credential belongs to
+	UserID []byte
+	// Indicates if the backup state of the credential changed since the credential was last used, e.g. because a
+	// passkey was synced to the cloud. The new state is available in Credential.BackupState.
+	BackupStateChanged bool
+	// The user resolved by the DiscoverableUserHandler, nil if the login was not a discoverable login
+	User User
+	// The verified outputs of the requested extensions
+	Extensions protocol.ExtensionOutputs
+	// Indicates that the signature counter did not increase, but the CounterPolicy let the login succeed. The
+	// authenticator may be cloned.
+	CloneWarning bool
+	// How the signature counter of the assertion compared to the stored counter
+	CounterStatus CounterStatus
+	// The signature counter returned by the authenticator. It is only stored in the credential if it increased.
+	SignCount uint32
+	// Indicates if the authenticator tested the user presence
+	UserPresent bool
+	// Indicates if the authenticator verified the user, e.g. by a PIN or biometrics. It can be used for step-up
+	// decisions even if user verification was not required.
+	UserVerified bool
+	// The origin of the ceremony as reported by the client
+	Origin string
+	// The origin of the top-level document, only set if the ceremony was made from a cross-origin iframe
+	TopOrigin string
+}
+
+// CounterStatus describes how the signature counter of a successful login compared to the stored counter
+type CounterStatus string
+
+const (
+	// CounterIncreased means the counter increased, as expected from an authenticator which implements a counter
+	CounterIncreased CounterStatus = "increased"
+	// CounterUnsupported means the authenticator does not implement a counter, both counters are zero
+	CounterUnsupported CounterStatus = "unsupported"
+	// CounterAccepted means the counter did not increase, but the CounterPolicy accepted the login
+	CounterAccepted CounterStatus = "accepted"
+	// CounterFlagged means the counter did not increase and the CounterPolicy flagged the login, see CloneWarning
+	CounterFlagged CounterStatus = "flagged"
+)
+
+// DiscoverableUserHandler resolves the user of a discoverable login from the raw ID of the credential and the
+// userHandle returned by the authenticator, which is the ID of the user set during the registration.
+type DiscoverableUserHandler func(rawID, userHandle []byte) (User, error)
+
 // FinishLogin takes the response from the client and validates it against the user credentials and stored session data
-func (webauthn *WebAuthn) FinishLogin(session SessionData, response *http.Request) (credential *credential.Credential, userId []byte, error error) {
+func (webauthn *WebAuthn) FinishLogin(session SessionData, response *http.Request) (*LoginResult, error) {
 	parsedResponse, err := protocol.ParseCredentialRequestResponse(response)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	return webauthn.ValidateLogin(session, parsedResponse)
 }
 
+// FinishStoredLogin takes the response from the client, consumes the matching session from the SessionStore and
+// validates the response against it. A response can only be finished once, a replayed response fails with
+// protocol.ErrSessionAlreadyUsed.
+func (webauthn *WebAuthn) FinishStoredLogin(response *http.Request) (*LoginResult, error) {
+	parsedResponse, err := protocol.ParseCredentialRequestResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := webauthn.ConsumeSession(parsedResponse.Response.CollectedClientData.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	return webauthn.ValidateLogin(*session, parsedResponse)
+}
+
+// FinishDiscoverableLogin takes the response of a login started with BeginDiscoverableLogin and validates it against
+// the credentials of the user returned by the handler and the stored session data
+func (webauthn *WebAuthn) FinishDiscoverableLogin(handler DiscoverableUserHandler, session SessionData, response *http.Request) (*LoginResult, error) {
+	parsedResponse, err := protocol.ParseCredentialRequestResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	return webauthn.ValidateDiscoverableLogin(handler, session, parsedResponse)
+}
+
+// FinishStoredDiscoverableLogin works like FinishDiscoverableLogin, but consumes the session from the SessionStore
+func (webauthn *WebAuthn) FinishStoredDiscoverableLogin(handler DiscoverableUserHandler, response *http.Request) (*LoginResult, error) {
+	parsedResponse, err := protocol.ParseCredentialRequestResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := webauthn.ConsumeSession(parsedResponse.Response.CollectedClientData.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	return webauthn.ValidateDiscoverableLogin(handler, *session, parsedResponse)
+}
+
+// ValidateDiscoverableLogin takes a parsed response of a discoverable login and validates it. The user is resolved
+// from the userHandle of the response, which must be present and must belong to the owner of the credential.
+func (webauthn *WebAuthn) ValidateDiscoverableLogin(handler DiscoverableUserHandler, session SessionData, parsedResponse *protocol.ParsedCredentialAssertionData) (*LoginResult, error) {
+	if len(session.UserID) > 0 {
+		return nil, protocol.ErrBadRequest.WithDetails("Session was not started for a discoverable login")
+	}
+
+	userHandle := parsedResponse.Response.UserHandle
+	if len(userHandle) == 0 {
+		return nil, protocol.ErrBadRequest.WithDetails("userHandle is required for a discoverable login")
+	}
+
+	user, err := handler(parsedResponse.RawID, userHandle)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !bytes.Equal(user.WebAuthnID(), userHandle) {
+		return nil, protocol.ErrUserMismatch.WithDetails("userHandle does not belong to the resolved user")
+	}
+
+	result, err := webauthn.ValidateLogin(session, parsedResponse)
+	if err != nil {
+		return nil, err
+	}
+	result.User = user
+
+	return result, nil
+}
+
 // ValidateLogin takes a parsed response and validates it against the user credentials and session data
-func (webauthn *WebAuthn) ValidateLogin(session SessionData, parsedResponse *protocol.ParsedCredentialAssertionData) (credential *credential.Credential, userId []byte, error error) {
+func (webauthn *WebAuthn) ValidateLogin(session SessionData, parsedResponse *protocol.ParsedCredentialAssertionData) (*LoginResult, error) {
+	return webauthn.validateLogin(session, parsedResponse, protocol.AssertCeremony)
+}
+
+// ValidatePaymentAssertion takes a parsed Secure Payment Confirmation assertion and validates it like a login. In
+// addition the payment details the user confirmed, including the payee origin, must match the payment recorded in the
+// session by BeginPaymentConfirmation. The origin of the client data may be one of the RPOrigins and RPRelatedOrigins
+// like for a login, or the payee origin or top origin of the issued payment, which is the merchant page that started
+// the payment. The merchants do not have to be added to the RPOrigins, which would also allow them to start logins.
+func (webauthn *WebAuthn) ValidatePaymentAssertion(session SessionData, parsedResponse *protocol.ParsedCredentialAssertionData) (*LoginResult, error) {
+	return webauthn.validateLogin(session, parsedResponse, protocol.PaymentCeremony)
+}
+
+// validateLogin validates a login or, for the payment ceremony, a Secure Payment Confirmation
+func (webauthn *WebAuthn) validateLogin(session SessionData, parsedResponse *protocol.ParsedCredentialAssertionData, ceremony protocol.CeremonyType) (*LoginResult, error) {
+	if err := session.verifyNotExpired(webauthn.now()); err != nil {
+		return nil, err
+	}
+	if err := session.verifyTenant(webauthn.Tenant); err != nil {
+		return nil, err
+	}
+
 	// Step 1. If the allowCredentials option was given when this authentication ceremony was initiated,
 	// verify that credential.id identifies one of the public key credentials that were listed in
 	// allowCredentials.
 	if len(session.AllowedCredentialIDs) > 0 {
 		var credentialAllowed bool
 		for _, allowedCredentialId := range session.AllowedCredentialIDs {
-			if bytes.Equal(allowedCredentialId, parsedResponse.Response.AuthenticatorData.AttData.CredentialID) {
+			if bytes.Equal(allowedCredentialId, parsedResponse.RawID) {
 				credentialAllowed = true
 				break
 			}
 		}
 		if !credentialAllowed {
-			return nil, nil, protocol.ErrBadRequest.WithDetails("Credential is not allowed by allowedCredential list")
+			return nil, protocol.ErrCredentialNotAllowed.WithDetails("Credential is not allowed by allowedCredential list")
 		}
 	}
 
@@ -145,38 +500,128 @@ func (webauthn *WebAuthn) ValidateLogin(session SessionData, parsedResponse *pro
 	// for your use case), look up the corresponding credential public key.
 	cred, userId, err := webauthn.CredentialService.GetCredential(parsedResponse.RawID)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	if cred == nil || userId == nil || len(userId) == 0 {
-		return nil, nil, protocol.ErrCredentialNotFound
+		return nil, protocol.ErrCredentialNotFound
 	}
-
-	// Step 2. If credential.response.userHandle is present, verify that the user identified by this value is
-	// the owner of the public key credential identified by credential.id.
+	// Step 2. If the user was identified before the authentication ceremony was initiated, verify that the
+	// identified user is the owner of the credential. If credential.response.userHandle is present, verify that
+	// the user identified by this value is the owner of the public key credential identified by credential.id.
+	// If the user was not identified before, the userHandle must be present.
 	userHandle := parsedResponse.Response.UserHandle
+	if len(session.UserID) > 0 {
+		if !bytes.Equal(userId, session.UserID) {
+			return nil, protocol.ErrUserMismatch.WithDetails("Credential does not belong to the user of the session")
+		}
+	} else if len(userHandle) == 0 {
+		return nil, protocol.ErrBadRequest.WithDetails("userHandle is required if the user was not identified before the login")
+	}
 	if len(userHandle) > 0 {
 		if !bytes.Equal(userId, parsedResponse.Response.UserHandle) {
-			return nil, nil, protocol.ErrBadRequest.WithDetails("userHandle and User ID do not match")
+			return nil, protocol.ErrUserMismatch.WithDetails("userHandle and User ID do not match")
 		}
 	}
 
 	shouldVerifyUser := session.UserVerification == protocol.VerificationRequired
 
-	rpID := webauthn.Config.RPID
-	rpOrigins := webauthn.Config.RPOrigins
+	// A credential registered with U2F is scoped to the AppID, which the client uses instead of the RP ID
+	rpID := parsedResponse.RelyingPartyID(webauthn.Config.RPID, session.Extensions)
+	origins, err := webauthn.Config.origins()
+	if err != nil {
+		return nil, err
+	}
 
 	// Handle steps 4 through 16
-	validError := parsedResponse.Verify(session.Challenge, rpID, rpOrigins, shouldVerifyUser, cred.PublicKey)
+	var validError error
+	if ceremony == protocol.PaymentCeremony {
+		validError = parsedResponse.VerifyPayment(session.Challenge, rpID, origins.rules, origins.topRules, shouldVerifyUser, cred.PublicKey, session.Payment)
+	} else {
+		validError = parsedResponse.Verify(session.Challenge, rpID, origins.rules, origins.topRules, shouldVerifyUser, cred.PublicKey)
+	}
 	if validError != nil {
-		return nil, nil, validError
+		return nil, validError
 	}
 
-	// Handle step 17
-	err = cred.Authenticator.CheckCounter(parsedResponse.Response.AuthenticatorData.Counter)
+	// A quarantined credential is only reported after the response was verified, so that its state is not revealed
+	// to someone who does not own the credential
+	if cred.Quarantined {
+		return nil, protocol.ErrCredentialQuarantined
+	}
+
+	// Step 14. Verify the client and authenticator extension outputs against the requested extensions
+	extensionOutputs, err := protocol.VerifyExtensions(protocol.AssertCeremony, session.Extensions, parsedResponse.Extensions, &parsedResponse.Response.AuthenticatorData)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if session.Transaction != nil {
+		if err := session.Transaction.Verify(session.Challenge, extensionOutputs); err != nil {
+			return nil, err
+		}
+	}
+
+	// Handle step 17. A counter which did not increase is a signal that the authenticator may be cloned, the
+	// CounterPolicy decides whether the login fails anyway.
+	signCount := parsedResponse.Response.AuthenticatorData.Counter
+	counterStatus := CounterIncreased
+	if signCount == 0 && cred.Authenticator.SignCount == 0 {
+		counterStatus = CounterUnsupported
+	}
+	switch protocol.CheckCounter(webauthn.CounterPolicy, &cred.Authenticator, signCount) {
+	case protocol.CounterReject:
+		return nil, protocol.ErrCounterError.WithInfo(fmt.Sprintf("Stored counter: %d, received: %d", cred.Authenticator.SignCount, signCount))
+	case protocol.CounterQuarantine:
+		cred.Quarantined = true
+		if err := webauthn.CredentialService.UpdateCredential(cred); err != nil {
+			return nil, err
+		}
+		counterErr := protocol.ErrCounterError.WithInfo(fmt.Sprintf("Stored counter: %d, received: %d", cred.Authenticator.SignCount, signCount))
+		return nil, protocol.ErrCredentialQuarantined.Wrap(counterErr).WithInfo(counterErr.DevInfo)
+	case protocol.CounterFlag:
+		counterStatus = CounterFlagged
+	default:
+		if signCount <= cred.Authenticator.SignCount && counterStatus != CounterUnsupported {
+			counterStatus = CounterAccepted
+		}
+	}
+	// The stored counter never decreases, so that a clone keeps being detected
+	if signCount > cred.Authenticator.SignCount {
+		cred.Authenticator.UpdateCounter(signCount)
+	}
+	cred.LastUsedAt = webauthn.now()
+
+	// The backup eligibility of a credential is fixed when it is created, while the backup state may change over
+	// its lifetime and is reported to the caller. Credentials stored before the flags were recorded learn them on
+	// their first login.
+	flags := parsedResponse.Response.AuthenticatorData.Flags
+	backupStateChanged := false
+	if cred.BackupEligible == nil {
+		backupEligible := flags.BackupEligible()
+		cred.BackupEligible = &backupEligible
+	} else {
+		if *cred.BackupEligible != flags.BackupEligible() {
+			return nil, protocol.ErrBackupEligibilityChanged.WithDetails("Backup eligibility of the credential changed")
+		}
+		backupStateChanged = cred.BackupState != flags.BackupState()
+	}
+	cred.BackupState = flags.BackupState()
+
+	err = webauthn.CredentialService.UpdateCredential(cred)
+	if err != nil {
+		return nil, err
 	}
-	cred.Authenticator.UpdateCounter(parsedResponse.Response.AuthenticatorData.Counter)
 
-	return cred, userId, nil
+	return &LoginResult{
+		Credential:         cred,
+		UserID:             userId,
+		BackupStateChanged: backupStateChanged,
+		Extensions:         extensionOutputs,
+		CloneWarning:       counterStatus == CounterFlagged,
+		CounterStatus:      counterStatus,
+		SignCount:          signCount,
+		UserPresent:        flags.UserPresent(),
+		UserVerified:       flags.UserVerified(),
+		Origin:             parsedResponse.Response.CollectedClientData.Origin,
+		TopOrigin:          parsedResponse.Response.CollectedClientData.TopOrigin,
+	}, nil
 }
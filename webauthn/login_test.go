@@ -1,7 +1,16 @@
 package webauthn
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/teamhanko/webauthn-go/credential"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/teamhanko/webauthn-go/protocol"
 )
@@ -12,14 +21,1378 @@ func TestLogin_FinishLoginFailure(t *testing.T) {
 	}
 
 	webauthn := &WebAuthn{}
-	credential, userId, err := webauthn.FinishLogin(session, nil)
+	result, err := webauthn.FinishLogin(session, nil)
 	if err == nil {
 		t.Errorf("FinishLogin() error = nil, want %v", protocol.ErrBadRequest.Type)
 	}
-	if credential != nil {
-		t.Errorf("FinishLogin() credential = %v, want nil", credential)
+	if result != nil {
+		t.Errorf("FinishLogin() result = %v, want nil", result)
 	}
-	if userId != nil {
-		t.Errorf("FinishLogin() user_id = %v, want nil", userId)
+}
+
+func TestLogin_ValidateLoginExpiredSession(t *testing.T) {
+	clock := &testClock{now: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)}
+	webauthn := &WebAuthn{
+		Config: &Config{
+			RPID:          "localhost",
+			RPDisplayName: "Test Relying Party",
+			Timeouts: Timeouts{
+				Authentication: 1000,
+			},
+		},
+		Clock: clock,
+	}
+
+	_, sessionData, err := webauthn.BeginLogin(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantExpires := clock.now.Add(time.Second)
+	if !sessionData.Expires.Equal(wantExpires) {
+		t.Errorf("BeginLogin() sessionData.Expires = %s, want %s", sessionData.Expires, wantExpires)
+	}
+
+	clock.now = clock.now.Add(1001 * time.Millisecond)
+	result, err := webauthn.ValidateLogin(*sessionData, &protocol.ParsedCredentialAssertionData{})
+	if e, ok := err.(*protocol.Error); !ok || e.Type != protocol.ErrSessionExpired.Type {
+		t.Errorf("ValidateLogin() error = %v, want %v", err, protocol.ErrSessionExpired)
+	}
+	if result != nil {
+		t.Errorf("ValidateLogin() result = %v, want nil", result)
+	}
+}
+
+// None Attestation - MacOS TouchID, see protocol/assertion_test.go
+const testAssertionResponse = `{
+	"id":"AI7D5q2P0LS-Fal9ZT7CHM2N5BLbUunF92T8b6iYC199bO2kagSuU05-5dZGqb1SP0A0lyTWng",
+	"rawId":"AI7D5q2P0LS-Fal9ZT7CHM2N5BLbUunF92T8b6iYC199bO2kagSuU05-5dZGqb1SP0A0lyTWng",
+	"type":"public-key",
+	"response":{
+		"authenticatorData":"dKbqkhPJnC90siSSsyDPQCYqlMGpUKA5fyklC2CEHvBFXJJiGa3OAAI1vMYKZIsLJfHwVQMANwCOw-atj9C0vhWpfWU-whzNjeQS21Lpxfdk_G-omAtffWztpGoErlNOfuXWRqm9Uj9ANJck1p6lAQIDJiABIVggKAhfsdHcBIc0KPgAcRyAIK_-Vi-nCXHkRHPNaCMBZ-4iWCBxB8fGYQSBONi9uvq0gv95dGWlhJrBwCsj_a4LJQKVHQ",
+		"clientDataJSON":"eyJjaGFsbGVuZ2UiOiJFNFBUY0lIX0hmWDFwQzZTaWdrMVNDOU5BbGdlenROMDQzOXZpOHpfYzlrIiwibmV3X2tleXNfbWF5X2JlX2FkZGVkX2hlcmUiOiJkbyBub3QgY29tcGFyZSBjbGllbnREYXRhSlNPTiBhZ2FpbnN0IGEgdGVtcGxhdGUuIFNlZSBodHRwczovL2dvby5nbC95YWJQZXgiLCJvcmlnaW4iOiJodHRwczovL3dlYmF1dGhuLmlvIiwidHlwZSI6IndlYmF1dGhuLmdldCJ9",
+		"signature":"MEUCIBtIVOQxzFYdyWQyxaLR0tik1TnuPhGVhXVSNgFwLmN5AiEAnxXdCq0UeAVGWxOaFcjBZ_mEZoXqNboY5IkQDdlWZYc",
+		"userHandle":"0ToAAAAAAAAAAA"}
+	}`
+
+func newTestLoginWebAuthn(t *testing.T) (*WebAuthn, *credential.InMemoryCredentialService, *credential.Credential, []byte) {
+	credentialID, _ := base64.RawURLEncoding.DecodeString("AI7D5q2P0LS-Fal9ZT7CHM2N5BLbUunF92T8b6iYC199bO2kagSuU05-5dZGqb1SP0A0lyTWng")
+	publicKey, _ := base64.RawURLEncoding.DecodeString("pQMmIAEhWCAoCF-x0dwEhzQo-ABxHIAgr_5WL6cJceREc81oIwFn7iJYIHEHx8ZhBIE42L26-rSC_3l0ZaWEmsHAKyP9rgslApUdAQI")
+	userId, _ := base64.RawURLEncoding.DecodeString("0ToAAAAAAAAAAA")
+
+	cred := &credential.Credential{
+		ID:              credentialID,
+		PublicKey:       publicKey,
+		AttestationType: "none",
+		Authenticator: credential.Authenticator{
+			SignCount: 1553097000,
+		},
+	}
+	credentialService := credential.NewInMemoryCredentialService()
+	if err := credentialService.StoreCredential(userId, cred); err != nil {
+		t.Fatal(err)
+	}
+
+	webauthn, err := New(&Config{
+		RPDisplayName: "WebAuthn.io",
+		RPID:          "webauthn.io",
+		RPOrigin:      "https://webauthn.io",
+	}, nil, credentialService, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return webauthn, credentialService, cred, userId
+}
+
+func TestLogin_ValidateLoginUpdatesCredential(t *testing.T) {
+	webauthn, credentialService, cred, userId := newTestLoginWebAuthn(t)
+	clock := &testClock{now: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)}
+	webauthn.Clock = clock
+
+	parsedResponse, err := protocol.ParseCredentialRequestResponseBody(strings.NewReader(testAssertionResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := SessionData{
+		Challenge:            "E4PTcIH_HfX1pC6Sigk1SC9NAlgeztN0439vi8z_c9k",
+		UserID:               userId,
+		AllowedCredentialIDs: [][]byte{cred.ID},
+	}
+	result, err := webauthn.ValidateLogin(session, parsedResponse)
+	if err != nil {
+		t.Fatalf("ValidateLogin() error = %v", err)
+	}
+	if !bytes.Equal(result.UserID, userId) {
+		t.Errorf("ValidateLogin() userId = %v, want %v", result.UserID, userId)
+	}
+	if result.Credential.Authenticator.SignCount != 1553097241 {
+		t.Errorf("ValidateLogin() credential.Authenticator.SignCount = %d, want %d", result.Credential.Authenticator.SignCount, 1553097241)
+	}
+
+	stored, _, _ := credentialService.GetCredential(cred.ID)
+	if stored.Authenticator.SignCount != 1553097241 {
+		t.Errorf("stored credential.Authenticator.SignCount = %d, want %d", stored.Authenticator.SignCount, 1553097241)
+	}
+	if !stored.LastUsedAt.Equal(clock.now) {
+		t.Errorf("stored credential.LastUsedAt = %s, want %s", stored.LastUsedAt, clock.now)
+	}
+
+	// The response is replayed, the persisted counter must reject it
+	_, err = webauthn.ValidateLogin(session, parsedResponse)
+	if err == nil {
+		t.Errorf("ValidateLogin() with replayed counter error = nil, want error")
+	}
+}
+
+func TestLogin_BeginLoginAddsTransports(t *testing.T) {
+	webauthn, credentialService, _, userId := newTestLoginWebAuthn(t)
+
+	withTransports := &credential.Credential{
+		ID:         []byte("credential-with-transports"),
+		PublicKey:  []byte("public-key"),
+		Transports: []string{"usb", "hybrid"},
+	}
+	if err := credentialService.StoreCredential(userId, withTransports); err != nil {
+		t.Fatal(err)
+	}
+
+	assertion, _, err := webauthn.BeginLogin(&defaultUser{id: userId})
+	if err != nil {
+		t.Fatalf("BeginLogin() error = %v", err)
+	}
+
+	want := map[string][]protocol.AuthenticatorTransport{
+		string(withTransports.ID): {protocol.USB, protocol.Hybrid},
+	}
+	for _, descriptor := range assertion.Response.AllowedCredentials {
+		if !reflect.DeepEqual(descriptor.Transport, want[string(descriptor.CredentialID)]) {
+			t.Errorf("AllowedCredentials[%s].Transport = %v, want %v", string(descriptor.CredentialID), descriptor.Transport, want[string(descriptor.CredentialID)])
+		}
+	}
+	if len(assertion.Response.AllowedCredentials) != 2 {
+		t.Errorf("len(AllowedCredentials) = %d, want 2", len(assertion.Response.AllowedCredentials))
+	}
+}
+
+func TestLogin_ValidateLoginBackupState(t *testing.T) {
+	tests := []struct {
+		name             string
+		storedEligible   bool
+		storedState      bool
+		unknownEligible  bool
+		flags            protocol.AuthenticatorFlags
+		wantStateChanged bool
+		wantErr          bool
+	}{
+		{
+			name:            "Unknown backup eligibility is learned",
+			unknownEligible: true,
+			flags:           protocol.FlagUserPresent | protocol.FlagBackupEligible | protocol.FlagBackupState,
+		},
+		{
+			name:  "Device-bound credential",
+			flags: protocol.FlagUserPresent,
+		},
+		{
+			name:           "Backup state unchanged",
+			storedEligible: true,
+			storedState:    true,
+			flags:          protocol.FlagUserPresent | protocol.FlagBackupEligible | protocol.FlagBackupState,
+		},
+		{
+			name:             "Credential was backed up",
+			storedEligible:   true,
+			flags:            protocol.FlagUserPresent | protocol.FlagBackupEligible | protocol.FlagBackupState,
+			wantStateChanged: true,
+		},
+		{
+			name:             "Credential is no longer backed up",
+			storedEligible:   true,
+			storedState:      true,
+			flags:            protocol.FlagUserPresent | protocol.FlagBackupEligible,
+			wantStateChanged: true,
+		},
+		{
+			name:    "Backup eligibility changed",
+			flags:   protocol.FlagUserPresent | protocol.FlagBackupEligible,
+			wantErr: true,
+		},
+		{
+			name:    "Backup state without backup eligibility",
+			flags:   protocol.FlagUserPresent | protocol.FlagBackupState,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t)
+			userId := []byte("user-1")
+			cred := authenticator.Credential(t)
+			cred.BackupEligible = &tt.storedEligible
+			if tt.unknownEligible {
+				cred.BackupEligible = nil
+			}
+			cred.BackupState = tt.storedState
+			credentialService := credential.NewInMemoryCredentialService()
+			if err := credentialService.StoreCredential(userId, cred); err != nil {
+				t.Fatal(err)
+			}
+			webauthn, err := New(&Config{
+				RPDisplayName: "WebAuthn.io",
+				RPID:          testRPID,
+				RPOrigin:      testOrigin,
+			}, nil, credentialService, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			session := SessionData{Challenge: "E4PTcIH_HfX1pC6Sigk1SC9NAlgeztN0439vi8z_c9k", UserID: userId}
+			parsedResponse := authenticator.getAssertion(t, testAssertion{
+				Challenge:  session.Challenge,
+				Flags:      tt.flags,
+				UserHandle: userId,
+			})
+
+			result, err := webauthn.ValidateLogin(session, parsedResponse)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if result.BackupStateChanged != tt.wantStateChanged {
+				t.Errorf("ValidateLogin() BackupStateChanged = %v, want %v", result.BackupStateChanged, tt.wantStateChanged)
+			}
+
+			stored, _, _ := credentialService.GetCredential(cred.ID)
+			if stored.BackupState != tt.flags.BackupState() {
+				t.Errorf("stored credential.BackupState = %v, want %v", stored.BackupState, tt.flags.BackupState())
+			}
+			if stored.BackupEligible == nil || *stored.BackupEligible != tt.flags.BackupEligible() {
+				t.Errorf("stored credential.BackupEligible = %v, want %v", stored.BackupEligible, tt.flags.BackupEligible())
+			}
+		})
+	}
+}
+
+func newTestAuthenticatorWebAuthn(t *testing.T, authenticator *testAuthenticator, userId []byte) (*WebAuthn, *credential.InMemoryCredentialService) {
+	credentialService := credential.NewInMemoryCredentialService()
+	if err := credentialService.StoreCredential(userId, authenticator.Credential(t)); err != nil {
+		t.Fatal(err)
+	}
+	webauthn, err := New(&Config{
+		RPDisplayName: "WebAuthn.io",
+		RPID:          testRPID,
+		RPOrigin:      testOrigin,
+	}, nil, credentialService, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return webauthn, credentialService
+}
+
+func TestLogin_BeginDiscoverableLogin(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, []byte("user-1"))
+
+	assertion, sessionData, err := webauthn.BeginDiscoverableLogin()
+	if err != nil {
+		t.Fatalf("BeginDiscoverableLogin() error = %v", err)
+	}
+	if len(assertion.Response.AllowedCredentials) != 0 {
+		t.Errorf("BeginDiscoverableLogin() AllowedCredentials = %v, want empty", assertion.Response.AllowedCredentials)
+	}
+	if len(sessionData.UserID) != 0 || len(sessionData.AllowedCredentialIDs) != 0 {
+		t.Errorf("BeginDiscoverableLogin() sessionData = %+v, want no user and no allowed credentials", sessionData)
+	}
+
+	_, sessionData, err = webauthn.BeginLogin(&defaultUser{id: []byte("user-1")})
+	if err != nil {
+		t.Fatalf("BeginLogin() error = %v", err)
+	}
+	if string(sessionData.UserID) != "user-1" {
+		t.Errorf("BeginLogin() sessionData.UserID = %s, want user-1", string(sessionData.UserID))
+	}
+}
+
+func TestLogin_ValidateDiscoverableLogin(t *testing.T) {
+	userId := []byte("user-1")
+	tests := []struct {
+		name        string
+		sessionUser []byte
+		userHandle  []byte
+		handlerUser User
+		handlerErr  error
+		wantErr     bool
+	}{
+		{
+			name:        "Success",
+			userHandle:  userId,
+			handlerUser: &defaultUser{id: userId},
+		},
+		{
+			name:        "Missing userHandle",
+			handlerUser: &defaultUser{id: userId},
+			wantErr:     true,
+		},
+		{
+			name:        "userHandle of another user",
+			userHandle:  []byte("user-2"),
+			handlerUser: &defaultUser{id: []byte("user-2")},
+			wantErr:     true,
+		},
+		{
+			name:        "Handler returns another user",
+			userHandle:  userId,
+			handlerUser: &defaultUser{id: []byte("user-2")},
+			wantErr:     true,
+		},
+		{
+			name:       "Handler fails",
+			userHandle: userId,
+			handlerErr: protocol.ErrBadRequest.WithDetails("Unknown user"),
+			wantErr:    true,
+		},
+		{
+			name:        "Session for a specific user",
+			sessionUser: userId,
+			userHandle:  userId,
+			handlerUser: &defaultUser{id: userId},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t)
+			webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+
+			session := SessionData{Challenge: "E4PTcIH_HfX1pC6Sigk1SC9NAlgeztN0439vi8z_c9k", UserID: tt.sessionUser}
+			parsedResponse := authenticator.getAssertion(t, testAssertion{
+				Challenge:  session.Challenge,
+				Flags:      protocol.FlagUserPresent | protocol.FlagUserVerified,
+				UserHandle: tt.userHandle,
+			})
+
+			handler := func(rawID, userHandle []byte) (User, error) {
+				if !bytes.Equal(rawID, authenticator.CredentialID) {
+					t.Errorf("DiscoverableUserHandler() rawID = %v, want %v", rawID, authenticator.CredentialID)
+				}
+				return tt.handlerUser, tt.handlerErr
+			}
+			result, err := webauthn.ValidateDiscoverableLogin(handler, session, parsedResponse)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateDiscoverableLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if result.User != tt.handlerUser || !bytes.Equal(result.UserID, userId) {
+				t.Errorf("ValidateDiscoverableLogin() user = %v, %s, want %v, %s", result.User, string(result.UserID), tt.handlerUser, string(userId))
+			}
+		})
+	}
+}
+
+func TestLogin_ValidateLoginCredentialOwner(t *testing.T) {
+	tests := []struct {
+		name        string
+		sessionUser []byte
+		userHandle  []byte
+		wantErr     bool
+	}{
+		{"Identified user", []byte("user-1"), nil, false},
+		{"Identified user with userHandle", []byte("user-1"), []byte("user-1"), false},
+		{"Credential of another user", []byte("user-2"), nil, true},
+		{"Unidentified user without userHandle", nil, nil, true},
+		{"Unidentified user with userHandle", nil, []byte("user-1"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t)
+			webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, []byte("user-1"))
+
+			session := SessionData{Challenge: "E4PTcIH_HfX1pC6Sigk1SC9NAlgeztN0439vi8z_c9k", UserID: tt.sessionUser}
+			parsedResponse := authenticator.getAssertion(t, testAssertion{
+				Challenge:  session.Challenge,
+				Flags:      protocol.FlagUserPresent,
+				UserHandle: tt.userHandle,
+			})
+
+			_, err := webauthn.ValidateLogin(session, parsedResponse)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLogin_WithMediation(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	userId := []byte("user-1")
+	webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+
+	tests := []struct {
+		name  string
+		begin func() (*protocol.CredentialAssertion, *SessionData, error)
+		want  protocol.CredentialMediationRequirement
+	}{
+		{
+			name: "Login without mediation",
+			begin: func() (*protocol.CredentialAssertion, *SessionData, error) {
+				return webauthn.BeginLogin(&defaultUser{id: userId})
+			},
+		},
+		{
+			name: "Login with conditional mediation",
+			begin: func() (*protocol.CredentialAssertion, *SessionData, error) {
+				return webauthn.BeginLogin(&defaultUser{id: userId}, WithMediation(protocol.MediationConditional))
+			},
+			want: protocol.MediationConditional,
+		},
+		{
+			name: "Discoverable login with required mediation",
+			begin: func() (*protocol.CredentialAssertion, *SessionData, error) {
+				return webauthn.BeginDiscoverableLogin(WithMediation(protocol.MediationRequired))
+			},
+			want: protocol.MediationRequired,
+		},
+		{
+			name: "Conditional login",
+			begin: func() (*protocol.CredentialAssertion, *SessionData, error) {
+				return webauthn.BeginConditionalLogin()
+			},
+			want: protocol.MediationConditional,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertion, _, err := tt.begin()
+			if err != nil {
+				t.Fatalf("begin error = %v", err)
+			}
+			if assertion.Mediation != tt.want || assertion.Response.Mediation != "" {
+				t.Errorf("assertion.Mediation = %q, want %q", assertion.Mediation, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogin_ConditionalMediation(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	userId := []byte("user-1")
+	webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+	webauthn.SessionStore = NewInMemorySessionStore(webauthn.Clock)
+
+	// Options which only know the request options keep working for conditional logins
+	customTimeout := func(cco *protocol.PublicKeyCredentialRequestOptions) {
+		cco.Timeout = 300000
+	}
+	assertion, sessionData, err := webauthn.BeginConditionalLogin(customTimeout)
+	if err != nil {
+		t.Fatalf("BeginConditionalLogin() error = %v", err)
+	}
+	if assertion.Response.Timeout != 300000 || len(assertion.Response.AllowedCredentials) != 0 {
+		t.Errorf("BeginConditionalLogin() assertion.Response = %+v, want custom timeout without allowed credentials", assertion.Response)
+	}
+	assertionJSON, err := json.Marshal(assertion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(assertionJSON), `"mediation":"conditional"`) {
+		t.Errorf("BeginConditionalLogin() assertion = %s, want conditional mediation", string(assertionJSON))
+	}
+
+	parsedResponse := authenticator.getAssertion(t, testAssertion{
+		Challenge:  sessionData.Challenge,
+		Flags:      protocol.FlagUserPresent | protocol.FlagUserVerified,
+		UserHandle: userId,
+	})
+	session, err := webauthn.ConsumeSession(parsedResponse.Response.CollectedClientData.Challenge)
+	if err != nil {
+		t.Fatalf("ConsumeSession() error = %v", err)
+	}
+	handler := func(rawID, userHandle []byte) (User, error) {
+		return &defaultUser{id: userHandle}, nil
+	}
+	result, err := webauthn.ValidateDiscoverableLogin(handler, *session, parsedResponse)
+	if err != nil {
+		t.Fatalf("ValidateDiscoverableLogin() error = %v", err)
+	}
+	if !bytes.Equal(result.UserID, userId) {
+		t.Errorf("ValidateDiscoverableLogin() userId = %s, want %s", string(result.UserID), string(userId))
+	}
+}
+
+func TestLogin_ValidateLoginExtensions(t *testing.T) {
+	tests := []struct {
+		name                    string
+		requested               protocol.AuthenticationExtensions
+		clientExtensions        map[string]interface{}
+		authenticatorExtensions map[string]interface{}
+		wantErr                 bool
+	}{
+		{
+			name: "No extensions",
+		},
+		{
+			name:                    "Requested extension",
+			requested:               protocol.AuthenticationExtensions{"example": json.RawMessage(`true`)},
+			clientExtensions:        map[string]interface{}{"example": true},
+			authenticatorExtensions: map[string]interface{}{"example": true},
+		},
+		{
+			name:             "Client output not requested",
+			clientExtensions: map[string]interface{}{"example": true},
+			wantErr:          true,
+		},
+		{
+			name:                    "Authenticator output not requested",
+			requested:               protocol.AuthenticationExtensions{"other": json.RawMessage(`true`)},
+			authenticatorExtensions: map[string]interface{}{"example": true},
+			wantErr:                 true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t)
+			userId := []byte("user-1")
+			webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+
+			_, session, err := webauthn.BeginLogin(&defaultUser{id: userId}, WithAssertionExtensions(tt.requested))
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsedResponse := authenticator.getAssertion(t, testAssertion{
+				Challenge:               session.Challenge,
+				Flags:                   protocol.FlagUserPresent,
+				ClientExtensions:        tt.clientExtensions,
+				AuthenticatorExtensions: tt.authenticatorExtensions,
+			})
+
+			result, err := webauthn.ValidateLogin(*session, parsedResponse)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for identifier := range tt.clientExtensions {
+				if result.Extensions[identifier].Client == nil {
+					t.Errorf("ValidateLogin() client output of %s missing", identifier)
+				}
+			}
+			for identifier := range tt.authenticatorExtensions {
+				if result.Extensions[identifier].Authenticator == nil {
+					t.Errorf("ValidateLogin() authenticator output of %s missing", identifier)
+				}
+			}
+		})
+	}
+}
+
+func TestLogin_ValidateLoginAppID(t *testing.T) {
+	const appID = "https://webauthn.io/u2f/app-id.json"
+	tests := []struct {
+		name    string
+		options []LoginOption
+		rpId    string
+		appId   interface{}
+		wantErr bool
+	}{
+		{
+			name: "RP ID",
+		},
+		{
+			name:    "AppID used",
+			options: []LoginOption{WithAppID(appID)},
+			rpId:    appID,
+			appId:   true,
+		},
+		{
+			name:    "AppID requested but not used",
+			options: []LoginOption{WithAppID(appID)},
+			appId:   false,
+		},
+		{
+			name:    "AppID hash without appid output",
+			options: []LoginOption{WithAppID(appID)},
+			rpId:    appID,
+			wantErr: true,
+		},
+		{
+			name:    "AppID hash reported as unused",
+			options: []LoginOption{WithAppID(appID)},
+			rpId:    appID,
+			appId:   false,
+			wantErr: true,
+		},
+		{
+			name:    "AppID not requested",
+			rpId:    appID,
+			appId:   true,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t)
+			userId := []byte("user-1")
+			webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+
+			assertion, session, err := webauthn.BeginLogin(&defaultUser{id: userId}, tt.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tt.options) > 0 && string(assertion.Response.Extensions[protocol.ExtensionAppID]) != `"`+appID+`"` {
+				t.Errorf("BeginLogin() extensions = %v, want appid", assertion.Response.Extensions)
+			}
+			var clientExtensions map[string]interface{}
+			if tt.appId != nil {
+				clientExtensions = map[string]interface{}{protocol.ExtensionAppID: tt.appId}
+			}
+			parsedResponse := authenticator.getAssertion(t, testAssertion{
+				Challenge:        session.Challenge,
+				Flags:            protocol.FlagUserPresent,
+				ClientExtensions: clientExtensions,
+				RPID:             tt.rpId,
+			})
+
+			_, err = webauthn.ValidateLogin(*session, parsedResponse)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLogin_ValidateLoginLargeBlob(t *testing.T) {
+	tests := []struct {
+		name             string
+		option           LoginOption
+		clientExtensions map[string]interface{}
+		wantBlob         []byte
+		wantWritten      bool
+		wantErr          bool
+	}{
+		{
+			name:             "Read",
+			option:           WithLargeBlobRead(),
+			clientExtensions: map[string]interface{}{"largeBlob": map[string]interface{}{"blob": "AQID"}},
+			wantBlob:         []byte{1, 2, 3},
+		},
+		{
+			name:             "Write",
+			option:           WithLargeBlobWrite([]byte{1, 2, 3}),
+			clientExtensions: map[string]interface{}{"largeBlob": map[string]interface{}{"written": true}},
+			wantWritten:      true,
+		},
+		{
+			name:             "Blob returned without read",
+			option:           WithLargeBlobWrite([]byte{1, 2, 3}),
+			clientExtensions: map[string]interface{}{"largeBlob": map[string]interface{}{"blob": "AQID"}},
+			wantErr:          true,
+		},
+		{
+			name:             "Supported returned for login",
+			option:           WithLargeBlobRead(),
+			clientExtensions: map[string]interface{}{"largeBlob": map[string]interface{}{"supported": true}},
+			wantErr:          true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t)
+			userId := []byte("user-1")
+			webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+
+			_, session, err := webauthn.BeginLogin(&defaultUser{id: userId}, tt.option)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsedResponse := authenticator.getAssertion(t, testAssertion{
+				Challenge:        session.Challenge,
+				Flags:            protocol.FlagUserPresent,
+				ClientExtensions: tt.clientExtensions,
+			})
+			largeBlob, ok := parsedResponse.Extensions.LargeBlob()
+			if !ok {
+				t.Fatalf("LargeBlob() missing in %v", parsedResponse.Extensions)
+			}
+
+			result, err := webauthn.ValidateLogin(*session, parsedResponse)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			verified, ok := result.Extensions.LargeBlob()
+			if !ok || !reflect.DeepEqual(verified, largeBlob) {
+				t.Errorf("ValidateLogin() largeBlob = %+v, want %+v", verified, largeBlob)
+			}
+			if !bytes.Equal(largeBlob.Blob, tt.wantBlob) {
+				t.Errorf("LargeBlob() blob = %v, want %v", largeBlob.Blob, tt.wantBlob)
+			}
+			if (largeBlob.Written != nil && *largeBlob.Written) != tt.wantWritten {
+				t.Errorf("LargeBlob() written = %v, want %v", largeBlob.Written, tt.wantWritten)
+			}
+		})
+	}
+}
+
+func TestLogin_ValidateLoginPRF(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	userId := []byte("user-1")
+	webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+
+	eval := &protocol.PRFValues{First: []byte("salt-1")}
+	credentialSalt := &protocol.PRFValues{First: []byte("salt-2"), Second: []byte("salt-3")}
+	assertion, session, err := webauthn.BeginLogin(&defaultUser{id: userId}, WithPRF(eval, func(credentialId []byte) *protocol.PRFValues {
+		if bytes.Equal(credentialId, authenticator.CredentialID) {
+			return credentialSalt
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inputs protocol.PRFInputs
+	if err := json.Unmarshal(assertion.Response.Extensions[protocol.ExtensionPRF], &inputs); err != nil {
+		t.Fatalf("BeginLogin() extensions = %v, want prf", assertion.Response.Extensions)
+	}
+	want := protocol.PRFInputs{
+		Eval:             eval,
+		EvalByCredential: map[string]protocol.PRFValues{base64.RawURLEncoding.EncodeToString(authenticator.CredentialID): *credentialSalt},
+	}
+	if !reflect.DeepEqual(inputs, want) {
+		t.Errorf("BeginLogin() prf inputs = %+v, want %+v", inputs, want)
+	}
+
+	encryptedOutput := bytes.Repeat([]byte{0x42}, 64)
+	parsedResponse := authenticator.getAssertion(t, testAssertion{
+		Challenge: session.Challenge,
+		Flags:     protocol.FlagUserPresent,
+		ClientExtensions: map[string]interface{}{
+			"prf": map[string]interface{}{"results": map[string]interface{}{"first": "AQID", "second": "BAUG"}},
+		},
+		AuthenticatorExtensions: map[string]interface{}{"hmac-secret": encryptedOutput},
+	})
+
+	result, err := webauthn.ValidateLogin(*session, parsedResponse)
+	if err != nil {
+		t.Fatalf("ValidateLogin() error = %v", err)
+	}
+	prf, ok := result.Extensions.PRF()
+	if !ok || prf.Results == nil {
+		t.Fatalf("ValidateLogin() prf output = %+v, want results", prf)
+	}
+	if !bytes.Equal(prf.Results.First, []byte{1, 2, 3}) || !bytes.Equal(prf.Results.Second, []byte{4, 5, 6}) {
+		t.Errorf("ValidateLogin() prf results = %+v, want first 010203 and second 040506", prf.Results)
+	}
+	hmacSecret, ok := result.Extensions.HMACSecret()
+	if !ok || !bytes.Equal(hmacSecret.EncryptedOutput, encryptedOutput) {
+		t.Errorf("ValidateLogin() hmac-secret output = %+v, want %x", hmacSecret, encryptedOutput)
+	}
+}
+
+func TestLogin_ValidateLoginTransaction(t *testing.T) {
+	content := []byte{0x89, 'P', 'N', 'G'}
+	contentHash := sha256.Sum256(content)
+	simple := protocol.Transaction{Text: "Pay 100 EUR to Alice"}
+	generic := protocol.Transaction{Generic: &protocol.TxAuthGenericArg{ContentType: "image/png", Content: content}}
+	optional := func(tx protocol.Transaction) protocol.Transaction {
+		tx.OptionalConfirmation = true
+		return tx
+	}
+	tests := []struct {
+		name                    string
+		transaction             protocol.Transaction
+		clientExtensions        map[string]interface{}
+		authenticatorExtensions map[string]interface{}
+		wantErr                 bool
+	}{
+		{
+			name:                    "Simple transaction confirmed",
+			transaction:             simple,
+			clientExtensions:        map[string]interface{}{"txAuthSimple": "Pay 100 EUR to Alice"},
+			authenticatorExtensions: map[string]interface{}{"txAuthSimple": "Pay 100 EUR to Alice"},
+		},
+		{
+			name:                    "Simple transaction with line breaks",
+			transaction:             simple,
+			authenticatorExtensions: map[string]interface{}{"txAuthSimple": "Pay 100 EUR \nto Alice"},
+		},
+		{
+			name:                    "Different simple transaction confirmed",
+			transaction:             simple,
+			authenticatorExtensions: map[string]interface{}{"txAuthSimple": "Pay 900 EUR to Mallory"},
+			wantErr:                 true,
+		},
+		{
+			name:        "Optional confirmation missing",
+			transaction: optional(simple),
+		},
+		{
+			name:                    "Different optional confirmation",
+			transaction:             optional(simple),
+			authenticatorExtensions: map[string]interface{}{"txAuthSimple": "Pay 900 EUR to Mallory"},
+			wantErr:                 true,
+		},
+		{
+			name:        "Confirmation missing",
+			transaction: simple,
+			wantErr:     true,
+		},
+		{
+			name:             "Confirmation only by the client",
+			transaction:      simple,
+			clientExtensions: map[string]interface{}{"txAuthSimple": "Pay 100 EUR to Alice"},
+			wantErr:          true,
+		},
+		{
+			name:                    "Generic transaction confirmed",
+			transaction:             generic,
+			clientExtensions:        map[string]interface{}{"txAuthGeneric": true},
+			authenticatorExtensions: map[string]interface{}{"txAuthGeneric": contentHash[:]},
+		},
+		{
+			name:                    "Different generic transaction confirmed",
+			transaction:             generic,
+			authenticatorExtensions: map[string]interface{}{"txAuthGeneric": make([]byte, 32)},
+			wantErr:                 true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t)
+			userId := []byte("user-1")
+			webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+
+			_, session, err := webauthn.BeginTransactionLogin(&defaultUser{id: userId}, tt.transaction)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if session.Transaction == nil || session.Transaction.OptionalConfirmation != tt.transaction.OptionalConfirmation {
+				t.Fatalf("BeginTransactionLogin() sessionData.Transaction = %+v, want %+v", session.Transaction, tt.transaction)
+			}
+			parsedResponse := authenticator.getAssertion(t, testAssertion{
+				Challenge:               session.Challenge,
+				Flags:                   protocol.FlagUserPresent,
+				ClientExtensions:        tt.clientExtensions,
+				AuthenticatorExtensions: tt.authenticatorExtensions,
+			})
+
+			_, err = webauthn.ValidateLogin(*session, parsedResponse)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLogin_ValidateLoginWithTransaction(t *testing.T) {
+	tests := []struct {
+		name                    string
+		sessionTransaction      string
+		authenticatorExtensions map[string]interface{}
+		wantErr                 bool
+	}{
+		{
+			name:                    "Transaction confirmed",
+			authenticatorExtensions: map[string]interface{}{"txAuthSimple": "Pay 100 EUR to Alice"},
+		},
+		{
+			name:    "Confirmation missing",
+			wantErr: true,
+		},
+		{
+			name:                    "Session transaction does not match the challenge",
+			sessionTransaction:      "Pay 900 EUR to Mallory",
+			authenticatorExtensions: map[string]interface{}{"txAuthSimple": "Pay 900 EUR to Mallory"},
+			wantErr:                 true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t)
+			userId := []byte("user-1")
+			webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+
+			_, session, err := webauthn.BeginLogin(&defaultUser{id: userId}, WithTransaction("Pay 100 EUR to Alice"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.sessionTransaction != "" {
+				session.Transaction = &protocol.Transaction{Text: tt.sessionTransaction}
+			}
+			parsedResponse := authenticator.getAssertion(t, testAssertion{
+				Challenge:               session.Challenge,
+				Flags:                   protocol.FlagUserPresent,
+				AuthenticatorExtensions: tt.authenticatorExtensions,
+			})
+
+			_, err = webauthn.ValidateLogin(*session, parsedResponse)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLogin_BeginLoginTransactionChallenge(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	userId := []byte("user-1")
+	webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+
+	// The last transaction option replaces the earlier one, so the challenge only contains its hash
+	assertion, session, err := webauthn.BeginLogin(&defaultUser{id: userId}, WithTransaction("Pay 900 EUR to Mallory"), WithTransaction("Pay 100 EUR to Alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := base64.RawURLEncoding.DecodeString(session.Challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactionHash := sha256.Sum256([]byte("Pay 100 EUR to Alice"))
+	if len(challenge) != 64 || !bytes.HasSuffix(challenge, transactionHash[:]) {
+		t.Errorf("BeginLogin() challenge = %x, want random nonce and %x", challenge, transactionHash)
+	}
+	if !bytes.Equal(assertion.Response.Challenge, challenge) {
+		t.Errorf("BeginLogin() options challenge = %x, want %x", assertion.Response.Challenge, challenge)
+	}
+	if string(assertion.Response.Extensions[protocol.ExtensionTxAuthSimple]) != `"Pay 100 EUR to Alice"` || session.Transaction.Text != "Pay 100 EUR to Alice" {
+		t.Errorf("BeginLogin() extensions = %s, transaction = %+v, want the last transaction", assertion.Response.Extensions, session.Transaction)
+	}
+
+	// An empty transaction requests no transaction
+	assertion, session, err = webauthn.BeginLogin(&defaultUser{id: userId}, WithTransaction(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Transaction != nil || len(assertion.Response.Extensions) != 0 || len(assertion.Response.Challenge) != 32 {
+		t.Errorf("BeginLogin() with empty transaction = %+v, %+v, want no transaction", assertion.Response, session.Transaction)
+	}
+}
+
+func TestLogin_ValidatePaymentAssertion(t *testing.T) {
+	issued := protocol.CollectedClientAdditionalPaymentData{
+		PayeeName:   "Merchant",
+		PayeeOrigin: "https://merchant.example",
+		Total:       protocol.PaymentCurrencyAmount{Currency: "EUR", Value: "10.00"},
+		Instrument:  protocol.PaymentCredentialInstrument{DisplayName: "Card 1234", Icon: "https://webauthn.io/card.png"},
+	}
+	payment := map[string]interface{}{
+		"rpId":        testRPID,
+		"topOrigin":   "https://merchant.example",
+		"payeeName":   "Merchant",
+		"payeeOrigin": "https://merchant.example",
+		"total":       map[string]interface{}{"currency": "EUR", "value": "10.00"},
+		"instrument":  map[string]interface{}{"displayName": "Card 1234", "icon": "https://webauthn.io/card.png"},
+	}
+	withPayment := func(key string, value interface{}) map[string]interface{} {
+		changed := make(map[string]interface{})
+		for k, v := range payment {
+			changed[k] = v
+		}
+		changed[key] = value
+		return changed
+	}
+
+	tests := []struct {
+		name       string
+		clientData map[string]interface{}
+		wantErr    bool
+	}{
+		{
+			name:       "Payment confirmed on RP origin",
+			clientData: map[string]interface{}{"type": "payment.get", "payment": payment},
+		},
+		{
+			name:       "Payment confirmed on payee origin",
+			clientData: map[string]interface{}{"type": "payment.get", "origin": "https://merchant.example", "payment": payment},
+		},
+		{
+			name:       "Payment confirmed in iframe of payee origin",
+			clientData: map[string]interface{}{"type": "payment.get", "origin": "https://psp.example", "crossOrigin": true, "topOrigin": "https://merchant.example", "payment": payment},
+			wantErr:    true,
+		},
+		{
+			name:       "Payment confirmed in RP iframe on payee origin",
+			clientData: map[string]interface{}{"type": "payment.get", "crossOrigin": true, "topOrigin": "https://merchant.example", "payment": payment},
+		},
+		{
+			name:       "Other payee origin confirmed",
+			clientData: map[string]interface{}{"type": "payment.get", "payment": withPayment("payeeOrigin", "https://evil.example")},
+			wantErr:    true,
+		},
+		{
+			name:       "Other amount confirmed",
+			clientData: map[string]interface{}{"type": "payment.get", "payment": withPayment("total", map[string]interface{}{"currency": "EUR", "value": "1000.00"})},
+			wantErr:    true,
+		},
+		{
+			name:       "Unknown origin",
+			clientData: map[string]interface{}{"type": "payment.get", "origin": "https://evil.example", "payment": payment},
+			wantErr:    true,
+		},
+		{
+			name:       "Login assertion",
+			clientData: map[string]interface{}{"payment": payment},
+			wantErr:    true,
+		},
+		{
+			name:       "Payment data missing",
+			clientData: map[string]interface{}{"type": "payment.get"},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t)
+			userId := []byte("user-1")
+			webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+
+			_, session, err := webauthn.BeginPaymentConfirmation(&defaultUser{id: userId}, issued)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsedResponse := authenticator.getAssertion(t, testAssertion{
+				Challenge:  session.Challenge,
+				Flags:      protocol.FlagUserPresent,
+				ClientData: tt.clientData,
+			})
+
+			_, err = webauthn.ValidatePaymentAssertion(*session, parsedResponse)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePaymentAssertion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// A payment assertion is not accepted as login
+	authenticator := newTestAuthenticator(t)
+	webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, []byte("user-1"))
+	_, session, err := webauthn.BeginLogin(&defaultUser{id: []byte("user-1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedResponse := authenticator.getAssertion(t, testAssertion{
+		Challenge:  session.Challenge,
+		Flags:      protocol.FlagUserPresent,
+		ClientData: map[string]interface{}{"type": "payment.get", "payment": payment},
+	})
+	if _, err := webauthn.ValidateLogin(*session, parsedResponse); err == nil {
+		t.Error("ValidateLogin() for a payment assertion error = nil, want error")
+	}
+	// A login session has no issued payment
+	if _, err := webauthn.ValidatePaymentAssertion(*session, parsedResponse); err == nil {
+		t.Error("ValidatePaymentAssertion() without issued payment error = nil, want error")
+	}
+	// The payee origin is only allowed for payments
+	parsedResponse = authenticator.getAssertion(t, testAssertion{
+		Challenge:  session.Challenge,
+		Flags:      protocol.FlagUserPresent,
+		ClientData: map[string]interface{}{"origin": "https://merchant.example"},
+	})
+	if _, err := webauthn.ValidateLogin(*session, parsedResponse); !errors.Is(err, protocol.ErrOriginMismatch) {
+		t.Errorf("ValidateLogin() on payee origin error = %v, want %v", err, protocol.ErrOriginMismatch)
+	}
+}
+
+func TestLogin_BeginPaymentConfirmation(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	userId := []byte("user-1")
+	webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+	issued := protocol.CollectedClientAdditionalPaymentData{
+		PayeeOrigin: "https://merchant.example",
+		Total:       protocol.PaymentCurrencyAmount{Currency: "EUR", Value: "10.00"},
+		Instrument:  protocol.PaymentCredentialInstrument{DisplayName: "Card 1234", Icon: "https://webauthn.io/card.png"},
+	}
+
+	request, session, err := webauthn.BeginPaymentConfirmation(&defaultUser{id: userId}, issued, WithLoginTimeout(30000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.RPID != testRPID || request.PayeeOrigin != issued.PayeeOrigin || request.Instrument != issued.Instrument || request.Timeout != 30000 {
+		t.Errorf("BeginPaymentConfirmation() request = %+v, want payment of %+v", request, issued)
+	}
+	if len(request.CredentialIDs) != 1 || !bytes.Equal(request.CredentialIDs[0], authenticator.CredentialID) {
+		t.Errorf("BeginPaymentConfirmation() credentialIds = %v, want %x", request.CredentialIDs, authenticator.CredentialID)
+	}
+	if base64.RawURLEncoding.EncodeToString(request.Challenge) != session.Challenge {
+		t.Errorf("BeginPaymentConfirmation() challenge = %x, want session challenge %s", request.Challenge, session.Challenge)
+	}
+	issued.RPID = testRPID
+	if session.Payment == nil || !reflect.DeepEqual(*session.Payment, issued) {
+		t.Errorf("BeginPaymentConfirmation() sessionData.Payment = %+v, want %+v", session.Payment, issued)
+	}
+
+	invalid := issued
+	invalid.PayeeOrigin = "http://merchant.example"
+	if _, _, err := webauthn.BeginPaymentConfirmation(&defaultUser{id: userId}, invalid); err == nil {
+		t.Error("BeginPaymentConfirmation() with http payee origin error = nil, want error")
+	}
+	invalid.PayeeOrigin = ""
+	if _, _, err := webauthn.BeginPaymentConfirmation(&defaultUser{id: userId}, invalid); err == nil {
+		t.Error("BeginPaymentConfirmation() without payee error = nil, want error")
+	}
+}
+
+func TestLogin_ValidateLoginCrossOrigin(t *testing.T) {
+	tests := []struct {
+		name       string
+		topOrigins []string
+		clientData map[string]interface{}
+		wantErr    bool
+	}{
+		{
+			name:       "Same origin",
+			clientData: map[string]interface{}{"crossOrigin": false},
+		},
+		{
+			name:       "Allowed partner site",
+			topOrigins: []string{"https://partner.example"},
+			clientData: map[string]interface{}{"crossOrigin": true, "topOrigin": "https://partner.example"},
+		},
+		{
+			name:       "Unknown partner site",
+			topOrigins: []string{"https://partner.example"},
+			clientData: map[string]interface{}{"crossOrigin": true, "topOrigin": "https://evil.example"},
+			wantErr:    true,
+		},
+		{
+			name:       "Cross origin not configured",
+			clientData: map[string]interface{}{"crossOrigin": true, "topOrigin": "https://partner.example"},
+			wantErr:    true,
+		},
+		{
+			name:       "Allowed partner site embedding unknown origin",
+			topOrigins: []string{"https://partner.example"},
+			clientData: map[string]interface{}{"crossOrigin": true, "topOrigin": "https://partner.example", "origin": "https://evil.example"},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t)
+			userId := []byte("user-1")
+			webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+			webauthn.Config.RPTopOrigins = tt.topOrigins
+
+			_, session, err := webauthn.BeginLogin(&defaultUser{id: userId})
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsedResponse := authenticator.getAssertion(t, testAssertion{
+				Challenge:  session.Challenge,
+				Flags:      protocol.FlagUserPresent,
+				ClientData: tt.clientData,
+			})
+
+			_, err = webauthn.ValidateLogin(*session, parsedResponse)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLogin_ValidateLoginOrigins(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	userId := []byte("user-1")
+	configured, credentialService := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+
+	tests := []struct {
+		name     string
+		webauthn *WebAuthn
+		origins  []string
+		wantErr  bool
+	}{
+		{
+			name:     "Origins changed after New",
+			webauthn: configured,
+			origins:  []string{"https://login.webauthn.io", testOrigin},
+		},
+		{
+			name:     "Origin removed after New",
+			webauthn: configured,
+			origins:  []string{"https://login.webauthn.io"},
+			wantErr:  true,
+		},
+		{
+			name: "WebAuthn not created with New",
+			webauthn: &WebAuthn{
+				Config:            &Config{RPDisplayName: "WebAuthn.io", RPID: testRPID, RPOrigins: []string{testOrigin}},
+				CredentialService: credentialService,
+			},
+			origins: []string{testOrigin},
+		},
+		{
+			name: "No valid origins",
+			webauthn: &WebAuthn{
+				Config:            &Config{RPDisplayName: "WebAuthn.io", RPID: testRPID},
+				CredentialService: credentialService,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.webauthn.Config.RPOrigin = ""
+			tt.webauthn.Config.RPOrigins = tt.origins
+
+			_, session, err := tt.webauthn.BeginLogin(&defaultUser{id: userId})
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsedResponse := authenticator.getAssertion(t, testAssertion{
+				Challenge: session.Challenge,
+				Flags:     protocol.FlagUserPresent,
+			})
+
+			_, err = tt.webauthn.ValidateLogin(*session, parsedResponse)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLogin_ValidateLoginCounterPolicy(t *testing.T) {
+	tests := []struct {
+		name              string
+		policy            protocol.CounterPolicy
+		counter           uint32
+		wantErr           error
+		wantCloneWarning  bool
+		wantCounterStatus CounterStatus
+		wantQuarantined   bool
+	}{
+		{name: "Default policy", counter: 4, wantErr: protocol.ErrCounterError},
+		{name: "Flag", policy: protocol.FlagCounterPolicy{}, counter: 4, wantCloneWarning: true, wantCounterStatus: CounterFlagged},
+		{name: "Allow zero", policy: protocol.AllowZeroCounterPolicy{}, counter: ^uint32(0), wantCounterStatus: CounterAccepted},
+		{name: "Allow zero with lower counter", policy: protocol.AllowZeroCounterPolicy{}, counter: 4, wantErr: protocol.ErrCounterError},
+		{name: "Quarantine", policy: protocol.QuarantineCounterPolicy{}, counter: 4, wantErr: protocol.ErrCredentialQuarantined, wantQuarantined: true},
+		{name: "Increased counter", policy: protocol.QuarantineCounterPolicy{}, counter: 10, wantCounterStatus: CounterIncreased},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t)
+			authenticator.Counter = 10
+			userId := []byte("user-1")
+			webauthn, credentialService := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+			webauthn.CounterPolicy = tt.policy
+
+			_, session, err := webauthn.BeginLogin(&defaultUser{id: userId})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The authenticator increases the counter before it signs, the maximum value wraps around to zero
+			authenticator.Counter = tt.counter
+			parsedResponse := authenticator.getAssertion(t, testAssertion{
+				Challenge: session.Challenge,
+				Flags:     protocol.FlagUserPresent,
+			})
+
+			result, err := webauthn.ValidateLogin(*session, parsedResponse)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateLogin() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantQuarantined && !errors.Is(err, protocol.ErrCounterError) {
+				t.Errorf("ValidateLogin() error = %v, want cause %v", err, protocol.ErrCounterError)
+			}
+			if err == nil && result.CloneWarning != tt.wantCloneWarning {
+				t.Errorf("ValidateLogin() CloneWarning = %v, want %v", result.CloneWarning, tt.wantCloneWarning)
+			}
+			if err == nil && result.CounterStatus != tt.wantCounterStatus {
+				t.Errorf("ValidateLogin() CounterStatus = %s, want %s", result.CounterStatus, tt.wantCounterStatus)
+			}
+
+			stored, _, _ := credentialService.GetCredential(authenticator.CredentialID)
+			if stored.Quarantined != tt.wantQuarantined {
+				t.Errorf("stored credential Quarantined = %v, want %v", stored.Quarantined, tt.wantQuarantined)
+			}
+			if stored.Authenticator.SignCount < 10 {
+				t.Errorf("stored SignCount = %d, must not decrease", stored.Authenticator.SignCount)
+			}
+		})
+	}
+}
+
+func TestLogin_ValidateLoginQuarantinedCredential(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	userId := []byte("user-1")
+	webauthn, credentialService := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+	cred := authenticator.Credential(t)
+	cred.Quarantined = true
+	if err := credentialService.UpdateCredential(cred); err != nil {
+		t.Fatal(err)
+	}
+
+	_, session, err := webauthn.BeginLogin(&defaultUser{id: userId})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The quarantine is not revealed for a response which can not be verified
+	parsedResponse := authenticator.getAssertion(t, testAssertion{
+		Challenge: "AAAA",
+		Flags:     protocol.FlagUserPresent,
+	})
+	if _, err := webauthn.ValidateLogin(*session, parsedResponse); !errors.Is(err, protocol.ErrChallengeMismatch) {
+		t.Errorf("ValidateLogin() error = %v, want %v", err, protocol.ErrChallengeMismatch)
+	}
+
+	parsedResponse = authenticator.getAssertion(t, testAssertion{
+		Challenge: session.Challenge,
+		Flags:     protocol.FlagUserPresent,
+	})
+	if _, err := webauthn.ValidateLogin(*session, parsedResponse); !errors.Is(err, protocol.ErrCredentialQuarantined) {
+		t.Errorf("ValidateLogin() error = %v, want %v", err, protocol.ErrCredentialQuarantined)
+	}
+}
+
+func TestLogin_ValidateLoginResult(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	userId := []byte("user-1")
+	webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+
+	_, session, err := webauthn.BeginLogin(&defaultUser{id: userId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An authenticator without a counter always returns zero
+	authenticator.Counter = ^uint32(0)
+	parsedResponse := authenticator.getAssertion(t, testAssertion{
+		Challenge: session.Challenge,
+		Flags:     protocol.FlagUserPresent | protocol.FlagUserVerified,
+	})
+
+	result, err := webauthn.ValidateLogin(*session, parsedResponse)
+	if err != nil {
+		t.Fatalf("ValidateLogin() error = %v", err)
+	}
+	if !result.UserPresent || !result.UserVerified {
+		t.Errorf("ValidateLogin() UserPresent, UserVerified = %v, %v, want true, true", result.UserPresent, result.UserVerified)
+	}
+	if result.Origin != testOrigin || result.TopOrigin != "" {
+		t.Errorf("ValidateLogin() Origin, TopOrigin = %s, %s, want %s, empty", result.Origin, result.TopOrigin, testOrigin)
+	}
+	if result.CounterStatus != CounterUnsupported || result.SignCount != 0 || result.CloneWarning {
+		t.Errorf("ValidateLogin() CounterStatus, SignCount, CloneWarning = %s, %d, %v, want %s, 0, false", result.CounterStatus, result.SignCount, result.CloneWarning, CounterUnsupported)
 	}
 }
@@ -1,6 +1,8 @@
 package webauthn
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"github.com/teamhanko/webauthn-go/cbor_options"
 	"github.com/teamhanko/webauthn-go/credential"
@@ -8,6 +10,8 @@ import (
 	"github.com/teamhanko/webauthn-go/protocol"
 	"log"
 	"net/url"
+	"sync/atomic"
+	"time"
 )
 
 var defaultTimeout = 60000
@@ -18,6 +22,31 @@ type WebAuthn struct {
 	MetadataService   metadata.MetadataService
 	CredentialService credential.CredentialService
 	RpPolicy          protocol.RelyingPartyPolicy
+	// SessionStore is optional. If it is set, the sessions created by BeginRegistration and BeginLogin are saved
+	// to it and can be finished with FinishStoredRegistration and FinishStoredLogin. The stores of this package must
+	// be created with the same Clock as the instance, otherwise they may disagree about the expiry of a session.
+	// The sessions of a tenant are stored under a key derived from the tenant and the challenge, so a response sent
+	// to another tenant sharing the store can not consume them.
+	SessionStore SessionStore
+	// Clock is used to determine the creation and expiry time of sessions. If it is nil, the system clock is used.
+	Clock Clock
+	// CounterPolicy decides whether a login fails if the signature counter did not increase. If it is nil, such
+	// logins are rejected.
+	CounterPolicy protocol.CounterPolicy
+	// Tenant is the key of the tenant the instance was created for by a TenantRegistry. It is recorded in the
+	// sessions, which can then only be finished by an instance of the same tenant.
+	Tenant string
+}
+
+// Clock provides the current time. It can be replaced to make the session expiry deterministic, e.g. in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
 }
 
 type Timeouts struct {
@@ -31,13 +60,71 @@ type Config struct {
 	RPID          string
 	RPOrigin      string
 	RPOrigins     []string
-	RPIcon        string
+	// RPTopOrigins are the origins of the top-level pages which may embed the ceremonies in a cross-origin iframe.
+	// Cross-origin ceremonies are rejected if it is empty.
+	RPTopOrigins []string
+	// RPRelatedOrigins are origins on other domains than the RP ID, e.g. other ccTLDs, which may use the RP ID. They
+	// are accepted like the RPOrigins and published with the RelatedOriginsHandler.
+	RPRelatedOrigins []string
+	RPIcon           string
 	// Defaults for generating options
 	AttestationPreference  protocol.ConveyancePreference
 	AuthenticatorSelection protocol.AuthenticatorSelection
 
 	Timeouts
 	Debug bool
+
+	// parsedOrigins holds the *parsedOrigins of the origins, see Config.origins
+	parsedOrigins atomic.Value
+}
+
+// parsedOrigins are the origin rules parsed from the origins of a Config
+type parsedOrigins struct {
+	// source are the origins of the Config the rules were parsed from
+	source originSource
+	// origins, topOrigins and relatedOrigins are the canonical forms of the parsed origins
+	origins        []string
+	topOrigins     []string
+	relatedOrigins []string
+	// rules are the parsed RPOrigin, RPOrigins and RPRelatedOrigins, topRules the parsed RPTopOrigins
+	rules    protocol.OriginRules
+	topRules protocol.OriginRules
+}
+
+// originSource is a copy of the origins of a Config, to notice when they change
+type originSource struct {
+	origin         string
+	origins        []string
+	topOrigins     []string
+	relatedOrigins []string
+}
+
+func newOriginSource(config *Config) originSource {
+	return originSource{
+		origin:         config.RPOrigin,
+		origins:        append([]string(nil), config.RPOrigins...),
+		topOrigins:     append([]string(nil), config.RPTopOrigins...),
+		relatedOrigins: append([]string(nil), config.RPRelatedOrigins...),
+	}
+}
+
+func (source originSource) equal(config *Config) bool {
+	return source.origin == config.RPOrigin &&
+		equalStrings(source.origins, config.RPOrigins) &&
+		equalStrings(source.topOrigins, config.RPTopOrigins) &&
+		equalStrings(source.relatedOrigins, config.RPRelatedOrigins)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
 
 // Validate that the config flags in Config are properly set
@@ -67,27 +154,112 @@ func (config *Config) validate() error {
 		config.RPOrigins = append(config.RPOrigins, config.RPOrigin)
 	}
 
-	var validOrigins []string
-	for _, origin := range config.RPOrigins {
-		u, err := url.Parse(origin)
+	parsed, err := parseOrigins(config)
+	if err != nil {
+		return err
+	}
+	config.RPOrigins, config.RPTopOrigins = parsed.origins, parsed.topOrigins
+	if len(parsed.relatedOrigins) > 0 {
+		config.RPRelatedOrigins = parsed.relatedOrigins
+	}
+	parsed.source = newOriginSource(config)
+	config.parsedOrigins.Store(parsed)
+
+	return nil
+}
+
+// origins returns the parsed origin rules. They are parsed by New and parsed again if the exported origins were
+// changed afterwards or the Config was not passed to New, so the rules always match the origins of the Config.
+func (config *Config) origins() (*parsedOrigins, error) {
+	if parsed, ok := config.parsedOrigins.Load().(*parsedOrigins); ok && parsed.source.equal(config) {
+		return parsed, nil
+	}
+	parsed, err := parseOrigins(config)
+	if err != nil {
+		return nil, fmt.Errorf("Configuration error: %+v", err)
+	}
+	config.parsedOrigins.Store(parsed)
+	return parsed, nil
+}
+
+// parseOrigins parses the RPOrigin, RPOrigins, RPTopOrigins and RPRelatedOrigins of the config
+func parseOrigins(config *Config) (*parsedOrigins, error) {
+	parsed := &parsedOrigins{source: newOriginSource(config)}
+
+	origins := config.RPOrigins
+	if config.RPOrigin != "" && !containsString(origins, config.RPOrigin) {
+		origins = append(append([]string(nil), origins...), config.RPOrigin)
+	}
+	var err error
+	parsed.origins, parsed.rules, err = validOrigins(origins, config.RPID)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed.origins) == 0 {
+		return nil, fmt.Errorf("no valid origins found")
+	}
+
+	parsed.topOrigins, parsed.topRules, err = validOrigins(config.RPTopOrigins, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(config.RPRelatedOrigins) > 0 {
+		related, err := protocol.NewRelatedOrigins(config.RPRelatedOrigins)
 		if err != nil {
-			log.Println(fmt.Sprintf("Failed to parse Origin: %s, skip it", origin))
-			continue
+			return nil, err
 		}
-		if u.Scheme != "https" && u.Scheme != "http" {
-			// we need this case for android (origin is then something like: 'android:apk-key-hash:...')
-			validOrigins = append(validOrigins, origin)
-		} else {
-			validOrigins = append(validOrigins, protocol.FullyQualifiedOrigin(u))
+		parsed.relatedOrigins = related.Origins
+		relatedRules, err := protocol.ParseOriginRules(related.Origins)
+		if err != nil {
+			return nil, err
 		}
+		parsed.rules = append(parsed.rules, relatedRules...)
 	}
-	config.RPOrigins = validOrigins
 
-	if len(config.RPOrigins) == 0 {
-		return fmt.Errorf("no valid origins found")
+	return parsed, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
 	}
+	return false
+}
 
-	return nil
+// clone returns a copy of the config which does not share the slices of the origins, so that validate does not change
+// the original
+func (config *Config) clone() *Config {
+	clone := *config
+	clone.parsedOrigins = atomic.Value{}
+	clone.RPOrigins = append([]string(nil), config.RPOrigins...)
+	clone.RPTopOrigins = append([]string(nil), config.RPTopOrigins...)
+	clone.RPRelatedOrigins = append([]string(nil), config.RPRelatedOrigins...)
+	return &clone
+}
+
+// validOrigins parses the origin rules and returns them in their canonical form. Origins which can not be parsed are
+// logged and skipped. Wildcard origins must only allow subdomains of the RP ID, unless the RP ID is empty.
+func validOrigins(origins []string, relyingPartyID string) ([]string, protocol.OriginRules, error) {
+	var valid []string
+	var rules protocol.OriginRules
+	for _, origin := range origins {
+		rule, err := protocol.ParseOriginRule(origin)
+		if err != nil {
+			log.Printf("Failed to parse origin %s, skip it: %v", origin, err)
+			continue
+		}
+		if wildcard, ok := rule.(protocol.WildcardOrigin); ok && relyingPartyID != "" {
+			if err := wildcard.VerifyRelyingPartyID(relyingPartyID); err != nil {
+				return nil, nil, err
+			}
+		}
+		valid = append(valid, rule.String())
+		rules = append(rules, rule)
+	}
+	return valid, rules, nil
 }
 
 // Create a new WebAuthn object given the proper config flags
@@ -110,11 +282,12 @@ func New(config *Config, metadataService metadata.MetadataService, credentialSer
 		MetadataService:   metadataService,
 		CredentialService: credentialService,
 		RpPolicy:          rpPolicy,
+		Clock:             systemClock{},
 	}, nil
 }
 
 func validateRelyingPartyPolicyRequirements(rpPolicy protocol.RelyingPartyPolicy, metadataService metadata.MetadataService) error {
-	switch rpPolicy.(type) {
+	switch policy := rpPolicy.(type) {
 	case protocol.AllowAllPolicy:
 		return nil
 	case protocol.AllowlistPolicy:
@@ -125,7 +298,52 @@ func validateRelyingPartyPolicyRequirements(rpPolicy protocol.RelyingPartyPolicy
 		if metadataService == nil {
 			return fmt.Errorf("MetadataService must be provided for AllowOnlyAuthenticatorFromMetadataServicePolicy")
 		}
+	case protocol.DenyBackupEligiblePolicy:
+		return validateRelyingPartyPolicyRequirements(policy.Policy, metadataService)
+	case protocol.ExtensionRequirementsPolicy:
+		return validateRelyingPartyPolicyRequirements(policy.Policy, metadataService)
 	}
 
 	return nil
 }
+
+// ConsumeSession loads the session for the given challenge from the SessionStore and removes it, so that it can not
+// be used a second time.
+func (webauthn *WebAuthn) ConsumeSession(challenge string) (*SessionData, error) {
+	if webauthn.SessionStore == nil {
+		return nil, protocol.ErrNotImplemented.WithDetails("No SessionStore configured")
+	}
+	session, err := webauthn.SessionStore.ConsumeSession(webauthn.sessionKey(challenge))
+	if err != nil {
+		return nil, err
+	}
+	session.Challenge = challenge
+	return session, nil
+}
+
+// sessionKey returns the key of the session for the challenge in the SessionStore. The key of a tenant's session is
+// the base64url encoded hash of the tenant and the challenge, so that the sessions of tenants sharing a store are
+// separated and the key is still a valid challenge for the stores of this package.
+func (webauthn *WebAuthn) sessionKey(challenge string) string {
+	if webauthn.Tenant == "" {
+		return challenge
+	}
+	hash := sha256.Sum256([]byte(webauthn.Tenant + "\x00" + challenge))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func (webauthn *WebAuthn) now() time.Time {
+	if webauthn.Clock == nil {
+		return time.Now()
+	}
+	return webauthn.Clock.Now()
+}
+
+func (webauthn *WebAuthn) saveSession(session *SessionData) error {
+	if webauthn.SessionStore == nil {
+		return nil
+	}
+	stored := *session
+	stored.Challenge = webauthn.sessionKey(session.Challenge)
+	return webauthn.SessionStore.SaveSession(&stored)
+}
@@ -1,9 +1,12 @@
 package webauthn
 
 import (
+	"bytes"
 	"github.com/teamhanko/webauthn-go/metadata"
 	"github.com/teamhanko/webauthn-go/protocol"
+	"github.com/teamhanko/webauthn-go/webauthn/webauthntest"
 	"reflect"
+	"sync/atomic"
 	"testing"
 )
 
@@ -73,6 +76,30 @@ func TestValidateRpPolicy(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "DenyBackupEligiblePolicy Without Policy",
+			args: args{
+				rpPolicy:        protocol.DenyBackupEligiblePolicy{},
+				metadataService: nil,
+			},
+			wantErr: false,
+		},
+		{
+			name: "DenyBackupEligiblePolicy wrapping AllowlistPolicy Without MetadataService",
+			args: args{
+				rpPolicy:        protocol.DenyBackupEligiblePolicy{Policy: protocol.AllowlistPolicy{}},
+				metadataService: nil,
+			},
+			wantErr: true,
+		},
+		{
+			name: "ExtensionRequirementsPolicy wrapping AllowlistPolicy Without MetadataService",
+			args: args{
+				rpPolicy:        protocol.ExtensionRequirementsPolicy{Policy: protocol.AllowlistPolicy{}},
+				metadataService: nil,
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -227,6 +254,109 @@ func TestConfigValidation(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Success with RPTopOrigins",
+			inputConfig: &Config{
+				RPDisplayName: "Test Relying Party",
+				RPID:          "test.com",
+				RPOrigins:     []string{"https://test.com"},
+				RPTopOrigins:  []string{"https://partner.com:443/embed"},
+				Timeouts: Timeouts{
+					Registration:   1000,
+					Authentication: 1000,
+				},
+			},
+			wantConfig: &Config{
+				RPDisplayName: "Test Relying Party",
+				RPID:          "test.com",
+				RPOrigins:     []string{"https://test.com"},
+				RPTopOrigins:  []string{"https://partner.com"},
+				Timeouts: Timeouts{
+					Registration:   1000,
+					Authentication: 1000,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success with wildcard and Android origins",
+			inputConfig: &Config{
+				RPDisplayName: "Test Relying Party",
+				RPID:          "test.com",
+				RPOrigins:     []string{"https://*.test.com", "android:apk-key-hash:-sYXRdwJA3hvue3mKpYrOZ9zSPC7b4mbgzJmdZEDO5w="},
+				Timeouts: Timeouts{
+					Registration:   1000,
+					Authentication: 1000,
+				},
+			},
+			wantConfig: &Config{
+				RPDisplayName: "Test Relying Party",
+				RPID:          "test.com",
+				RPOrigins:     []string{"https://*.test.com", "android:apk-key-hash:-sYXRdwJA3hvue3mKpYrOZ9zSPC7b4mbgzJmdZEDO5w"},
+				Timeouts: Timeouts{
+					Registration:   1000,
+					Authentication: 1000,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid origins are skipped",
+			inputConfig: &Config{
+				RPDisplayName: "Test Relying Party",
+				RPID:          "test.com",
+				RPOrigins:     []string{"https://test.com", "android:apk-key-hash:AAAA", "https://[::1"},
+				Timeouts: Timeouts{
+					Registration:   1000,
+					Authentication: 1000,
+				},
+			},
+			wantConfig: &Config{
+				RPDisplayName: "Test Relying Party",
+				RPID:          "test.com",
+				RPOrigins:     []string{"https://test.com"},
+				Timeouts: Timeouts{
+					Registration:   1000,
+					Authentication: 1000,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Only invalid origins",
+			inputConfig: &Config{
+				RPDisplayName: "Test Relying Party",
+				RPID:          "test.com",
+				RPOrigins:     []string{"android:apk-key-hash:AAAA"},
+			},
+			wantConfig: nil,
+			wantErr:    true,
+		},
+		{
+			name: "Wildcard origin outside of RPID",
+			inputConfig: &Config{
+				RPDisplayName: "Test Relying Party",
+				RPID:          "test.com",
+				RPOrigins:     []string{"https://*.other.com"},
+				Timeouts: Timeouts{
+					Registration:   1000,
+					Authentication: 1000,
+				},
+			},
+			wantConfig: nil,
+			wantErr:    true,
+		},
+		{
+			name: "Wildcard related origin",
+			inputConfig: &Config{
+				RPDisplayName:    "Test Relying Party",
+				RPID:             "test.com",
+				RPOrigins:        []string{"https://test.com"},
+				RPRelatedOrigins: []string{"https://*.test.de"},
+			},
+			wantConfig: nil,
+			wantErr:    true,
+		},
 		{
 			name: "Empty Config",
 			inputConfig: &Config{
@@ -277,9 +407,38 @@ func TestConfigValidation(t *testing.T) {
 				return
 			}
 
+			// The parsed origin rules are checked by the login and registration tests
+			tt.inputConfig.parsedOrigins = atomic.Value{}
 			if !reflect.DeepEqual(tt.inputConfig, tt.wantConfig) {
 				t.Errorf("Config.validate() expected different values in Config got = %v, want = %v", tt.inputConfig, tt.wantConfig)
 			}
 		})
 	}
 }
+
+// testAuthenticator is a software authenticator with a P-256 key, which signs assertions for the RP webauthn.io and
+// parses them like a response sent by a client
+type testAuthenticator struct {
+	*webauthntest.Authenticator
+}
+
+// testAssertion describes the assertion a testAuthenticator should create
+type testAssertion = webauthntest.Assertion
+
+const (
+	testRPID   = "webauthn.io"
+	testOrigin = "https://webauthn.io"
+)
+
+func newTestAuthenticator(t *testing.T) *testAuthenticator {
+	return &testAuthenticator{webauthntest.NewAuthenticator(t, testRPID, testOrigin)}
+}
+
+// getAssertion creates a signed assertion and parses it like a response sent by a client
+func (a *testAuthenticator) getAssertion(t *testing.T, assertion testAssertion) *protocol.ParsedCredentialAssertionData {
+	parsedResponse, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(a.Get(t, assertion)))
+	if err != nil {
+		t.Fatalf("ParseCredentialRequestResponseBody() error = %v", err)
+	}
+	return parsedResponse
+}
@@ -2,6 +2,7 @@ package webauthn
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"github.com/teamhanko/webauthn-go/credential"
 	"net/http"
 
@@ -41,6 +42,7 @@ func (webauthn *WebAuthn) BeginRegistration(user User, opts ...RegistrationOptio
 
 	credentialParams := defaultRegistrationCredentialParameters()
 
+	// ResidentKey is left empty, so that options which only set RequireResidentKey are respected by SyncResidentKey
 	rrk := false
 	authSelection := protocol.AuthenticatorSelection{
 		RequireResidentKey: &rrk,
@@ -61,14 +63,26 @@ func (webauthn *WebAuthn) BeginRegistration(user User, opts ...RegistrationOptio
 		setter(&creationOptions)
 	}
 
+	creationOptions.AuthenticatorSelection.SyncResidentKey()
+	if creationOptions.AuthenticatorSelection.ResidentKey != protocol.ResidentKeyRequirementDiscouraged {
+		// The credProps extension tells us whether a discoverable credential was actually created
+		creationOptions.Extensions = withExtension(creationOptions.Extensions, protocol.ExtensionCredProps, true)
+	}
+	if creationOptions.Extensions, err = protocol.BuildExtensionInputs(protocol.CreateCeremony, creationOptions.Extensions); err != nil {
+		return nil, nil, err
+	}
+
 	response := protocol.CredentialCreation{Response: creationOptions}
-	newSessionData := SessionData{
-		Challenge:               base64.RawURLEncoding.EncodeToString(challenge),
-		UserID:                  user.WebAuthnID(),
-		UserVerification:        creationOptions.AuthenticatorSelection.UserVerification,
-		ConveyancePreference:    creationOptions.Attestation,
-		AuthenticatorAttachment: creationOptions.AuthenticatorSelection.AuthenticatorAttachment,
-		Timeout:                 creationOptions.Timeout,
+	newSessionData := newSessionData(base64.RawURLEncoding.EncodeToString(challenge), creationOptions.Timeout, webauthn.Tenant, webauthn.now())
+	newSessionData.UserID = user.WebAuthnID()
+	newSessionData.UserVerification = creationOptions.AuthenticatorSelection.UserVerification
+	newSessionData.ConveyancePreference = creationOptions.Attestation
+	newSessionData.AuthenticatorAttachment = creationOptions.AuthenticatorSelection.AuthenticatorAttachment
+	newSessionData.ResidentKey = creationOptions.AuthenticatorSelection.ResidentKey
+	newSessionData.Extensions = creationOptions.Extensions
+
+	if err := webauthn.saveSession(&newSessionData); err != nil {
+		return nil, nil, err
 	}
 
 	return &response, &newSessionData, nil
@@ -81,6 +95,16 @@ func WithAuthenticatorSelection(authenticatorSelection protocol.AuthenticatorSel
 	}
 }
 
+// WithResidentKeyRequirement sets whether the authenticator should create a client-side discoverable credential, which
+// can be used for a login without providing a username first.
+func WithResidentKeyRequirement(requirement protocol.ResidentKeyRequirement) RegistrationOption {
+	return func(cco *protocol.PublicKeyCredentialCreationOptions) {
+		cco.AuthenticatorSelection.ResidentKey = requirement
+		requireResidentKey := requirement == protocol.ResidentKeyRequirementRequired
+		cco.AuthenticatorSelection.RequireResidentKey = &requireResidentKey
+	}
+}
+
 // Provide non-default parameters regarding credentials to exclude from retrieval.
 func WithExclusions(excludeList []protocol.CredentialDescriptor) RegistrationOption {
 	return func(cco *protocol.PublicKeyCredentialCreationOptions) {
@@ -102,6 +126,51 @@ func WithExtensions(extension protocol.AuthenticationExtensions) RegistrationOpt
 	}
 }
 
+// WithAppIDExclude requests the appidExclude extension with the AppID of a FIDO U2F application, which prevents the
+// registration on authenticators that already contain a U2F credential of the user
+func WithAppIDExclude(appID string) RegistrationOption {
+	return func(cco *protocol.PublicKeyCredentialCreationOptions) {
+		cco.Extensions = withExtension(cco.Extensions, protocol.ExtensionAppIDExclude, appID)
+	}
+}
+
+// WithLargeBlobSupport requests the largeBlob extension, which makes the authenticator reserve storage for a large
+// blob of the new credential. Whether the credential supports large blobs is recorded in
+// credential.Credential.LargeBlobSupported.
+func WithLargeBlobSupport(support protocol.LargeBlobSupport) RegistrationOption {
+	return func(cco *protocol.PublicKeyCredentialCreationOptions) {
+		cco.Extensions = withExtension(cco.Extensions, protocol.ExtensionLargeBlob, protocol.LargeBlobInputs{Support: support})
+	}
+}
+
+// WithPRFSupport requests the prf extension, which enables the hmac-secret extension of the authenticator for the
+// new credential, so that the credential can derive secrets during later logins with WithPRF
+func WithPRFSupport() RegistrationOption {
+	return func(cco *protocol.PublicKeyCredentialCreationOptions) {
+		cco.Extensions = withExtension(cco.Extensions, protocol.ExtensionPRF, protocol.PRFInputs{})
+	}
+}
+
+// WithCredentialProtection requests the credProtect extension with the given protection level. If enforce is true,
+// the client fails the registration if the authenticator can not apply the level. The level returned by the
+// authenticator can be enforced with protocol.ExtensionRequirementsPolicy.
+func WithCredentialProtection(policy protocol.CredentialProtectionPolicy, enforce bool) RegistrationOption {
+	return func(cco *protocol.PublicKeyCredentialCreationOptions) {
+		cco.Extensions = withExtension(cco.Extensions, protocol.ExtensionCredentialProtectionPolicy, policy)
+		if enforce {
+			cco.Extensions = withExtension(cco.Extensions, protocol.ExtensionEnforceCredentialProtectionPolicy, true)
+		}
+	}
+}
+
+// WithMinPinLength requests the minPinLength extension, which returns the minimum PIN length of the authenticator.
+// The length can be enforced with protocol.ExtensionRequirementsPolicy.
+func WithMinPinLength() RegistrationOption {
+	return func(cco *protocol.PublicKeyCredentialCreationOptions) {
+		cco.Extensions = withExtension(cco.Extensions, protocol.ExtensionMinPinLength, true)
+	}
+}
+
 // WithRegistrationTimeout adds a custom timeout in milliseconds for the registration operation
 func WithRegistrationTimeout(timeout int) RegistrationOption {
 	return func(cco *protocol.PublicKeyCredentialCreationOptions) {
@@ -109,9 +178,30 @@ func WithRegistrationTimeout(timeout int) RegistrationOption {
 	}
 }
 
+// RegistrationResult contains the outcome of a successful registration ceremony
+type RegistrationResult struct {
+	// The new credential, its backup eligibility and state are available in Credential.BackupEligible and
+	// Credential.BackupState
+	Credential *credential.Credential
+	// The ID of the user the credential was registered for
+	UserID []byte
+	// Indicates if the authenticator tested the user presence
+	UserPresent bool
+	// Indicates if the authenticator verified the user, e.g. by a PIN or biometrics
+	UserVerified bool
+	// The verified outputs of the requested extensions
+	Extensions protocol.ExtensionOutputs
+	// The attestation format, type and trust path of the authenticator and its metadata statement, if one was found
+	Attestation protocol.AttestationResult
+	// The origin of the ceremony as reported by the client
+	Origin string
+	// The origin of the top-level document, only set if the ceremony was made from a cross-origin iframe
+	TopOrigin string
+}
+
 // Take the response from the authenticator and client and verify the credential against the user's credentials and
 // session data.
-func (webauthn *WebAuthn) FinishRegistration(session SessionData, response *http.Request) (*credential.Credential, error) {
+func (webauthn *WebAuthn) FinishRegistration(session SessionData, response *http.Request) (*RegistrationResult, error) {
 	parsedResponse, err := protocol.ParseCredentialCreationResponse(response)
 	if err != nil {
 		return nil, err
@@ -120,16 +210,85 @@ func (webauthn *WebAuthn) FinishRegistration(session SessionData, response *http
 	return webauthn.CreateCredential(session, parsedResponse)
 }
 
+// FinishStoredRegistration takes the response from the authenticator and client, consumes the matching session from
+// the SessionStore and verifies the credential against it. The result contains the new credential and the ID of the
+// user it was registered for. A response can only be finished once, a replayed response fails with
+// protocol.ErrSessionAlreadyUsed.
+func (webauthn *WebAuthn) FinishStoredRegistration(response *http.Request) (*RegistrationResult, error) {
+	parsedResponse, err := protocol.ParseCredentialCreationResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := webauthn.ConsumeSession(parsedResponse.Response.CollectedClientData.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	return webauthn.CreateCredential(*session, parsedResponse)
+}
+
 // CreateCredential verifies a parsed response against the user's credentials and session data.
-func (webauthn *WebAuthn) CreateCredential(session SessionData, parsedResponse *protocol.ParsedCredentialCreationData) (*credential.Credential, error) {
+func (webauthn *WebAuthn) CreateCredential(session SessionData, parsedResponse *protocol.ParsedCredentialCreationData) (*RegistrationResult, error) {
+	if err := session.verifyNotExpired(webauthn.now()); err != nil {
+		return nil, err
+	}
+	if err := session.verifyTenant(webauthn.Tenant); err != nil {
+		return nil, err
+	}
+
 	shouldVerifyUser := session.UserVerification == protocol.VerificationRequired
 
-	invalidErr := parsedResponse.Verify(session.Challenge, shouldVerifyUser, webauthn.Config.RPID, webauthn.Config.RPOrigins, webauthn.MetadataService, webauthn.CredentialService, webauthn.RpPolicy)
+	origins, err := webauthn.Config.origins()
+	if err != nil {
+		return nil, err
+	}
+	attestation, invalidErr := parsedResponse.Verify(session.Challenge, shouldVerifyUser, webauthn.Config.RPID, origins.rules, origins.topRules, webauthn.MetadataService, webauthn.CredentialService, webauthn.RpPolicy)
 	if invalidErr != nil {
 		return nil, invalidErr
 	}
 
-	return MakeNewCredential(parsedResponse)
+	// Step 12. Verify the client and authenticator extension outputs against the requested extensions
+	extensionOutputs, err := protocol.VerifyExtensions(protocol.CreateCeremony, session.Extensions, parsedResponse.Extensions, &parsedResponse.Response.AttestationObject.AuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := protocol.VerifyExtensionPolicy(webauthn.RpPolicy, parsedResponse, session.Extensions, extensionOutputs); err != nil {
+		return nil, err
+	}
+
+	newCredential, err := makeNewCredential(parsedResponse, webauthn.now())
+	if err != nil {
+		return nil, err
+	}
+
+	if session.ResidentKey == protocol.ResidentKeyRequirementRequired {
+		if credProps, ok := extensionOutputs.CredProps(); ok && credProps.ResidentKey != nil && !*credProps.ResidentKey {
+			return nil, protocol.ErrVerification.WithDetails("Resident key required but the credential is not discoverable")
+		}
+		// Clients must fail the ceremony if they can not create a required resident key
+		newCredential.Discoverable = true
+	}
+
+	// Step 18. Register the new credential with the account that was denoted in the options.user passed to create()
+	if webauthn.CredentialService != nil {
+		err = webauthn.CredentialService.StoreCredential(session.UserID, newCredential)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	flags := parsedResponse.Response.AttestationObject.AuthData.Flags
+	return &RegistrationResult{
+		Credential:   newCredential,
+		UserID:       session.UserID,
+		UserPresent:  flags.UserPresent(),
+		UserVerified: flags.UserVerified(),
+		Extensions:   extensionOutputs,
+		Attestation:  *attestation,
+		Origin:       parsedResponse.Response.CollectedClientData.Origin,
+		TopOrigin:    parsedResponse.Response.CollectedClientData.TopOrigin,
+	}, nil
 }
 
 func defaultRegistrationCredentialParameters() []protocol.CredentialParameter {
@@ -176,3 +335,18 @@ func defaultRegistrationCredentialParameters() []protocol.CredentialParameter {
 		},
 	}
 }
+
+// withExtension returns a copy of the extensions with the given extension input added, unless it is already present.
+// The input is checked by BuildExtensionInputs once all options are applied.
+func withExtension(extensions protocol.AuthenticationExtensions, identifier string, input interface{}) protocol.AuthenticationExtensions {
+	if _, ok := extensions[identifier]; ok {
+		return extensions
+	}
+	encoded, _ := json.Marshal(input)
+	result := make(protocol.AuthenticationExtensions, len(extensions)+1)
+	for key, value := range extensions {
+		result[key] = value
+	}
+	result[identifier] = encoded
+	return result
+}
@@ -4,7 +4,12 @@ import (
 	"testing"
 
 	"bytes"
+	"encoding/json"
+	"github.com/teamhanko/webauthn-go/credential"
 	"github.com/teamhanko/webauthn-go/protocol"
+	"reflect"
+	"strings"
+	"time"
 )
 
 func TestRegistration_FinishRegistrationFailure(t *testing.T) {
@@ -13,12 +18,12 @@ func TestRegistration_FinishRegistrationFailure(t *testing.T) {
 	}
 
 	webauthn := &WebAuthn{}
-	credential, err := webauthn.FinishRegistration(session, nil)
+	result, err := webauthn.FinishRegistration(session, nil)
 	if err == nil {
 		t.Errorf("FinishRegistration() error = nil, want %v", protocol.ErrBadRequest.Type)
 	}
-	if credential != nil {
-		t.Errorf("FinishRegistration() credential = %v, want nil", credential)
+	if result != nil {
+		t.Errorf("FinishRegistration() result = %v, want nil", result)
 	}
 }
 
@@ -27,14 +32,12 @@ func TestRegistration_BeginRegistrationDefaultOptions(t *testing.T) {
 		id: []byte("123"),
 	}
 
-	webauthn := WebAuthn{&Config{
-		RPID:          "http://localhost",
-		RPDisplayName: "Test Relying Party",
-		RPIcon:        "icon",
-	},
-		nil,
-		nil,
-		nil,
+	webauthn := WebAuthn{
+		Config: &Config{
+			RPID:          "http://localhost",
+			RPDisplayName: "Test Relying Party",
+			RPIcon:        "icon",
+		},
 	}
 	options, sessionData, err := webauthn.BeginRegistration(user)
 
@@ -80,14 +83,12 @@ func TestRegistration_BeginRegistrationAuthenticatorSelectionOption(t *testing.T
 		id: []byte("123"),
 	}
 
-	webauthn := WebAuthn{&Config{
-		RPID:          "http://localhost",
-		RPDisplayName: "Test Relying Party",
-		RPIcon:        "icon",
-	},
-		nil,
-		nil,
-		nil,
+	webauthn := WebAuthn{
+		Config: &Config{
+			RPID:          "http://localhost",
+			RPDisplayName: "Test Relying Party",
+			RPIcon:        "icon",
+		},
 	}
 
 	authenticatorSelection := protocol.AuthenticatorSelection{
@@ -124,14 +125,12 @@ func TestRegistration_BeginRegistrationConveyancePreferenceOption(t *testing.T)
 		id: []byte("123"),
 	}
 
-	webauthn := WebAuthn{&Config{
-		RPID:          "http://localhost",
-		RPDisplayName: "Test Relying Party",
-		RPIcon:        "icon",
-	},
-		nil,
-		nil,
-		nil,
+	webauthn := WebAuthn{
+		Config: &Config{
+			RPID:          "http://localhost",
+			RPDisplayName: "Test Relying Party",
+			RPIcon:        "icon",
+		},
 	}
 
 	options, _, err := webauthn.BeginRegistration(user, WithConveyancePreference(protocol.PreferDirectAttestation))
@@ -150,14 +149,12 @@ func TestRegistration_BeginRegistrationCredentialDescriptorOption(t *testing.T)
 		id: []byte("123"),
 	}
 
-	webauthn := WebAuthn{&Config{
-		RPID:          "http://localhost",
-		RPDisplayName: "Test Relying Party",
-		RPIcon:        "icon",
-	},
-		nil,
-		nil,
-		nil,
+	webauthn := WebAuthn{
+		Config: &Config{
+			RPID:          "http://localhost",
+			RPDisplayName: "Test Relying Party",
+			RPIcon:        "icon",
+		},
 	}
 
 	excludeList := make([]protocol.CredentialDescriptor, 2)
@@ -189,3 +186,439 @@ func TestRegistration_BeginRegistrationCredentialDescriptorOption(t *testing.T)
 		t.Errorf("BeginRegistration() options.Response.CredentialExcludeList[0].CredentialID = %s, want %s", string(options.Response.CredentialExcludeList[0].CredentialID), string(excludeList[0].CredentialID))
 	}
 }
+
+type testClock struct {
+	now time.Time
+}
+
+func (clock *testClock) Now() time.Time {
+	return clock.now
+}
+
+func TestRegistration_BeginRegistrationSessionExpiry(t *testing.T) {
+	clock := &testClock{now: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)}
+	webauthn := WebAuthn{
+		Config: &Config{
+			RPID:          "http://localhost",
+			RPDisplayName: "Test Relying Party",
+			Timeouts: Timeouts{
+				Registration: 30000,
+			},
+		},
+		Clock: clock,
+	}
+
+	_, sessionData, err := webauthn.BeginRegistration(&defaultUser{id: []byte("123")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !sessionData.CreatedAt.Equal(clock.now) {
+		t.Errorf("BeginRegistration() sessionData.CreatedAt = %s, want %s", sessionData.CreatedAt, clock.now)
+	}
+
+	wantExpires := clock.now.Add(30 * time.Second)
+	if !sessionData.Expires.Equal(wantExpires) {
+		t.Errorf("BeginRegistration() sessionData.Expires = %s, want %s", sessionData.Expires, wantExpires)
+	}
+}
+
+func TestRegistration_CreateCredentialExpiredSession(t *testing.T) {
+	clock := &testClock{now: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)}
+	webauthn := WebAuthn{
+		Config: &Config{
+			RPID:          "http://localhost",
+			RPDisplayName: "Test Relying Party",
+		},
+		Clock: clock,
+	}
+
+	_, sessionData, err := webauthn.BeginRegistration(&defaultUser{id: []byte("123")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.now = sessionData.Expires.Add(time.Millisecond)
+	credential, err := webauthn.CreateCredential(*sessionData, &protocol.ParsedCredentialCreationData{})
+	if e, ok := err.(*protocol.Error); !ok || e.Type != protocol.ErrSessionExpired.Type {
+		t.Errorf("CreateCredential() error = %v, want %v", err, protocol.ErrSessionExpired)
+	}
+	if credential != nil {
+		t.Errorf("CreateCredential() credential = %v, want nil", credential)
+	}
+}
+
+// None Attestation, see protocol/credential_test.go
+const testRegistrationResponse = `{
+	"id":"6xrtBhJQW6QU4tOaB4rrHaS2Ks0yDDL_q8jDC16DEjZ-VLVf4kCRkvl2xp2D71sTPYns-exsHQHTy3G-zJRK8g",
+	"rawId":"6xrtBhJQW6QU4tOaB4rrHaS2Ks0yDDL_q8jDC16DEjZ-VLVf4kCRkvl2xp2D71sTPYns-exsHQHTy3G-zJRK8g",
+	"type":"public-key",
+	"authenticatorAttachment":"cross-platform",
+	"response":{
+		"transports":["usb","nfc"],
+		"attestationObject":"o2NmbXRkbm9uZWdhdHRTdG10oGhhdXRoRGF0YVjEdKbqkhPJnC90siSSsyDPQCYqlMGpUKA5fyklC2CEHvBBAAAAAAAAAAAAAAAAAAAAAAAAAAAAQOsa7QYSUFukFOLTmgeK6x2ktirNMgwy_6vIwwtegxI2flS1X-JAkZL5dsadg-9bEz2J7PnsbB0B08txvsyUSvKlAQIDJiABIVggLKF5xS0_BntttUIrm2Z2tgZ4uQDwllbdIfrrBMABCNciWCDHwin8Zdkr56iSIh0MrB5qZiEzYLQpEOREhMUkY6q4Vw",
+		"clientDataJSON":"eyJjaGFsbGVuZ2UiOiJXOEd6RlU4cEdqaG9SYldyTERsYW1BZnFfeTRTMUNaRzFWdW9lUkxBUnJFIiwib3JpZ2luIjoiaHR0cHM6Ly93ZWJhdXRobi5pbyIsInR5cGUiOiJ3ZWJhdXRobi5jcmVhdGUifQ"
+		}
+	}`
+
+func newTestRegistrationWebAuthn(t *testing.T) (*WebAuthn, *credential.InMemoryCredentialService) {
+	credentialService := credential.NewInMemoryCredentialService()
+	webauthn, err := New(&Config{
+		RPDisplayName: "WebAuthn.io",
+		RPID:          "webauthn.io",
+		RPOrigin:      "https://webauthn.io",
+	}, nil, credentialService, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return webauthn, credentialService
+}
+
+func TestRegistration_CreateCredentialStoresCredential(t *testing.T) {
+	webauthn, credentialService := newTestRegistrationWebAuthn(t)
+
+	parsedResponse, err := protocol.ParseCredentialCreationResponseBody(strings.NewReader(testRegistrationResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := SessionData{
+		Challenge: "W8GzFU8pGjhoRbWrLDlamAfq_y4S1CZG1VuoeRLARrE",
+		UserID:    []byte("123"),
+	}
+	result, err := webauthn.CreateCredential(session, parsedResponse)
+	if err != nil {
+		t.Fatalf("CreateCredential() error = %v", err)
+	}
+
+	stored, userId, err := credentialService.GetCredential(result.Credential.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("CredentialService.GetCredential() = %v, %v, want stored credential", stored, err)
+	}
+	if !bytes.Equal(userId, session.UserID) {
+		t.Errorf("stored credential userId = %s, want %s", string(userId), string(session.UserID))
+	}
+
+	// The same credential can not be registered twice
+	_, err = webauthn.CreateCredential(session, parsedResponse)
+	if err != protocol.ErrCredentialAlreadyExists {
+		t.Errorf("CreateCredential() second call error = %v, want %v", err, protocol.ErrCredentialAlreadyExists)
+	}
+}
+
+func TestRegistration_CreateCredentialRecordsDetails(t *testing.T) {
+	webauthn, _ := newTestRegistrationWebAuthn(t)
+	clock := &testClock{now: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)}
+	webauthn.Clock = clock
+
+	parsedResponse, err := protocol.ParseCredentialCreationResponseBody(strings.NewReader(testRegistrationResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := SessionData{
+		Challenge: "W8GzFU8pGjhoRbWrLDlamAfq_y4S1CZG1VuoeRLARrE",
+		UserID:    []byte("123"),
+	}
+	result, err := webauthn.CreateCredential(session, parsedResponse)
+	if err != nil {
+		t.Fatalf("CreateCredential() error = %v", err)
+	}
+	cred := result.Credential
+
+	if !reflect.DeepEqual(cred.Transports, []string{"usb", "nfc"}) {
+		t.Errorf("credential.Transports = %v, want %v", cred.Transports, []string{"usb", "nfc"})
+	}
+	if cred.AuthenticatorAttachment != "cross-platform" {
+		t.Errorf("credential.AuthenticatorAttachment = %s, want cross-platform", cred.AuthenticatorAttachment)
+	}
+	if cred.BackupEligible == nil || *cred.BackupEligible || cred.BackupState {
+		t.Errorf("credential.BackupEligible, BackupState = %v, %v, want false, false", cred.BackupEligible, cred.BackupState)
+	}
+	if !cred.CreatedAt.Equal(clock.now) {
+		t.Errorf("credential.CreatedAt = %s, want %s", cred.CreatedAt, clock.now)
+	}
+	if !bytes.Equal(cred.AttestationObject, parsedResponse.Raw.AttestationResponse.AttestationObject) {
+		t.Errorf("credential.AttestationObject does not match the attestation object of the response")
+	}
+
+	if !bytes.Equal(result.UserID, session.UserID) {
+		t.Errorf("result.UserID = %s, want %s", string(result.UserID), string(session.UserID))
+	}
+	if !result.UserPresent || result.UserVerified {
+		t.Errorf("result.UserPresent, UserVerified = %v, %v, want true, false", result.UserPresent, result.UserVerified)
+	}
+	if result.Origin != testOrigin || result.TopOrigin != "" {
+		t.Errorf("result.Origin, TopOrigin = %s, %s, want %s, empty", result.Origin, result.TopOrigin, testOrigin)
+	}
+	if result.Attestation.Format != "none" || len(result.Attestation.TrustPath) != 0 || result.Attestation.MetadataStatement != nil {
+		t.Errorf("result.Attestation = %+v, want none attestation without trust path", result.Attestation)
+	}
+}
+
+func TestRegistration_BeginRegistrationResidentKeyOption(t *testing.T) {
+	webauthn, _ := newTestRegistrationWebAuthn(t)
+
+	options, sessionData, err := webauthn.BeginRegistration(&defaultUser{id: []byte("123")}, WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	selection := options.Response.AuthenticatorSelection
+	if selection.ResidentKey != protocol.ResidentKeyRequirementRequired {
+		t.Errorf("BeginRegistration() options.Response.AuthenticatorSelection.ResidentKey = %s, want %s", selection.ResidentKey, protocol.ResidentKeyRequirementRequired)
+	}
+	if selection.RequireResidentKey == nil || !*selection.RequireResidentKey {
+		t.Errorf("BeginRegistration() options.Response.AuthenticatorSelection.RequireResidentKey = %v, want true", selection.RequireResidentKey)
+	}
+	if string(options.Response.Extensions[protocol.ExtensionCredProps]) != "true" {
+		t.Errorf("BeginRegistration() options.Response.Extensions = %v, want credProps", options.Response.Extensions)
+	}
+	if sessionData.ResidentKey != protocol.ResidentKeyRequirementRequired {
+		t.Errorf("BeginRegistration() sessionData.ResidentKey = %s, want %s", sessionData.ResidentKey, protocol.ResidentKeyRequirementRequired)
+	}
+
+	options, sessionData, err = webauthn.BeginRegistration(&defaultUser{id: []byte("123")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.Response.AuthenticatorSelection.ResidentKey != protocol.ResidentKeyRequirementDiscouraged {
+		t.Errorf("BeginRegistration() default ResidentKey = %s, want %s", options.Response.AuthenticatorSelection.ResidentKey, protocol.ResidentKeyRequirementDiscouraged)
+	}
+	if _, ok := options.Response.Extensions[protocol.ExtensionCredProps]; ok {
+		t.Errorf("BeginRegistration() default options.Response.Extensions = %v, want no credProps", options.Response.Extensions)
+	}
+	if sessionData.ResidentKey != protocol.ResidentKeyRequirementDiscouraged {
+		t.Errorf("BeginRegistration() default sessionData.ResidentKey = %s, want %s", sessionData.ResidentKey, protocol.ResidentKeyRequirementDiscouraged)
+	}
+
+	// Options written before ResidentKey existed only set RequireResidentKey
+	requireResidentKey := func(cco *protocol.PublicKeyCredentialCreationOptions) {
+		rrk := true
+		cco.AuthenticatorSelection.RequireResidentKey = &rrk
+	}
+	options, sessionData, err = webauthn.BeginRegistration(&defaultUser{id: []byte("123")}, requireResidentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selection = options.Response.AuthenticatorSelection
+	if selection.ResidentKey != protocol.ResidentKeyRequirementRequired || selection.RequireResidentKey == nil || !*selection.RequireResidentKey {
+		t.Errorf("BeginRegistration() with RequireResidentKey option ResidentKey, RequireResidentKey = %s, %v, want %s, true", selection.ResidentKey, selection.RequireResidentKey, protocol.ResidentKeyRequirementRequired)
+	}
+	if sessionData.ResidentKey != protocol.ResidentKeyRequirementRequired {
+		t.Errorf("BeginRegistration() with RequireResidentKey option sessionData.ResidentKey = %s, want %s", sessionData.ResidentKey, protocol.ResidentKeyRequirementRequired)
+	}
+}
+
+func TestRegistration_CreateCredentialDiscoverable(t *testing.T) {
+	tests := []struct {
+		name             string
+		residentKey      protocol.ResidentKeyRequirement
+		extensions       string
+		wantDiscoverable bool
+		wantErr          bool
+	}{
+		{
+			name:        "No credProps",
+			residentKey: protocol.ResidentKeyRequirementDiscouraged,
+			extensions:  `{}`,
+		},
+		{
+			name:             "Discoverable credential",
+			residentKey:      protocol.ResidentKeyRequirementPreferred,
+			extensions:       `{"credProps":{"rk":true}}`,
+			wantDiscoverable: true,
+		},
+		{
+			name:        "Server-side credential",
+			residentKey: protocol.ResidentKeyRequirementPreferred,
+			extensions:  `{"credProps":{"rk":false}}`,
+		},
+		{
+			name:             "Required without credProps",
+			residentKey:      protocol.ResidentKeyRequirementRequired,
+			extensions:       `{}`,
+			wantDiscoverable: true,
+		},
+		{
+			name:        "Required but server-side credential",
+			residentKey: protocol.ResidentKeyRequirementRequired,
+			extensions:  `{"credProps":{"rk":false}}`,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webauthn, _ := newTestRegistrationWebAuthn(t)
+
+			response := strings.Replace(testRegistrationResponse, `"type":"public-key",`, `"type":"public-key","extensions":`+tt.extensions+`,`, 1)
+			parsedResponse, err := protocol.ParseCredentialCreationResponseBody(strings.NewReader(response))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			session := SessionData{
+				Challenge:   "W8GzFU8pGjhoRbWrLDlamAfq_y4S1CZG1VuoeRLARrE",
+				UserID:      []byte("123"),
+				ResidentKey: tt.residentKey,
+				Extensions:  protocol.AuthenticationExtensions{protocol.ExtensionCredProps: json.RawMessage(`true`)},
+			}
+			result, err := webauthn.CreateCredential(session, parsedResponse)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateCredential() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && result.Credential.Discoverable != tt.wantDiscoverable {
+				t.Errorf("CreateCredential() credential.Discoverable = %v, want %v", result.Credential.Discoverable, tt.wantDiscoverable)
+			}
+		})
+	}
+}
+
+func TestRegistration_BeginRegistrationAppIDExcludeOption(t *testing.T) {
+	webauthn, _ := newTestRegistrationWebAuthn(t)
+	const appID = "https://webauthn.io/u2f/app-id.json"
+
+	options, sessionData, err := webauthn.BeginRegistration(&defaultUser{id: []byte("123")}, WithAppIDExclude(appID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(options.Response.Extensions[protocol.ExtensionAppIDExclude]) != `"`+appID+`"` {
+		t.Errorf("BeginRegistration() options.Response.Extensions = %v, want appidExclude", options.Response.Extensions)
+	}
+	if string(sessionData.Extensions[protocol.ExtensionAppIDExclude]) != `"`+appID+`"` {
+		t.Errorf("BeginRegistration() sessionData.Extensions = %v, want appidExclude", sessionData.Extensions)
+	}
+}
+
+func TestRegistration_CreateCredentialLargeBlob(t *testing.T) {
+	tests := []struct {
+		name          string
+		support       protocol.LargeBlobSupport
+		extensions    string
+		wantSupported bool
+		wantErr       bool
+	}{
+		{
+			name:       "No output",
+			support:    protocol.LargeBlobSupportPreferred,
+			extensions: `{}`,
+		},
+		{
+			name:          "Supported",
+			support:       protocol.LargeBlobSupportPreferred,
+			extensions:    `{"largeBlob":{"supported":true}}`,
+			wantSupported: true,
+		},
+		{
+			name:       "Not supported",
+			support:    protocol.LargeBlobSupportPreferred,
+			extensions: `{"largeBlob":{"supported":false}}`,
+		},
+		{
+			name:       "Required but not supported",
+			support:    protocol.LargeBlobSupportRequired,
+			extensions: `{"largeBlob":{"supported":false}}`,
+			wantErr:    true,
+		},
+		{
+			name:       "Blob returned for registration",
+			support:    protocol.LargeBlobSupportPreferred,
+			extensions: `{"largeBlob":{"supported":true,"blob":"AQID"}}`,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webauthn, _ := newTestRegistrationWebAuthn(t)
+
+			options, _, err := webauthn.BeginRegistration(&defaultUser{id: []byte("123")}, WithLargeBlobSupport(tt.support))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var input protocol.LargeBlobInputs
+			if err := json.Unmarshal(options.Response.Extensions[protocol.ExtensionLargeBlob], &input); err != nil || input.Support != tt.support {
+				t.Errorf("BeginRegistration() options.Response.Extensions = %v, want largeBlob support %s", options.Response.Extensions, tt.support)
+			}
+
+			response := strings.Replace(testRegistrationResponse, `"type":"public-key",`, `"type":"public-key","extensions":`+tt.extensions+`,`, 1)
+			parsedResponse, err := protocol.ParseCredentialCreationResponseBody(strings.NewReader(response))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			session := SessionData{
+				Challenge:  "W8GzFU8pGjhoRbWrLDlamAfq_y4S1CZG1VuoeRLARrE",
+				UserID:     []byte("123"),
+				Extensions: options.Response.Extensions,
+			}
+			result, err := webauthn.CreateCredential(session, parsedResponse)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateCredential() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && result.Credential.LargeBlobSupported != tt.wantSupported {
+				t.Errorf("CreateCredential() credential.LargeBlobSupported = %v, want %v", result.Credential.LargeBlobSupported, tt.wantSupported)
+			}
+		})
+	}
+}
+
+func TestRegistration_BeginRegistrationCredentialProtectionOptions(t *testing.T) {
+	webauthn, _ := newTestRegistrationWebAuthn(t)
+
+	options, _, err := webauthn.BeginRegistration(&defaultUser{id: []byte("123")},
+		WithCredentialProtection(protocol.CredentialProtectionUserVerificationRequired, true), WithMinPinLength())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := protocol.AuthenticationExtensions{
+		protocol.ExtensionCredentialProtectionPolicy:        json.RawMessage(`"userVerificationRequired"`),
+		protocol.ExtensionEnforceCredentialProtectionPolicy: json.RawMessage(`true`),
+		protocol.ExtensionMinPinLength:                      json.RawMessage(`true`),
+	}
+	if !reflect.DeepEqual(options.Response.Extensions, want) {
+		t.Errorf("BeginRegistration() options.Response.Extensions = %v, want %v", options.Response.Extensions, want)
+	}
+}
+
+func TestRegistration_CreateCredentialExtensionPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		rpPolicy protocol.RelyingPartyPolicy
+		wantErr  bool
+	}{
+		{
+			name:     "Policy without extension checks",
+			rpPolicy: protocol.AllowAllPolicy{},
+		},
+		{
+			name:     "credProtect level missing",
+			rpPolicy: protocol.ExtensionRequirementsPolicy{Policy: protocol.AllowAllPolicy{}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webauthn, _ := newTestRegistrationWebAuthn(t)
+			webauthn.RpPolicy = tt.rpPolicy
+
+			parsedResponse, err := protocol.ParseCredentialCreationResponseBody(strings.NewReader(testRegistrationResponse))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			session := SessionData{
+				Challenge:  "W8GzFU8pGjhoRbWrLDlamAfq_y4S1CZG1VuoeRLARrE",
+				UserID:     []byte("123"),
+				Extensions: protocol.AuthenticationExtensions{protocol.ExtensionCredentialProtectionPolicy: json.RawMessage(`"userVerificationRequired"`)},
+			}
+			_, err = webauthn.CreateCredential(session, parsedResponse)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CreateCredential() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
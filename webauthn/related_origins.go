@@ -0,0 +1,40 @@
+package webauthn
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/teamhanko/webauthn-go/protocol"
+)
+
+// RelatedOrigins returns the related origins document of the RP ID, which lists the RPRelatedOrigins of the Config
+func (webauthn *WebAuthn) RelatedOrigins() *protocol.RelatedOrigins {
+	origins := make([]string, len(webauthn.Config.RPRelatedOrigins))
+	copy(origins, webauthn.Config.RPRelatedOrigins)
+	return &protocol.RelatedOrigins{Origins: origins}
+}
+
+// RelatedOriginsHandler serves the related origins document. It must be reachable at
+// https://<rpid>/.well-known/webauthn, e.g. by registering it for protocol.WellKnownWebAuthnPath:
+//
+//	mux.Handle(protocol.WellKnownWebAuthnPath, webauthn.RelatedOriginsHandler())
+func (webauthn *WebAuthn) RelatedOriginsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := json.Marshal(webauthn.RelatedOrigins())
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(body)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package webauthn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/teamhanko/webauthn-go/credential"
+	"github.com/teamhanko/webauthn-go/protocol"
+)
+
+func TestRelatedOriginsHandler(t *testing.T) {
+	webauthn, err := New(&Config{
+		RPDisplayName:    "Example",
+		RPID:             "example.com",
+		RPOrigin:         "https://example.com",
+		RPRelatedOrigins: []string{"https://example.de", "https://example.co.uk:443"},
+	}, nil, credential.NewInMemoryCredentialService(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := webauthn.RelatedOriginsHandler()
+
+	tests := []struct {
+		method     string
+		wantStatus int
+		wantBody   string
+	}{
+		{method: http.MethodGet, wantStatus: http.StatusOK, wantBody: `{"origins":["https://example.de","https://example.co.uk"]}`},
+		{method: http.MethodHead, wantStatus: http.StatusOK},
+		{method: http.MethodPost, wantStatus: http.StatusMethodNotAllowed, wantBody: "Method Not Allowed\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(tt.method, protocol.WellKnownWebAuthnPath, nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if recorder.Body.String() != tt.wantBody {
+				t.Errorf("body = %s, want %s", recorder.Body.String(), tt.wantBody)
+			}
+			if tt.wantStatus == http.StatusOK && recorder.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("Content-Type = %s, want application/json", recorder.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestLogin_ValidateLoginRelatedOrigin(t *testing.T) {
+	tests := []struct {
+		name           string
+		relatedOrigins []string
+		wantErr        bool
+	}{
+		{
+			name:           "Related origin",
+			relatedOrigins: []string{"https://webauthn.de"},
+		},
+		{
+			name:    "No related origins",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t)
+			userId := []byte("user-1")
+			webauthn, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+			webauthn.Config.RPRelatedOrigins = tt.relatedOrigins
+
+			_, session, err := webauthn.BeginLogin(&defaultUser{id: userId})
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsedResponse := authenticator.getAssertion(t, testAssertion{
+				Challenge:  session.Challenge,
+				Flags:      protocol.FlagUserPresent,
+				ClientData: map[string]interface{}{"origin": "https://webauthn.de"},
+			})
+
+			_, err = webauthn.ValidateLogin(*session, parsedResponse)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,6 +1,11 @@
 package webauthn
 
-import "github.com/teamhanko/webauthn-go/protocol"
+import (
+	"fmt"
+	"time"
+
+	"github.com/teamhanko/webauthn-go/protocol"
+)
 
 // SessionData is the data that should be stored by the Relying Party for
 // the duration of the web authentication ceremony
@@ -12,4 +17,56 @@ type SessionData struct {
 	ConveyancePreference    protocol.ConveyancePreference        `json:"conveyance_preference"`
 	AuthenticatorAttachment protocol.AuthenticatorAttachment     `json:"authenticator_attachment"`
 	Timeout                 int                                  `json:"timeout"`
+	// ResidentKey is the requirement for a client-side discoverable credential of a registration
+	ResidentKey protocol.ResidentKeyRequirement `json:"resident_key,omitempty"`
+	// Extensions are the extension inputs of the request, outputs of other extensions are rejected
+	Extensions protocol.AuthenticationExtensions `json:"extensions,omitempty"`
+	// Transaction is the transaction which has to be confirmed by the authenticator during the login
+	Transaction *protocol.Transaction `json:"transaction,omitempty"`
+	// Payment is the payment issued by BeginPaymentConfirmation, which the user has to confirm
+	Payment *protocol.CollectedClientAdditionalPaymentData `json:"payment,omitempty"`
+	// Tenant is the key of the tenant the ceremony was started for, the response can only be finished by the
+	// WebAuthn instance of the same tenant
+	Tenant string `json:"tenant,omitempty"`
+	// CreatedAt is the time the ceremony was started
+	CreatedAt time.Time `json:"created_at"`
+	// Expires is the time after which the ceremony can not be finished anymore
+	Expires time.Time `json:"expires"`
+}
+
+// newSessionData creates the SessionData for a ceremony of the tenant with the given challenge that is started now.
+// The session expires after the given timeout in milliseconds.
+func newSessionData(challenge string, timeout int, tenant string, now time.Time) SessionData {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return SessionData{
+		Challenge: challenge,
+		Timeout:   timeout,
+		Tenant:    tenant,
+		CreatedAt: now,
+		Expires:   now.Add(time.Duration(timeout) * time.Millisecond),
+	}
+}
+
+// IsExpired returns whether the session is expired at the given time. Sessions without an expiry never expire.
+func (session *SessionData) IsExpired(now time.Time) bool {
+	return !session.Expires.IsZero() && now.After(session.Expires)
+}
+
+// verifyNotExpired returns protocol.ErrSessionExpired if the session is expired at the given time.
+func (session *SessionData) verifyNotExpired(now time.Time) error {
+	if session.IsExpired(now) {
+		return protocol.ErrSessionExpired.WithInfo(fmt.Sprintf("Session expired at %s, now is %s", session.Expires.Format(time.RFC3339Nano), now.Format(time.RFC3339Nano)))
+	}
+	return nil
+}
+
+// verifyTenant returns protocol.ErrBadRequest if the session was started for another tenant.
+func (session *SessionData) verifyTenant(tenant string) error {
+	if session.Tenant != tenant {
+		err := protocol.ErrBadRequest.WithDetails("Session was started for a different tenant")
+		return err.WithInfo(fmt.Sprintf("Expected tenant: %q\n Received: %q\n", tenant, session.Tenant))
+	}
+	return nil
 }
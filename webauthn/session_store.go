@@ -0,0 +1,284 @@
+package webauthn
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teamhanko/webauthn-go/protocol"
+)
+
+// SessionStore persists the SessionData of a ceremony between the Begin and the Finish call. Sessions are keyed by
+// their challenge. Implementations must guarantee that a session can be consumed only once, even when
+// ConsumeSession is called concurrently for the same challenge.
+type SessionStore interface {
+	// SaveSession stores the session under its challenge.
+	SaveSession(session *SessionData) error
+	// ConsumeSession returns the session stored for the challenge and removes it in the same step. It returns
+	// protocol.ErrSessionNotFound if no session was stored for the challenge, protocol.ErrSessionAlreadyUsed if the
+	// session was already consumed and protocol.ErrSessionExpired if the session timed out.
+	ConsumeSession(challenge string) (*SessionData, error)
+}
+
+// storedSession is the representation of a session inside of the session stores of this package.
+type storedSession struct {
+	Session   SessionData `json:"session"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+func newStoredSession(session *SessionData, now time.Time) *storedSession {
+	expiresAt := session.Expires
+	if expiresAt.IsZero() {
+		timeout := session.Timeout
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		expiresAt = now.Add(time.Duration(timeout) * time.Millisecond)
+	}
+	return &storedSession{
+		Session:   *session,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// inMemorySessionSweepInterval is the minimum time between two scans of an InMemorySessionStore for expired sessions
+const inMemorySessionSweepInterval = time.Minute
+
+// InMemorySessionStore keeps the sessions in memory. Consumed sessions are remembered until they would have expired,
+// so that a replayed response can be told apart from an unknown one. Expired sessions are removed by SaveSession at
+// most once per minute, ConsumeSession rejects them before they are removed.
+type InMemorySessionStore struct {
+	sessions  map[string]*storedSession
+	used      map[string]time.Time
+	clock     Clock
+	nextSweep time.Time
+	mu        sync.Mutex
+}
+
+var _ SessionStore = (*InMemorySessionStore)(nil)
+
+// NewInMemorySessionStore creates an empty InMemorySessionStore. The clock decides when sessions expire and should be
+// the Clock of the WebAuthn instance, if it is nil the system clock is used.
+func NewInMemorySessionStore(clock Clock) *InMemorySessionStore {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &InMemorySessionStore{
+		sessions: make(map[string]*storedSession),
+		used:     make(map[string]time.Time),
+		clock:    clock,
+	}
+}
+
+func (store *InMemorySessionStore) SaveSession(session *SessionData) error {
+	if session == nil || session.Challenge == "" {
+		return protocol.ErrBadRequest.WithDetails("Session without challenge can not be stored")
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := store.clock.Now()
+	if !now.Before(store.nextSweep) {
+		store.removeExpired(now)
+		store.nextSweep = now.Add(inMemorySessionSweepInterval)
+	}
+
+	if _, exists := store.sessions[session.Challenge]; exists {
+		return protocol.ErrBadRequest.WithDetails("Session for challenge already exists")
+	}
+	if _, used := store.used[session.Challenge]; used {
+		return protocol.ErrSessionAlreadyUsed
+	}
+	store.sessions[session.Challenge] = newStoredSession(session, now)
+
+	return nil
+}
+
+func (store *InMemorySessionStore) ConsumeSession(challenge string) (*SessionData, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	stored, ok := store.sessions[challenge]
+	if !ok {
+		if _, used := store.used[challenge]; used {
+			return nil, protocol.ErrSessionAlreadyUsed
+		}
+		return nil, protocol.ErrSessionNotFound
+	}
+	delete(store.sessions, challenge)
+	store.used[challenge] = stored.ExpiresAt
+
+	if store.clock.Now().After(stored.ExpiresAt) {
+		return nil, protocol.ErrSessionExpired
+	}
+
+	session := stored.Session
+	return &session, nil
+}
+
+// removeExpired drops all sessions and markers of consumed sessions which are expired. The caller must hold the lock.
+func (store *InMemorySessionStore) removeExpired(now time.Time) {
+	for challenge, stored := range store.sessions {
+		if now.After(stored.ExpiresAt) {
+			delete(store.sessions, challenge)
+		}
+	}
+	for challenge, expiresAt := range store.used {
+		if now.After(expiresAt) {
+			delete(store.used, challenge)
+		}
+	}
+}
+
+const (
+	sessionFileSuffix     = ".json"
+	usedSessionFileSuffix = ".used"
+)
+
+// FileSessionStore keeps every session as a JSON file in a directory, which makes it possible to share the sessions
+// between multiple processes on the same host. A session is consumed by renaming its file, which is atomic, so only
+// one caller can ever consume a session.
+type FileSessionStore struct {
+	directory string
+	clock     Clock
+}
+
+var _ SessionStore = (*FileSessionStore)(nil)
+
+// NewFileSessionStore creates a FileSessionStore which stores the sessions in the given directory. The directory is
+// created if it does not exist. The clock decides when sessions expire and should be the Clock of the WebAuthn
+// instance, if it is nil the system clock is used.
+func NewFileSessionStore(directory string, clock Clock) (*FileSessionStore, error) {
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &FileSessionStore{
+		directory: directory,
+		clock:     clock,
+	}, nil
+}
+
+func (store *FileSessionStore) SaveSession(session *SessionData) error {
+	if session == nil || !isValidSessionChallenge(session.Challenge) {
+		return protocol.ErrBadRequest.WithDetails("Session without valid challenge can not be stored")
+	}
+
+	data, err := json.Marshal(newStoredSession(session, store.clock.Now()))
+	if err != nil {
+		return err
+	}
+
+	path := store.path(session.Challenge)
+	if _, err := os.Stat(path + usedSessionFileSuffix); err == nil {
+		return protocol.ErrSessionAlreadyUsed
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return protocol.ErrBadRequest.WithDetails("Session for challenge already exists")
+		}
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+
+	return nil
+}
+
+func (store *FileSessionStore) ConsumeSession(challenge string) (*SessionData, error) {
+	if !isValidSessionChallenge(challenge) {
+		return nil, protocol.ErrSessionNotFound
+	}
+
+	path := store.path(challenge)
+	usedPath := path + usedSessionFileSuffix
+	if err := os.Rename(path, usedPath); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if _, statErr := os.Stat(usedPath); statErr == nil {
+			return nil, protocol.ErrSessionAlreadyUsed
+		}
+		return nil, protocol.ErrSessionNotFound
+	}
+
+	stored, err := readStoredSession(usedPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if store.clock.Now().After(stored.ExpiresAt) {
+		return nil, protocol.ErrSessionExpired
+	}
+
+	return &stored.Session, nil
+}
+
+// Cleanup removes the files of all expired sessions, including the markers of consumed sessions. It should be
+// called periodically, e.g. by a scheduler.
+func (store *FileSessionStore) Cleanup() error {
+	entries, err := ioutil.ReadDir(store.directory)
+	if err != nil {
+		return err
+	}
+
+	now := store.clock.Now()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, sessionFileSuffix) || strings.HasSuffix(name, usedSessionFileSuffix)) {
+			continue
+		}
+		path := filepath.Join(store.directory, name)
+		stored, err := readStoredSession(path)
+		if err != nil || now.After(stored.ExpiresAt) {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (store *FileSessionStore) path(challenge string) string {
+	return filepath.Join(store.directory, challenge+sessionFileSuffix)
+}
+
+func readStoredSession(path string) (*storedSession, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var stored storedSession
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// isValidSessionChallenge checks that the challenge is base64url encoded, which also guarantees that it can be
+// safely used as a file name.
+func isValidSessionChallenge(challenge string) bool {
+	if challenge == "" {
+		return false
+	}
+	_, err := base64.RawURLEncoding.DecodeString(challenge)
+	return err == nil
+}
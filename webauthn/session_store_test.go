@@ -0,0 +1,204 @@
+package webauthn
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teamhanko/webauthn-go/protocol"
+)
+
+func newTestSessionStores(t *testing.T, clock Clock) map[string]SessionStore {
+	fileStore, err := NewFileSessionStore(t.TempDir(), clock)
+	if err != nil {
+		t.Fatalf("NewFileSessionStore() error = %v", err)
+	}
+	return map[string]SessionStore{
+		"InMemorySessionStore": NewInMemorySessionStore(clock),
+		"FileSessionStore":     fileStore,
+	}
+}
+
+func TestSessionStore_ConsumeSession(t *testing.T) {
+	for name, store := range newTestSessionStores(t, nil) {
+		t.Run(name, func(t *testing.T) {
+			session := &SessionData{
+				Challenge: "E4PTcIH_HfX1pC6Sigk1SC9NAlgeztN0439vi8z_c9k",
+				UserID:    []byte("ABC"),
+				Timeout:   60000,
+			}
+			if err := store.SaveSession(session); err != nil {
+				t.Fatalf("SaveSession() error = %v", err)
+			}
+
+			got, err := store.ConsumeSession(session.Challenge)
+			if err != nil {
+				t.Fatalf("ConsumeSession() error = %v", err)
+			}
+			if got.Challenge != session.Challenge || string(got.UserID) != string(session.UserID) {
+				t.Errorf("ConsumeSession() = %+v, want %+v", got, session)
+			}
+
+			_, err = store.ConsumeSession(session.Challenge)
+			if err != protocol.ErrSessionAlreadyUsed {
+				t.Errorf("ConsumeSession() second call error = %v, want %v", err, protocol.ErrSessionAlreadyUsed)
+			}
+
+			if err := store.SaveSession(session); err != protocol.ErrSessionAlreadyUsed {
+				t.Errorf("SaveSession() of used session error = %v, want %v", err, protocol.ErrSessionAlreadyUsed)
+			}
+		})
+	}
+}
+
+func TestSessionStore_ConsumeUnknownSession(t *testing.T) {
+	for name, store := range newTestSessionStores(t, nil) {
+		t.Run(name, func(t *testing.T) {
+			for _, challenge := range []string{"W8GzFU8pGjhoRbWrLDlamAfq_y4S1CZG1VuoeRLARrE", "", "../../etc/passwd"} {
+				_, err := store.ConsumeSession(challenge)
+				if err != protocol.ErrSessionNotFound {
+					t.Errorf("ConsumeSession(%q) error = %v, want %v", challenge, err, protocol.ErrSessionNotFound)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionStore_ConsumeExpiredSession(t *testing.T) {
+	clock := &testClock{}
+	for name, store := range newTestSessionStores(t, clock) {
+		t.Run(name, func(t *testing.T) {
+			clock.now = time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+			session := &SessionData{
+				Challenge: "E4PTcIH_HfX1pC6Sigk1SC9NAlgeztN0439vi8z_c9k",
+				Timeout:   1000,
+			}
+			if err := store.SaveSession(session); err != nil {
+				t.Fatalf("SaveSession() error = %v", err)
+			}
+
+			clock.now = clock.now.Add(1001 * time.Millisecond)
+			_, err := store.ConsumeSession(session.Challenge)
+			if err != protocol.ErrSessionExpired {
+				t.Errorf("ConsumeSession() error = %v, want %v", err, protocol.ErrSessionExpired)
+			}
+		})
+	}
+}
+
+func TestSessionStore_ConcurrentConsume(t *testing.T) {
+	for name, store := range newTestSessionStores(t, nil) {
+		t.Run(name, func(t *testing.T) {
+			session := &SessionData{
+				Challenge: "E4PTcIH_HfX1pC6Sigk1SC9NAlgeztN0439vi8z_c9k",
+				Timeout:   60000,
+			}
+			if err := store.SaveSession(session); err != nil {
+				t.Fatalf("SaveSession() error = %v", err)
+			}
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			successes := 0
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := store.ConsumeSession(session.Challenge); err == nil {
+						mu.Lock()
+						successes++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+
+			if successes != 1 {
+				t.Errorf("ConsumeSession() succeeded %d times, want 1", successes)
+			}
+		})
+	}
+}
+
+func TestInMemorySessionStore_RemoveExpired(t *testing.T) {
+	clock := &testClock{now: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewInMemorySessionStore(clock)
+
+	if err := store.SaveSession(&SessionData{Challenge: "E4PTcIH_HfX1pC6Sigk1SC9NAlgeztN0439vi8z_c9k", Timeout: 1000}); err != nil {
+		t.Fatalf("SaveSession() error = %v", err)
+	}
+
+	// Saves within the sweep interval do not scan the store
+	clock.now = clock.now.Add(2 * time.Second)
+	if err := store.SaveSession(&SessionData{Challenge: "W8GzFU8pGjhoRbWrLDlamAfq_y4S1CZG1VuoeRLARrE", Timeout: 300000}); err != nil {
+		t.Fatalf("SaveSession() error = %v", err)
+	}
+	if len(store.sessions) != 2 {
+		t.Errorf("sessions = %d, want 2 before the sweep interval passed", len(store.sessions))
+	}
+
+	clock.now = clock.now.Add(inMemorySessionSweepInterval)
+	if err := store.SaveSession(&SessionData{Challenge: "kqnYxCslh2GmCX0yvPnBS8yxZ1kZ2MzXJ4_WlXGn9z8", Timeout: 300000}); err != nil {
+		t.Fatalf("SaveSession() error = %v", err)
+	}
+	if _, expired := store.sessions["E4PTcIH_HfX1pC6Sigk1SC9NAlgeztN0439vi8z_c9k"]; expired || len(store.sessions) != 2 {
+		t.Errorf("sessions = %d, want the expired session to be removed", len(store.sessions))
+	}
+}
+
+func TestFileSessionStore_Cleanup(t *testing.T) {
+	clock := &testClock{now: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)}
+	store, err := NewFileSessionStore(t.TempDir(), clock)
+	if err != nil {
+		t.Fatalf("NewFileSessionStore() error = %v", err)
+	}
+
+	expired := &SessionData{Challenge: "E4PTcIH_HfX1pC6Sigk1SC9NAlgeztN0439vi8z_c9k", Timeout: 1000}
+	valid := &SessionData{Challenge: "W8GzFU8pGjhoRbWrLDlamAfq_y4S1CZG1VuoeRLARrE", Timeout: 60000}
+	if err := store.SaveSession(expired); err != nil {
+		t.Fatalf("SaveSession() error = %v", err)
+	}
+	if err := store.SaveSession(valid); err != nil {
+		t.Fatalf("SaveSession() error = %v", err)
+	}
+
+	clock.now = clock.now.Add(2 * time.Second)
+	if err := store.Cleanup(); err != nil {
+		t.Fatalf("Cleanup() error = %v", err)
+	}
+
+	if _, err := store.ConsumeSession(expired.Challenge); err != protocol.ErrSessionNotFound {
+		t.Errorf("ConsumeSession() of cleaned up session error = %v, want %v", err, protocol.ErrSessionNotFound)
+	}
+	if _, err := store.ConsumeSession(valid.Challenge); err != nil {
+		t.Errorf("ConsumeSession() of valid session error = %v", err)
+	}
+}
+
+func TestLogin_BeginLoginSavesSession(t *testing.T) {
+	webauthn := &WebAuthn{
+		Config: &Config{
+			RPID:          "localhost",
+			RPDisplayName: "Test Relying Party",
+		},
+		SessionStore: NewInMemorySessionStore(nil),
+	}
+
+	_, sessionData, err := webauthn.BeginLogin(nil)
+	if err != nil {
+		t.Fatalf("BeginLogin() error = %v", err)
+	}
+
+	stored, err := webauthn.ConsumeSession(sessionData.Challenge)
+	if err != nil {
+		t.Fatalf("ConsumeSession() error = %v", err)
+	}
+	if stored.Challenge != sessionData.Challenge {
+		t.Errorf("ConsumeSession() challenge = %s, want %s", stored.Challenge, sessionData.Challenge)
+	}
+
+	if _, err := webauthn.ConsumeSession(sessionData.Challenge); err != protocol.ErrSessionAlreadyUsed {
+		t.Errorf("ConsumeSession() second call error = %v, want %v", err, protocol.ErrSessionAlreadyUsed)
+	}
+}
@@ -0,0 +1,157 @@
+package webauthn
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/teamhanko/webauthn-go/credential"
+	"github.com/teamhanko/webauthn-go/metadata"
+	"github.com/teamhanko/webauthn-go/protocol"
+)
+
+// Tenant contains everything that is needed to create the WebAuthn instance of one tenant
+type Tenant struct {
+	Config            *Config
+	MetadataService   metadata.MetadataService
+	CredentialService credential.CredentialService
+	RpPolicy          protocol.RelyingPartyPolicy
+	// SessionStore is optional, it may be shared between tenants
+	SessionStore SessionStore
+	// CounterPolicy is optional, see WebAuthn.CounterPolicy
+	CounterPolicy protocol.CounterPolicy
+}
+
+// TenantResolver loads the tenant for a key. It returns nil if there is no tenant for the key.
+type TenantResolver interface {
+	ResolveTenant(key string) (*Tenant, error)
+}
+
+// TenantResolverFunc is a function which implements TenantResolver
+type TenantResolverFunc func(key string) (*Tenant, error)
+
+func (f TenantResolverFunc) ResolveTenant(key string) (*Tenant, error) {
+	return f(key)
+}
+
+// TenantRegistry provides the WebAuthn instances of many tenants, e.g. when every customer has its own RP ID. An
+// instance is created the first time its tenant is requested and cached afterwards. It is safe for concurrent use.
+type TenantRegistry struct {
+	resolver TenantResolver
+	// hostKey maps the host of a request to the key of its tenant. If it is nil, the host without port is the key.
+	hostKey func(host string) (string, error)
+
+	instances map[string]*tenantInstance
+	mu        sync.Mutex
+}
+
+// tenantInstance is a cached instance, done is closed once the instance was created
+type tenantInstance struct {
+	done     chan struct{}
+	webauthn *WebAuthn
+	err      error
+}
+
+// TenantRegistryOption configures a TenantRegistry when it is created
+type TenantRegistryOption func(registry *TenantRegistry)
+
+// WithHostKey maps the host of a request to the key of its tenant, e.g. to serve several hosts for one tenant. By
+// default the host without port is the key.
+func WithHostKey(hostKey func(host string) (string, error)) TenantRegistryOption {
+	return func(registry *TenantRegistry) {
+		registry.hostKey = hostKey
+	}
+}
+
+// NewTenantRegistry creates a TenantRegistry which resolves the tenants with the given resolver
+func NewTenantRegistry(resolver TenantResolver, opts ...TenantRegistryOption) *TenantRegistry {
+	registry := &TenantRegistry{
+		resolver:  resolver,
+		instances: make(map[string]*tenantInstance),
+	}
+	for _, setter := range opts {
+		setter(registry)
+	}
+	return registry
+}
+
+// Get returns the WebAuthn instance of the tenant with the given key. It returns protocol.ErrTenantNotFound if the
+// resolver does not know the tenant. Failures are not cached, so the next call tries to resolve the tenant again.
+func (registry *TenantRegistry) Get(key string) (*WebAuthn, error) {
+	registry.mu.Lock()
+	instance, ok := registry.instances[key]
+	if !ok {
+		instance = &tenantInstance{done: make(chan struct{})}
+		registry.instances[key] = instance
+	}
+	registry.mu.Unlock()
+
+	if ok {
+		<-instance.done
+		return instance.webauthn, instance.err
+	}
+
+	instance.webauthn, instance.err = registry.create(key)
+	if instance.err != nil {
+		registry.mu.Lock()
+		delete(registry.instances, key)
+		registry.mu.Unlock()
+	}
+	close(instance.done)
+
+	return instance.webauthn, instance.err
+}
+
+// ForHost returns the WebAuthn instance of the tenant serving the given host, which may contain a port
+func (registry *TenantRegistry) ForHost(host string) (*WebAuthn, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	key := host
+	if registry.hostKey != nil {
+		var err error
+		if key, err = registry.hostKey(host); err != nil {
+			return nil, err
+		}
+	}
+	return registry.Get(key)
+}
+
+// ForRequest returns the WebAuthn instance of the tenant serving the host of the request
+func (registry *TenantRegistry) ForRequest(r *http.Request) (*WebAuthn, error) {
+	return registry.ForHost(r.Host)
+}
+
+// Remove drops the cached instance of the tenant, so that it is resolved again the next time it is requested, e.g.
+// after its configuration was changed.
+func (registry *TenantRegistry) Remove(key string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	delete(registry.instances, key)
+}
+
+func (registry *TenantRegistry) create(key string) (*WebAuthn, error) {
+	tenant, err := registry.resolver.ResolveTenant(key)
+	if err != nil {
+		return nil, err
+	}
+	if tenant == nil || tenant.Config == nil {
+		return nil, protocol.ErrTenantNotFound.WithInfo(fmt.Sprintf("Tenant: %q", key))
+	}
+
+	// The resolver may return a Config shared by several tenants, New must not change it
+	webauthn, err := New(tenant.Config.clone(), tenant.MetadataService, tenant.CredentialService, tenant.RpPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %s: %w", key, err)
+	}
+	webauthn.SessionStore = tenant.SessionStore
+	webauthn.CounterPolicy = tenant.CounterPolicy
+	webauthn.Tenant = key
+
+	return webauthn, nil
+}
@@ -0,0 +1,204 @@
+package webauthn
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/teamhanko/webauthn-go/credential"
+	"github.com/teamhanko/webauthn-go/protocol"
+)
+
+// newTestTenantRegistry creates a registry with the tenants example.com and example.org and counts the resolutions
+func newTestTenantRegistry(resolved *int32, opts ...TenantRegistryOption) *TenantRegistry {
+	return NewTenantRegistry(TenantResolverFunc(func(key string) (*Tenant, error) {
+		atomic.AddInt32(resolved, 1)
+		switch key {
+		case "example.com", "example.org":
+			return &Tenant{
+				Config: &Config{
+					RPDisplayName: key,
+					RPID:          key,
+					RPOrigin:      "https://" + key,
+				},
+				CredentialService: credential.NewInMemoryCredentialService(),
+			}, nil
+		case "broken.com":
+			return nil, errors.New("database unavailable")
+		}
+		return nil, nil
+	}), opts...)
+}
+
+func TestTenantRegistry_Get(t *testing.T) {
+	var resolved int32
+	registry := newTestTenantRegistry(&resolved)
+
+	var wg sync.WaitGroup
+	instances := make([]*WebAuthn, 10)
+	for i := range instances {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			instance, err := registry.Get("example.com")
+			if err != nil {
+				t.Errorf("Get() error = %v", err)
+			}
+			instances[i] = instance
+		}(i)
+	}
+	wg.Wait()
+
+	if resolved != 1 {
+		t.Errorf("tenant resolved %d times, want 1", resolved)
+	}
+	for _, instance := range instances {
+		if instance != instances[0] {
+			t.Fatalf("Get() returned different instances for the same tenant")
+		}
+	}
+	if instances[0].Tenant != "example.com" || instances[0].Config.RPID != "example.com" {
+		t.Errorf("Get() = tenant %s with RPID %s, want example.com", instances[0].Tenant, instances[0].Config.RPID)
+	}
+
+	registry.Remove("example.com")
+	if _, err := registry.Get("example.com"); err != nil || resolved != 2 {
+		t.Errorf("Get() after Remove() error = %v, resolved %d times, want nil and 2", err, resolved)
+	}
+}
+
+func TestTenantRegistry_GetErrors(t *testing.T) {
+	var resolved int32
+	registry := newTestTenantRegistry(&resolved)
+
+	_, err := registry.Get("unknown.com")
+	var protocolErr *protocol.Error
+	if !errors.As(err, &protocolErr) || protocolErr.Type != protocol.ErrTenantNotFound.Type {
+		t.Errorf("Get() error = %v, want %v", err, protocol.ErrTenantNotFound)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := registry.Get("broken.com"); err == nil {
+			t.Errorf("Get() error = nil, want error")
+		}
+	}
+	if resolved != 3 {
+		t.Errorf("tenant resolved %d times, want 3 because failures are not cached", resolved)
+	}
+}
+
+func TestTenantRegistry_ForRequest(t *testing.T) {
+	tests := []struct {
+		host       string
+		hostKey    func(host string) (string, error)
+		wantTenant string
+		wantErr    bool
+	}{
+		{host: "example.com", wantTenant: "example.com"},
+		{host: "Example.org:8443", wantTenant: "example.org"},
+		{host: "unknown.com", wantErr: true},
+		{
+			host: "login.example.org",
+			hostKey: func(host string) (string, error) {
+				return "example.org", nil
+			},
+			wantTenant: "example.org",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			var resolved int32
+			registry := newTestTenantRegistry(&resolved, WithHostKey(tt.hostKey))
+			r := httptest.NewRequest("POST", "https://"+tt.host+"/login", nil)
+
+			instance, err := registry.ForRequest(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ForRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && instance.Tenant != tt.wantTenant {
+				t.Errorf("ForRequest() tenant = %s, want %s", instance.Tenant, tt.wantTenant)
+			}
+		})
+	}
+}
+
+func TestTenantRegistry_SessionOfOtherTenant(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	userId := []byte("user-1")
+	tenantA, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+	tenantA.Tenant = "tenant-a"
+	tenantB, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+	tenantB.Tenant = "tenant-b"
+
+	_, session, err := tenantA.BeginLogin(&defaultUser{id: userId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Tenant != "tenant-a" {
+		t.Errorf("BeginLogin() session tenant = %s, want tenant-a", session.Tenant)
+	}
+
+	parsedResponse := authenticator.getAssertion(t, testAssertion{
+		Challenge: session.Challenge,
+		Flags:     protocol.FlagUserPresent,
+	})
+	if _, err := tenantB.ValidateLogin(*session, parsedResponse); err == nil {
+		t.Errorf("ValidateLogin() of other tenant error = nil, want error")
+	}
+	if _, err := tenantA.ValidateLogin(*session, parsedResponse); err != nil {
+		t.Errorf("ValidateLogin() error = %v", err)
+	}
+}
+
+func TestTenantRegistry_SharedSessionStore(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	userId := []byte("user-1")
+	tenantA, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+	tenantA.Tenant = "tenant-a"
+	tenantB, _ := newTestAuthenticatorWebAuthn(t, authenticator, userId)
+	tenantB.Tenant = "tenant-b"
+	store := NewInMemorySessionStore(tenantA.Clock)
+	tenantA.SessionStore = store
+	tenantB.SessionStore = store
+
+	_, session, err := tenantA.BeginLogin(&defaultUser{id: userId})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A response sent to another tenant must not consume the session
+	if _, err := tenantB.ConsumeSession(session.Challenge); !errors.Is(err, protocol.ErrSessionNotFound) {
+		t.Errorf("ConsumeSession() of other tenant error = %v, want %v", err, protocol.ErrSessionNotFound)
+	}
+	consumed, err := tenantA.ConsumeSession(session.Challenge)
+	if err != nil {
+		t.Fatalf("ConsumeSession() error = %v", err)
+	}
+	if consumed.Challenge != session.Challenge || consumed.Tenant != "tenant-a" {
+		t.Errorf("ConsumeSession() = %+v, want %+v", consumed, session)
+	}
+}
+
+func TestTenantRegistry_SharedConfig(t *testing.T) {
+	config := &Config{
+		RPDisplayName: "example.com",
+		RPID:          "example.com",
+		RPOrigin:      "https://example.com",
+	}
+	registry := NewTenantRegistry(TenantResolverFunc(func(key string) (*Tenant, error) {
+		return &Tenant{Config: config, CredentialService: credential.NewInMemoryCredentialService()}, nil
+	}))
+
+	instance, err := registry.Get("example.com")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if instance.Config == config {
+		t.Errorf("Get() instance shares the config of the resolver")
+	}
+	if len(config.RPOrigins) != 0 {
+		t.Errorf("Get() changed the config of the resolver, RPOrigins = %v", config.RPOrigins)
+	}
+}
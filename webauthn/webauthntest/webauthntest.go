@@ -0,0 +1,209 @@
+// Package webauthntest contains a software authenticator for tests of relying parties. It creates the JSON bodies a
+// client would send for navigator.credentials.create() and navigator.credentials.get(), and allows to test flags and
+// extension outputs which are not present in recorded responses. The helpers take a testing.TB, so they can be used
+// in tests, benchmarks and fuzz targets.
+package webauthntest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/teamhanko/webauthn-go/credential"
+	"github.com/teamhanko/webauthn-go/protocol"
+	"github.com/teamhanko/webauthn-go/protocol/webauthncose"
+)
+
+// Authenticator is a software authenticator with a P-256 key and a single credential
+type Authenticator struct {
+	// RPID is the RP ID whose hash is part of the authenticator data
+	RPID string
+	// Origin is the origin of the client data
+	Origin string
+
+	Key          *ecdsa.PrivateKey
+	CredentialID []byte
+	// UserHandle is set by Create and returned by Get, unless the assertion sets another user handle
+	UserHandle []byte
+	// Counter is the signature counter, which is increased for every authenticator data
+	Counter uint32
+}
+
+// Assertion describes the assertion an Authenticator should create
+type Assertion struct {
+	Challenge  string
+	Flags      protocol.AuthenticatorFlags
+	UserHandle []byte
+	// AuthenticatorExtensions are CBOR encoded into the authenticator data, which also sets the ED flag
+	AuthenticatorExtensions map[string]interface{}
+	// ClientExtensions are returned as client extension outputs
+	ClientExtensions map[string]interface{}
+	// ClientData contains additional members of the client data, e.g. to overwrite the origin
+	ClientData map[string]interface{}
+	// RPID overwrites the RP ID whose hash is part of the authenticator data, e.g. to use an AppID
+	RPID string
+}
+
+// NewAuthenticator creates an Authenticator with a new key and a random credential ID for the RP
+func NewAuthenticator(t testing.TB, rpId, origin string) *Authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+	return &Authenticator{RPID: rpId, Origin: origin, Key: key, CredentialID: credentialID}
+}
+
+// PublicKey returns the COSE encoded public key of the authenticator
+func (a *Authenticator) PublicKey(t testing.TB) []byte {
+	publicKey, err := cbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1, // P-256
+		XCoord: a.Key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.Key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return publicKey
+}
+
+// Credential returns the credential of the authenticator as it would be stored after the registration
+func (a *Authenticator) Credential(t testing.TB) *credential.Credential {
+	return &credential.Credential{
+		ID:              a.CredentialID,
+		PublicKey:       a.PublicKey(t),
+		AttestationType: "none",
+		Authenticator: credential.Authenticator{
+			SignCount: a.Counter,
+		},
+	}
+}
+
+// AuthenticatorData returns the authenticator data for the RP ID with an increased counter
+func (a *Authenticator) AuthenticatorData(t testing.TB, rpId string, flags protocol.AuthenticatorFlags, attestedCredentialData []byte, extensions map[string]interface{}) []byte {
+	a.Counter++
+	rpIdHash := sha256.Sum256([]byte(rpId))
+	authData := bytes.NewBuffer(rpIdHash[:])
+	if attestedCredentialData != nil {
+		flags |= protocol.FlagAttestedCredentialData
+	}
+	if extensions != nil {
+		flags |= protocol.FlagHasExtensions
+	}
+	authData.WriteByte(byte(flags))
+	_ = binary.Write(authData, binary.BigEndian, a.Counter)
+	authData.Write(attestedCredentialData)
+	if extensions != nil {
+		extData, err := cbor.Marshal(extensions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authData.Write(extData)
+	}
+	return authData.Bytes()
+}
+
+// Create returns the response of navigator.credentials.create() with none attestation for the creation options
+func (a *Authenticator) Create(t testing.TB, options protocol.PublicKeyCredentialCreationOptions) []byte {
+	a.UserHandle = options.User.ID
+	attestedCredentialData := bytes.NewBuffer(make([]byte, 16))
+	_ = binary.Write(attestedCredentialData, binary.BigEndian, uint16(len(a.CredentialID)))
+	attestedCredentialData.Write(a.CredentialID)
+	attestedCredentialData.Write(a.PublicKey(t))
+
+	authData := a.AuthenticatorData(t, a.RPID, protocol.FlagUserPresent, attestedCredentialData.Bytes(), nil)
+	attestationObject, err := cbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.response(t, map[string][]byte{
+		"clientDataJSON":    a.clientData(t, protocol.CreateCeremony, options.Challenge.String(), nil),
+		"attestationObject": attestationObject,
+	}, nil)
+}
+
+// Get returns the response of navigator.credentials.get() with a signed assertion
+func (a *Authenticator) Get(t testing.TB, assertion Assertion) []byte {
+	clientData := a.clientData(t, protocol.AssertCeremony, assertion.Challenge, assertion.ClientData)
+	rpId := a.RPID
+	if assertion.RPID != "" {
+		rpId = assertion.RPID
+	}
+	authData := a.AuthenticatorData(t, rpId, assertion.Flags, nil, assertion.AuthenticatorExtensions)
+	clientDataHash := sha256.Sum256(clientData)
+	signedData := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.Key, signedData[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userHandle := a.UserHandle
+	if assertion.UserHandle != nil {
+		userHandle = assertion.UserHandle
+	}
+	return a.response(t, map[string][]byte{
+		"authenticatorData": authData,
+		"clientDataJSON":    clientData,
+		"signature":         signature,
+		"userHandle":        userHandle,
+	}, assertion.ClientExtensions)
+}
+
+// clientData returns the client data JSON of the ceremony, with the additional members
+func (a *Authenticator) clientData(t testing.TB, ceremony protocol.CeremonyType, challenge string, additional map[string]interface{}) []byte {
+	clientData := map[string]interface{}{
+		"type":      ceremony,
+		"challenge": challenge,
+		"origin":    a.Origin,
+	}
+	for key, value := range additional {
+		clientData[key] = value
+	}
+	clientDataJSON, err := json.Marshal(clientData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clientDataJSON
+}
+
+// response returns the JSON of the PublicKeyCredential with the base64url encoded members of the response
+func (a *Authenticator) response(t testing.TB, response map[string][]byte, clientExtensions map[string]interface{}) []byte {
+	encode := base64.RawURLEncoding.EncodeToString
+	encoded := map[string]string{}
+	for key, value := range response {
+		encoded[key] = encode(value)
+	}
+	credential := map[string]interface{}{
+		"id":       encode(a.CredentialID),
+		"rawId":    encode(a.CredentialID),
+		"type":     "public-key",
+		"response": encoded,
+	}
+	if clientExtensions != nil {
+		credential["extensions"] = clientExtensions
+	}
+	body, err := json.Marshal(credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}